@@ -3,7 +3,6 @@ package ssh
 import (
 	"fmt"
 	"strings"
-	"time"
 )
 
 const (
@@ -12,6 +11,14 @@ const (
 	CISCO  = "cisco"
 )
 
+// 根据提示符识别出的设备权限模式
+const (
+	UNKNOWN_MODE    = ""
+	USER_MODE       = "user"       //用户模式，如cisco的"Switch>"，华为/h3c的"<Switch>"
+	PRIVILEGED_MODE = "privileged" //特权模式，如cisco的"Switch#"
+	CONFIG_MODE     = "config"     //配置模式，如cisco的"Switch(config)#"，华为/h3c的"[Switch]"
+)
+
 var IsLogDebug = true
 
 /**
@@ -21,19 +28,7 @@ var IsLogDebug = true
  * @author shenbowei
  */
 func RunCommands(user, password, ipPort string, cmds ...string) (string, error) {
-	sessionKey := user + "_" + password + "_" + ipPort
-	sessionManager.LockSession(sessionKey)
-	defer sessionManager.UnlockSession(sessionKey)
-
-	sshSession, err := sessionManager.GetSession(user, password, ipPort, "")
-	if err != nil {
-		LogError("GetSession error:%s", err)
-		return "", err
-	}
-	sshSession.WriteChannel(cmds...)
-	result := sshSession.ReadChannelTiming(2 * time.Second)
-	filteredResult := filterResult(result, cmds[0])
-	return filteredResult, nil
+	return DefaultSessionManager.RunCommands(user, password, ipPort, cmds...)
 }
 
 /**
@@ -43,19 +38,17 @@ func RunCommands(user, password, ipPort string, cmds ...string) (string, error)
  * @author shenbowei
  */
 func RunCommandsWithBrand(user, password, ipPort, brand string, cmds ...string) (string, error) {
-	sessionKey := user + "_" + password + "_" + ipPort
-	sessionManager.LockSession(sessionKey)
-	defer sessionManager.UnlockSession(sessionKey)
-
-	sshSession, err := sessionManager.GetSession(user, password, ipPort, brand)
-	if err != nil {
-		LogError("GetSession error:%s", err)
-		return "", err
-	}
-	sshSession.WriteChannel(cmds...)
-	result := sshSession.ReadChannelTiming(2 * time.Second)
-	filteredResult := filterResult(result, cmds[0])
-	return filteredResult, nil
+	return DefaultSessionManager.RunCommandsWithBrand(user, password, ipPort, brand, cmds...)
+}
+
+/**
+ * 外部调用的统一方法，完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），提升权限（cisco的enable，华为/h3c的super），执行指令的流程，返回执行结果
+ * @param user ssh连接的用户名, password 密码, enablePassword 提权密码（可为空）, ipPort 交换机的ip和端口, brand 交换机品牌（可为空）， cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func RunCommandsWithEnable(user, password, enablePassword, ipPort, brand string, cmds ...string) (string, error) {
+	return DefaultSessionManager.RunCommandsWithEnable(user, password, enablePassword, ipPort, brand, cmds...)
 }
 
 /**
@@ -65,16 +58,227 @@ func RunCommandsWithBrand(user, password, ipPort, brand string, cmds ...string)
  * @author shenbowei
  */
 func GetSSHBrand(user, password, ipPort string) (string, error) {
-	sessionKey := user + "_" + password + "_" + ipPort
-	sessionManager.LockSession(sessionKey)
-	defer sessionManager.UnlockSession(sessionKey)
-
-	sshSession, err := sessionManager.GetSession(user, password, ipPort, "")
-	if err != nil {
-		LogError("GetSession error:%s", err)
-		return "", err
-	}
-	return sshSession.GetSSHBrand(), nil
+	return DefaultSessionManager.GetSSHBrand(user, password, ipPort)
+}
+
+/**
+ * 外部调用的统一方法，识别设备的品牌并返回识别的依据和可信度
+ * @param device 设备的身份信息
+ * @return 识别结果和执行错误
+ * @author shenbowei
+ */
+func DetectDeviceBrand(device Device) (BrandDetection, error) {
+	return DefaultSessionManager.DetectDeviceBrand(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的基本信息（型号、软件版本、序列号、运行时间、设备名称、堆叠成员等）
+ * @param device 设备的身份信息
+ * @return 设备的基本信息和执行错误
+ * @author shenbowei
+ */
+func GetFacts(device Device) (Facts, error) {
+	return DefaultSessionManager.GetFacts(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备所有接口的状态、速率、双工、MTU、描述、VLAN模式和IP地址
+ * @param device 设备的身份信息
+ * @return 接口信息和执行错误
+ * @author shenbowei
+ */
+func GetInterfaces(device Device) ([]Interface, error) {
+	return DefaultSessionManager.GetInterfaces(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备所有接口的流量和错误计数
+ * @param device 设备的身份信息
+ * @return 接口计数和执行错误
+ * @author shenbowei
+ */
+func GetInterfaceCounters(device Device) ([]InterfaceCounters, error) {
+	return DefaultSessionManager.GetInterfaceCounters(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的MAC地址表
+ * @param device 设备的身份信息
+ * @return MAC地址表项和执行错误
+ * @author shenbowei
+ */
+func GetMACTable(device Device) ([]MACEntry, error) {
+	return DefaultSessionManager.GetMACTable(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的ARP表
+ * @param device 设备的身份信息
+ * @return ARP表项和执行错误
+ * @author shenbowei
+ */
+func GetARPTable(device Device) ([]ARPEntry, error) {
+	return DefaultSessionManager.GetARPTable(device)
+}
+
+/**
+ * 外部调用的统一方法，使用默认配置查找主机所在的接入端口
+ * @param devices 查找的设备, target 主机的MAC地址或IPv4地址
+ * @return 主机所在的接入端口，执行的错误（所有设备都没有找到时返回ErrHostNotFound或设备的错误）
+ * @author shenbowei
+ */
+func LocateHost(devices []Device, target string) ([]HostLocation, error) {
+	return new(HostLocator).Locate(devices, target)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的LLDP邻居
+ * @param device 设备的身份信息
+ * @return LLDP邻居和执行错误
+ * @author shenbowei
+ */
+func GetLLDPNeighbors(device Device) ([]Neighbor, error) {
+	return DefaultSessionManager.GetLLDPNeighbors(device)
+}
+
+/**
+ * 外部调用的统一方法，获取cisco设备的CDP邻居
+ * @param device 设备的身份信息
+ * @return CDP邻居和执行错误
+ * @author shenbowei
+ */
+func GetCDPNeighbors(device Device) ([]Neighbor, error) {
+	return DefaultSessionManager.GetCDPNeighbors(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的LLDP和CDP邻居
+ * @param device 设备的身份信息
+ * @return 邻居和执行错误
+ * @author shenbowei
+ */
+func GetNeighbors(device Device) ([]Neighbor, error) {
+	return DefaultSessionManager.GetNeighbors(device)
+}
+
+/**
+ * 外部调用的统一方法，使用默认的SessionManager发现网络拓扑
+ * @param seeds 种子设备, credentials 新发现设备的登录信息（为nil时只登录种子设备）
+ * @return 网络拓扑，执行的错误（所有种子设备都登录失败时返回第一个错误）
+ * @author shenbowei
+ */
+func DiscoverTopology(seeds []Device, credentials CredentialProvider) (*Topology, error) {
+	return (&Crawler{Credentials: credentials}).Crawl(seeds)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的IPv4路由表
+ * @param device 设备的身份信息, vrf VPN实例/VRF的名称（为""时获取公网路由表）
+ * @return 路由表项和执行错误
+ * @author shenbowei
+ */
+func GetRouteTable(device Device, vrf string) ([]Route, error) {
+	return DefaultSessionManager.GetRouteTable(device, vrf)
+}
+
+/**
+ * 外部调用的统一方法，使用默认配置在公网路由表中逐跳追踪目的地址
+ * @param devices 路径上可能经过的设备（第一台为起点）, destination 目的IPv4地址
+ * @return 经过的各跳，追踪的错误
+ * @author shenbowei
+ */
+func TraceRoute(devices []Device, destination string) ([]TraceHop, error) {
+	return new(RouteTracer).Trace(devices, destination)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的BGP IPv4单播对等体
+ * @param device 设备的身份信息
+ * @return BGP对等体和执行错误
+ * @author shenbowei
+ */
+func GetBGPPeers(device Device) ([]BGPPeer, error) {
+	return DefaultSessionManager.GetBGPPeers(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的OSPF邻居
+ * @param device 设备的身份信息
+ * @return OSPF邻居和执行错误
+ * @author shenbowei
+ */
+func GetOSPFNeighbors(device Device) ([]OSPFNeighbor, error) {
+	return DefaultSessionManager.GetOSPFNeighbors(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的VLAN及其成员接口
+ * @param device 设备的身份信息
+ * @return VLAN和执行错误
+ * @author shenbowei
+ */
+func GetVLANs(device Device) ([]VLAN, error) {
+	return DefaultSessionManager.GetVLANs(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的生成树信息
+ * @param device 设备的身份信息
+ * @return 生成树信息和执行错误
+ * @author shenbowei
+ */
+func GetSpanningTree(device Device) (SpanningTree, error) {
+	return DefaultSessionManager.GetSpanningTree(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备的风扇、电源、温度、CPU和内存信息
+ * @param device 设备的身份信息
+ * @return 硬件环境信息和执行错误
+ * @author shenbowei
+ */
+func GetEnvironment(device Device) (Environment, error) {
+	return DefaultSessionManager.GetEnvironment(device)
+}
+
+/**
+ * 外部调用的统一方法，获取设备上光模块的诊断信息和告警阈值
+ * @param device 设备的身份信息
+ * @return 光模块信息和执行错误
+ * @author shenbowei
+ */
+func GetTransceivers(device Device) ([]Transceiver, error) {
+	return DefaultSessionManager.GetTransceivers(device)
+}
+
+/**
+ * 外部调用的统一方法，完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），按需提升权限，执行指令的流程，返回执行结果
+ * @param device 设备的身份信息, cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func RunDeviceCommands(device Device, cmds ...string) (string, error) {
+	return DefaultSessionManager.RunDeviceCommands(device, cmds...)
+}
+
+/**
+ * 外部调用的统一方法，完成获取交换机的型号
+ * @param device 设备的身份信息
+ * @return 设备品牌（huawei，h3c，cisco，""）和执行错误
+ * @author shenbowei
+ */
+func GetDeviceBrand(device Device) (string, error) {
+	return DefaultSessionManager.GetDeviceBrand(device)
+}
+
+/**
+ * 外部调用的统一方法，按设备的执行方式（shell或exec）逐条执行指令，返回每条指令的输出和退出码
+ * @param device 设备的身份信息, cmds 执行的指令(可以多个)
+ * @return 每条指令的执行结果和执行错误
+ * @author shenbowei
+ */
+func RunDeviceExec(device Device, cmds ...string) ([]ExecResult, error) {
+	return DefaultSessionManager.RunDeviceExec(device, cmds...)
 }
 
 /**
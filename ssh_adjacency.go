@@ -0,0 +1,348 @@
+package ssh
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 邻居建立完成时的状态，其他状态为设备输出的小写形式（如idle、active、2-way、init）
+const (
+	BGP_ESTABLISHED = "established"
+	OSPF_FULL       = "full"
+)
+
+/**
+ * BGP对等体
+ * @attr Address:对等体地址，RemoteAS:对等体的AS号，LocalAS:本端AS号，RouterID:本端的Router ID，
+ *       State:会话状态（BGP_ESTABLISHED、idle、active等），Uptime:会话建立（或断开）的时长，PrefixesReceived:收到的前缀数量
+ * @author shenbowei
+ */
+type BGPPeer struct {
+	Address          string
+	RemoteAS         uint32
+	LocalAS          uint32
+	RouterID         string
+	State            string
+	Uptime           time.Duration
+	PrefixesReceived int
+}
+
+/**
+ * OSPF邻居
+ * @attr RouterID:邻居的Router ID，Address:邻居的接口地址（华为brief输出中没有时为""），Area:区域（点分十进制形式），
+ *       State:邻居状态（OSPF_FULL、2-way、init等），Role:邻居的DR角色（DR、BDR、DROther，没有时为""），Interface:规范化后的本端接口
+ * @author shenbowei
+ */
+type OSPFNeighbor struct {
+	RouterID  string
+	Address   string
+	Area      string
+	State     string
+	Role      string
+	Interface string
+}
+
+// 获取BGP对等体和OSPF邻居时各品牌执行的指令
+var (
+	HuaweiBGPPeerCmd      = "display bgp peer"
+	H3cBGPPeerCmd         = "display bgp peer ipv4"
+	CiscoBGPSummaryCmd    = "show ip bgp summary"
+	HuaweiOSPFPeerCmd     = "display ospf peer brief"
+	H3cOSPFPeerCmd        = "display ospf peer"
+	CiscoOSPFNeighborCmd  = "show ip ospf neighbor"
+	CiscoOSPFInterfaceCmd = "show ip ospf interface brief"
+)
+
+/**
+ * 获取设备的BGP IPv4单播对等体
+ * @param device 设备的身份信息
+ * @return BGP对等体，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetBGPPeers(device Device) ([]BGPPeer, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiBGPPeerCmd},
+		H3C:    {H3cBGPPeerCmd},
+		CISCO:  {CiscoBGPSummaryCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch brand {
+	case H3C:
+		return parseBGPPeers(brand, outputs[H3cBGPPeerCmd]), nil
+	case CISCO:
+		return parseBGPPeers(brand, outputs[CiscoBGPSummaryCmd]), nil
+	}
+	return parseBGPPeers(brand, outputs[HuaweiBGPPeerCmd]), nil
+}
+
+/**
+ * 获取设备的OSPF邻居，cisco的区域从show ip ospf interface brief中按接口获取
+ * @param device 设备的身份信息
+ * @return OSPF邻居，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetOSPFNeighbors(device Device) ([]OSPFNeighbor, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiOSPFPeerCmd},
+		H3C:    {H3cOSPFPeerCmd},
+		CISCO:  {CiscoOSPFNeighborCmd, CiscoOSPFInterfaceCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch brand {
+	case H3C:
+		return parseH3cOSPFNeighbors(outputs[H3cOSPFPeerCmd]), nil
+	case CISCO:
+		return parseCiscoOSPFNeighbors(outputs[CiscoOSPFNeighborCmd], outputs[CiscoOSPFInterfaceCmd]), nil
+	}
+	return parseHuaweiOSPFNeighbors(outputs[HuaweiOSPFPeerCmd]), nil
+}
+
+var (
+	bgpRouterIDRegexp    = regexp.MustCompile(`(?i)router (?:ID|identifier)\s*:?\s*(\d+\.\d+\.\d+\.\d+)`)
+	bgpLocalASRegexp     = regexp.MustCompile(`(?i)local AS number\s*:?\s*([\d.]+)`)
+	shortDurationRegexp  = regexp.MustCompile(`(\d+)([ywdhms])`)
+	clockDurationRegexp  = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2})$`)
+	shortDurationUnitMap = map[string]time.Duration{
+		"y": 365 * 24 * time.Hour, "w": 7 * 24 * time.Hour, "d": 24 * time.Hour,
+		"h": time.Hour, "m": time.Minute, "s": time.Second,
+	}
+)
+
+/**
+ * 解析设备输出的简短时长，如"00:05:10"、"1d02h"、"1w2d"、"0010h12m"（华为）
+ * @param text 时长的文本
+ * @return 时长，"never"或无法解析时为0
+ * @author shenbowei
+ */
+func parseShortDuration(text string) time.Duration {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if match := clockDurationRegexp.FindStringSubmatch(text); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.Atoi(match[3])
+		return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	}
+	duration := time.Duration(0)
+	for _, match := range shortDurationRegexp.FindAllStringSubmatch(text, -1) {
+		value, _ := strconv.Atoi(match[1])
+		duration += time.Duration(value) * shortDurationUnitMap[match[2]]
+	}
+	return duration
+}
+
+/**
+ * 解析AS号，支持asplain（"65546"）和asdot（"1.10"）形式
+ * @param text AS号的文本
+ * @return AS号，无法解析时为0
+ * @author shenbowei
+ */
+func parseASNumber(text string) uint32 {
+	parts := strings.SplitN(text, ".", 2)
+	high, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0
+	}
+	if len(parts) == 1 {
+		return uint32(high)
+	}
+	low, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil || high > 0xffff {
+		return 0
+	}
+	return uint32(high<<16 | low)
+}
+
+/**
+ * 规范化邻居状态：取第一个单词的小写形式，如"Established"为"established"、"Idle(Admin)"和"Idle (Admin)"为"idle"
+ * @param state 设备输出的状态
+ * @return 规范化的状态
+ * @author shenbowei
+ */
+func normalizeNeighborState(state string) string {
+	state = strings.ToLower(strings.TrimSpace(state))
+	if index := strings.IndexAny(state, " ("); index > 0 {
+		state = state[:index]
+	}
+	return state
+}
+
+/**
+ * 解析BGP对等体的汇总输出。各品牌的列顺序不同：华为为"Peer V AS MsgRcvd MsgSent OutQ Up/Down State PrefRcv"，
+ * h3c为"Peer AS MsgRcvd MsgSent OutQ PrefRcv Up/Down State"，cisco为"Neighbor V AS MsgRcvd MsgSent TblVer InQ OutQ Up/Down State/PfxRcd"，
+ * cisco的State/PfxRcd为数字时表示会话已建立
+ * @param brand 设备品牌, output display bgp peer/show ip bgp summary的输出
+ * @return BGP对等体
+ * @author shenbowei
+ */
+func parseBGPPeers(brand, output string) []BGPPeer {
+	peers := make([]BGPPeer, 0)
+	routerID, localAS := "", uint32(0)
+	for _, line := range outputLines(output) {
+		if match := bgpRouterIDRegexp.FindStringSubmatch(line); match != nil {
+			routerID = match[1]
+		}
+		if match := bgpLocalASRegexp.FindStringSubmatch(line); match != nil {
+			localAS = parseASNumber(match[1])
+		}
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		if len(fields) < 8 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		peer := BGPPeer{Address: fields[0], LocalAS: localAS, RouterID: routerID}
+		switch brand {
+		case CISCO:
+			if len(fields) < 10 {
+				continue
+			}
+			peer.RemoteAS = parseASNumber(fields[2])
+			peer.Uptime = parseShortDuration(fields[8])
+			state := strings.Join(fields[9:], " ")
+			if isDigits(state) {
+				peer.State = BGP_ESTABLISHED
+				peer.PrefixesReceived, _ = strconv.Atoi(state)
+			} else {
+				peer.State = normalizeNeighborState(state)
+			}
+		case H3C:
+			peer.RemoteAS = parseASNumber(fields[1])
+			peer.PrefixesReceived, _ = strconv.Atoi(fields[5])
+			peer.Uptime = parseShortDuration(fields[6])
+			peer.State = normalizeNeighborState(strings.Join(fields[7:], " "))
+		default:
+			if len(fields) < 9 {
+				continue
+			}
+			peer.RemoteAS = parseASNumber(fields[2])
+			peer.Uptime = parseShortDuration(fields[6])
+			peer.State = normalizeNeighborState(fields[7])
+			peer.PrefixesReceived, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+/**
+ * 拆分"Full/DR"形式的OSPF邻居状态
+ * @param text 设备输出的状态
+ * @return 规范化的状态，DR角色（没有时为""）
+ * @author shenbowei
+ */
+func splitOSPFState(text string) (string, string) {
+	parts := strings.SplitN(text, "/", 2)
+	role := ""
+	if len(parts) == 2 {
+		role = strings.TrimSpace(parts[1])
+		if role == "-" {
+			role = ""
+		}
+		if strings.EqualFold(role, "DROther") {
+			role = "DROther"
+		} else {
+			role = strings.ToUpper(role)
+		}
+	}
+	return normalizeNeighborState(parts[0]), role
+}
+
+/**
+ * 将区域转换为点分十进制形式，如cisco的"0"为"0.0.0.0"
+ * @param area 区域
+ * @return 点分十进制形式的区域
+ * @author shenbowei
+ */
+func normalizeOSPFArea(area string) string {
+	if !isDigits(area) {
+		return area
+	}
+	value, err := strconv.ParseUint(area, 10, 32)
+	if err != nil {
+		return area
+	}
+	return net.IPv4(byte(value>>24), byte(value>>16), byte(value>>8), byte(value)).String()
+}
+
+/**
+ * 解析华为的OSPF邻居（display ospf peer brief），每行为"区域 接口 邻居Router ID 状态"
+ * @param output 指令的输出
+ * @return OSPF邻居
+ * @author shenbowei
+ */
+func parseHuaweiOSPFNeighbors(output string) []OSPFNeighbor {
+	neighbors := make([]OSPFNeighbor, 0)
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) != 4 || net.ParseIP(fields[0]) == nil || net.ParseIP(fields[2]) == nil {
+			continue
+		}
+		state, role := splitOSPFState(fields[3])
+		neighbors = append(neighbors, OSPFNeighbor{RouterID: fields[2], Area: fields[0], State: state, Role: role,
+			Interface: NormalizeInterfaceName(HUAWEI, fields[1])})
+	}
+	return neighbors
+}
+
+var h3cOSPFAreaRegexp = regexp.MustCompile(`^\s*Area:\s*(\S+)`)
+
+/**
+ * 解析h3c的OSPF邻居（display ospf peer），"Area: 0.0.0.0"之后每行为"Router ID 地址 优先级 Dead-Time 状态 接口"
+ * @param output 指令的输出
+ * @return OSPF邻居
+ * @author shenbowei
+ */
+func parseH3cOSPFNeighbors(output string) []OSPFNeighbor {
+	neighbors := make([]OSPFNeighbor, 0)
+	area := ""
+	for _, line := range outputLines(output) {
+		if match := h3cOSPFAreaRegexp.FindStringSubmatch(line); match != nil {
+			area = normalizeOSPFArea(match[1])
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 6 || net.ParseIP(fields[0]) == nil || net.ParseIP(fields[1]) == nil {
+			continue
+		}
+		state, role := splitOSPFState(fields[4])
+		neighbors = append(neighbors, OSPFNeighbor{RouterID: fields[0], Address: fields[1], Area: area, State: state, Role: role,
+			Interface: NormalizeInterfaceName(H3C, fields[5])})
+	}
+	return neighbors
+}
+
+var ciscoOSPFNeighborRegexp = regexp.MustCompile(`^\s*(\d+\.\d+\.\d+\.\d+)\s+\d+\s+(\S+?(?:/\s*\S+)?)\s+\S+\s+(\d+\.\d+\.\d+\.\d+)\s+(\S+)\s*$`)
+
+/**
+ * 解析cisco的OSPF邻居（show ip ospf neighbor，IOS和NX-OS），区域从show ip ospf interface brief中按接口获取
+ * @param output show ip ospf neighbor的输出, interfaces show ip ospf interface brief的输出
+ * @return OSPF邻居
+ * @author shenbowei
+ */
+func parseCiscoOSPFNeighbors(output, interfaces string) []OSPFNeighbor {
+	areas := make(map[string]string)
+	for _, line := range outputLines(interfaces) {
+		//IOS为"Interface PID Area ..."，NX-OS为"Interface ID Area ..."
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && isDigits(fields[1]) && (isDigits(fields[2]) || net.ParseIP(fields[2]) != nil) {
+			areas[NormalizeInterfaceName(CISCO, fields[0])] = normalizeOSPFArea(fields[2])
+		}
+	}
+	neighbors := make([]OSPFNeighbor, 0)
+	for _, line := range outputLines(output) {
+		match := ciscoOSPFNeighborRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		state, role := splitOSPFState(match[2])
+		name := NormalizeInterfaceName(CISCO, match[4])
+		neighbors = append(neighbors, OSPFNeighbor{RouterID: match[1], Address: match[3], Area: areas[name], State: state, Role: role,
+			Interface: name})
+	}
+	return neighbors
+}
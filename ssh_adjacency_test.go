@@ -0,0 +1,178 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const huaweiBGPPeerSample = ` BGP local router ID : 10.0.0.1
+ Local AS number : 65001
+ Total number of peers : 3                 Peers in established state : 2
+
+  Peer            V          AS  MsgRcvd  MsgSent  OutQ  Up/Down       State  PrefRcv
+
+  10.0.0.2        4       65001     1520     1523     0 0010h12m Established        12
+  10.0.0.3        4       65002        0        0     0 00:05:10      Active         0
+  192.168.1.1     4         1.10    3310     3305     0 02d03h   Established      120
+`
+
+const h3cBGPPeerSample = ` BGP local router ID: 10.2.0.1
+ Local AS number: 65010
+ Total number of peers: 2                  Peers in established state: 1
+
+  * - Dynamically created peer
+  Peer                    AS  MsgRcvd  MsgSent OutQ PrefRcv Up/Down  State
+
+  10.2.0.2             65010      120      118    0      15 01:23:45 Established
+  10.2.0.3             65020        0        0    0       0 00:10:02 Idle
+`
+
+const ciscoBGPSummarySample = `BGP router identifier 10.3.0.1, local AS number 65020
+BGP table version is 55, main routing table version 55
+25 network entries using 3600 bytes of memory
+
+Neighbor        V           AS MsgRcvd MsgSent   TblVer  InQ OutQ Up/Down  State/PfxRcd
+10.3.0.2        4        65020    1234    1240       55    0    0 1d02h           25
+10.3.0.3        4        65030       0       0        1    0    0 never    Active
+10.3.0.4        4        65040      10      12        1    0    0 00:01:10 Idle (Admin)
+`
+
+const huaweiOSPFPeerSample = `          OSPF Process 1 with Router ID 10.0.0.1
+                  Peer Statistic Information
+ ----------------------------------------------------------------------------
+ Area Id          Interface                        Neighbor id      State
+ 0.0.0.0          Vlanif10                         10.0.0.2         Full
+ 0.0.0.1          GE0/0/1                          10.0.0.3         2-Way
+ ----------------------------------------------------------------------------
+`
+
+const h3cOSPFPeerSample = `         OSPF Process 1 with Router ID 10.2.0.1
+               Neighbor Brief Information
+
+ Area: 0.0.0.0
+ Router ID       Address         Pri Dead-Time  State             Interface
+ 10.2.0.2        10.2.1.2        1   37         Full/DR           Vlan10
+ 10.2.0.3        10.2.1.3        1   32         Full/BDR          Vlan10
+ Area: 0.0.0.1
+ Router ID       Address         Pri Dead-Time  State             Interface
+ 10.2.0.4        10.2.2.4        1   35         2-Way/DROther     Vlan20
+`
+
+const ciscoOSPFNeighborSample = `Neighbor ID     Pri   State           Dead Time   Address         Interface
+10.3.0.2          1   FULL/DR         00:00:35    10.3.1.2        Vlan10
+10.3.0.3          0   FULL/  -        00:00:33    10.3.2.3        GigabitEthernet1/0/1
+`
+
+const ciscoOSPFInterfaceSample = `Interface    PID   Area            IP Address/Mask    Cost  State Nbrs F/C
+Vl10         1     0               10.3.1.1/24        1     DR    1/1
+Gi1/0/1      1     1               10.3.2.1/30        1     P2P   1/1
+`
+
+func TestParseShortDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"00:05:10": 5*time.Minute + 10*time.Second,
+		"1d02h":    26 * time.Hour,
+		"1w2d":     9 * 24 * time.Hour,
+		"0010h12m": 10*time.Hour + 12*time.Minute,
+		"never":    0,
+	}
+	for text, expected := range cases {
+		if duration := parseShortDuration(text); duration != expected {
+			t.Errorf("parseShortDuration(%q) = %s, expected %s", text, duration, expected)
+		}
+	}
+}
+
+func TestParseBGPPeers(t *testing.T) {
+	peers := parseBGPPeers(HUAWEI, huaweiBGPPeerSample)
+	if len(peers) != 3 {
+		t.Fatalf("huawei peers:%+v", peers)
+	}
+	if peers[0] != (BGPPeer{Address: "10.0.0.2", RemoteAS: 65001, LocalAS: 65001, RouterID: "10.0.0.1", State: BGP_ESTABLISHED,
+		Uptime: 10*time.Hour + 12*time.Minute, PrefixesReceived: 12}) {
+		t.Errorf("huawei peer 0:%+v", peers[0])
+	}
+	if peers[1].State != "active" || peers[2].RemoteAS != 65546 || peers[2].PrefixesReceived != 120 {
+		t.Errorf("huawei peers:%+v", peers)
+	}
+
+	peers = parseBGPPeers(H3C, h3cBGPPeerSample)
+	if len(peers) != 2 {
+		t.Fatalf("h3c peers:%+v", peers)
+	}
+	if peers[0] != (BGPPeer{Address: "10.2.0.2", RemoteAS: 65010, LocalAS: 65010, RouterID: "10.2.0.1", State: BGP_ESTABLISHED,
+		Uptime: time.Hour + 23*time.Minute + 45*time.Second, PrefixesReceived: 15}) {
+		t.Errorf("h3c peer 0:%+v", peers[0])
+	}
+	if peers[1].State != "idle" || peers[1].RemoteAS != 65020 {
+		t.Errorf("h3c peer 1:%+v", peers[1])
+	}
+
+	peers = parseBGPPeers(CISCO, ciscoBGPSummarySample)
+	if len(peers) != 3 {
+		t.Fatalf("cisco peers:%+v", peers)
+	}
+	if peers[0] != (BGPPeer{Address: "10.3.0.2", RemoteAS: 65020, LocalAS: 65020, RouterID: "10.3.0.1", State: BGP_ESTABLISHED,
+		Uptime: 26 * time.Hour, PrefixesReceived: 25}) {
+		t.Errorf("cisco peer 0:%+v", peers[0])
+	}
+	if peers[1].State != "active" || peers[1].Uptime != 0 || peers[2].State != "idle" {
+		t.Errorf("cisco peers:%+v", peers)
+	}
+}
+
+func TestParseOSPFNeighbors(t *testing.T) {
+	neighbors := parseHuaweiOSPFNeighbors(huaweiOSPFPeerSample)
+	if len(neighbors) != 2 {
+		t.Fatalf("huawei neighbors:%+v", neighbors)
+	}
+	if neighbors[0] != (OSPFNeighbor{RouterID: "10.0.0.2", Area: "0.0.0.0", State: OSPF_FULL, Interface: "Vlanif10"}) ||
+		neighbors[1].State != "2-way" || neighbors[1].Interface != "GigabitEthernet0/0/1" {
+		t.Errorf("huawei neighbors:%+v", neighbors)
+	}
+
+	neighbors = parseH3cOSPFNeighbors(h3cOSPFPeerSample)
+	if len(neighbors) != 3 {
+		t.Fatalf("h3c neighbors:%+v", neighbors)
+	}
+	if neighbors[0] != (OSPFNeighbor{RouterID: "10.2.0.2", Address: "10.2.1.2", Area: "0.0.0.0", State: OSPF_FULL, Role: "DR",
+		Interface: "Vlan-interface10"}) {
+		t.Errorf("h3c neighbor 0:%+v", neighbors[0])
+	}
+	if neighbors[2].Area != "0.0.0.1" || neighbors[2].State != "2-way" || neighbors[2].Role != "DROther" {
+		t.Errorf("h3c neighbor 2:%+v", neighbors[2])
+	}
+
+	neighbors = parseCiscoOSPFNeighbors(ciscoOSPFNeighborSample, ciscoOSPFInterfaceSample)
+	if len(neighbors) != 2 {
+		t.Fatalf("cisco neighbors:%+v", neighbors)
+	}
+	if neighbors[0] != (OSPFNeighbor{RouterID: "10.3.0.2", Address: "10.3.1.2", Area: "0.0.0.0", State: OSPF_FULL, Role: "DR",
+		Interface: "Vlan10"}) {
+		t.Errorf("cisco neighbor 0:%+v", neighbors[0])
+	}
+	if neighbors[1].Role != "" || neighbors[1].Area != "0.0.0.1" || neighbors[1].Interface != "GigabitEthernet1/0/1" {
+		t.Errorf("cisco neighbor 1:%+v", neighbors[1])
+	}
+}
+
+func TestSessionManagerGetAdjacencies(t *testing.T) {
+	fake := newFakeSwitch(t, "R1#", map[string]string{
+		CiscoBGPSummaryCmd:    ciscoBGPSummarySample,
+		CiscoOSPFNeighborCmd:  ciscoOSPFNeighborSample,
+		CiscoOSPFInterfaceCmd: ciscoOSPFInterfaceSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+
+	peers, err := manager.GetBGPPeers(device)
+	if err != nil || len(peers) != 3 || peers[0].PrefixesReceived != 25 {
+		t.Errorf("GetBGPPeers peers:%+v err:%v", peers, err)
+	}
+	neighbors, err := manager.GetOSPFNeighbors(device)
+	if err != nil || len(neighbors) != 2 || neighbors[1].Area != "0.0.0.1" {
+		t.Errorf("GetOSPFNeighbors neighbors:%+v err:%v", neighbors, err)
+	}
+}
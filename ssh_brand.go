@@ -0,0 +1,220 @@
+package ssh
+
+import (
+	"strings"
+)
+
+// 识别设备品牌时使用的依据
+const (
+	BRAND_SOURCE_DEVICE         = "device"         //Device.Brand指定，或者同一设备的其他shell已经识别
+	BRAND_SOURCE_SERVER_VERSION = "server-version" //ssh服务端的版本号，如"SSH-2.0-HUAWEI-1.5"、"SSH-2.0-Cisco-1.25"
+	BRAND_SOURCE_BANNER         = "banner"         //登录前的banner和登录后的MOTD
+	BRAND_SOURCE_PROMPT         = "prompt"         //提示符的形式，"<...>"、"[...]"为华为/h3c，"...#"为cisco
+	BRAND_SOURCE_COMMAND        = "command"        //执行版本指令的输出
+)
+
+// 不执行指令识别品牌时要求的最低可信度，低于该值时执行版本指令
+const minBrandConfidence = 0.4
+
+/**
+ * 品牌识别的结果
+ * @attr Brand:品牌（huawei,h3c,cisco，无法识别时为""），Confidence:可信度（0~1，为该品牌与其他品牌得分的差值），
+ *       Source:得分最高的识别依据（BRAND_SOURCE_*）
+ * @author shenbowei
+ */
+type BrandDetection struct {
+	Brand      string
+	Confidence float64
+	Source     string
+}
+
+/**
+ * 品牌识别的一条依据
+ * @attr keywords:出现任一关键字即认为命中（小写），brands:命中时支持的品牌，weight:命中时的权重
+ * @author shenbowei
+ */
+type brandEvidence struct {
+	keywords []string
+	brands   []string
+	weight   float64
+}
+
+// ssh服务端版本号中的关键字，h3c的Comware系统版本号形如"SSH-2.0-Comware-7.1.064"
+var serverVersionEvidences = []brandEvidence{
+	{[]string{"huawei"}, []string{HUAWEI}, 0.9},
+	{[]string{"h3c", "comware"}, []string{H3C}, 0.9},
+	{[]string{"cisco"}, []string{CISCO}, 0.9},
+}
+
+// banner和MOTD中的关键字
+var bannerEvidences = []brandEvidence{
+	{[]string{"huawei"}, []string{HUAWEI}, 0.7},
+	{[]string{"h3c", "comware"}, []string{H3C}, 0.7},
+	{[]string{"cisco"}, []string{CISCO}, 0.7},
+}
+
+/**
+ * 根据ssh服务端版本号、banner（含MOTD）和提示符识别设备品牌，不执行任何指令。
+ * 每个品牌的得分为命中依据的权重按1-∏(1-weight)合并，可信度为最高得分与次高得分的差值
+ * @param serverVersion ssh服务端版本号（telnet等为""）, banner 登录前的banner和登录后的MOTD, prompt 设备的提示符
+ * @return 识别结果，没有任何依据时Brand为""
+ * @author shenbowei
+ */
+func detectBrand(serverVersion, banner, prompt string) BrandDetection {
+	scores := map[string]float64{}
+	sources := map[string]string{}
+	weights := map[string]float64{}
+	addEvidence := func(brands []string, weight float64, source string) {
+		for _, brand := range brands {
+			scores[brand] = 1 - (1-scores[brand])*(1-weight)
+			if weight > weights[brand] {
+				weights[brand] = weight
+				sources[brand] = source
+			}
+		}
+	}
+	for _, evidence := range serverVersionEvidences {
+		if containsAny(strings.ToLower(serverVersion), evidence.keywords) {
+			addEvidence(evidence.brands, evidence.weight, BRAND_SOURCE_SERVER_VERSION)
+		}
+	}
+	for _, evidence := range bannerEvidences {
+		if containsAny(strings.ToLower(banner), evidence.keywords) {
+			addEvidence(evidence.brands, evidence.weight, BRAND_SOURCE_BANNER)
+		}
+	}
+	if brands, weight := brandsFromPrompt(prompt); len(brands) > 0 {
+		addEvidence(brands, weight, BRAND_SOURCE_PROMPT)
+	}
+
+	detection := BrandDetection{}
+	second := 0.0
+	for _, brand := range []string{HUAWEI, H3C, CISCO} {
+		score := scores[brand]
+		if score > detection.Confidence {
+			second = detection.Confidence
+			detection = BrandDetection{Brand: brand, Confidence: score, Source: sources[brand]}
+		} else if score > second {
+			second = score
+		}
+	}
+	detection.Confidence -= second
+	if detection.Confidence <= 0 {
+		return BrandDetection{}
+	}
+	return detection
+}
+
+/**
+ * 根据提示符的形式推断可能的品牌：华为/h3c的提示符为"<...>"或"[...]"，无法区分两者；cisco为"...>"或"...#"
+ * @param prompt 设备的提示符
+ * @return 可能的品牌，权重
+ * @author shenbowei
+ */
+func brandsFromPrompt(prompt string) ([]string, float64) {
+	switch {
+	case strings.HasPrefix(prompt, "<") && strings.HasSuffix(prompt, ">"),
+		strings.HasPrefix(prompt, "[") && strings.HasSuffix(prompt, "]"):
+		return []string{HUAWEI, H3C}, 0.3
+	case strings.HasSuffix(prompt, "#") || strings.HasSuffix(prompt, ">"):
+		return []string{CISCO}, 0.35
+	}
+	return nil, 0
+}
+
+/**
+ * 根据版本指令的输出识别品牌
+ * @param output 版本指令的输出
+ * @return 品牌（huawei,h3c,cisco），无法识别时返回""
+ * @author shenbowei
+ */
+func brandFromVersion(output string) string {
+	output = strings.ToLower(output)
+	for _, brand := range []string{HUAWEI, H3C, CISCO} {
+		if strings.Contains(output, brand) {
+			return brand
+		}
+	}
+	return ""
+}
+
+/**
+ * 判断文本中是否包含任一关键字
+ * @param text 文本, keywords 关键字
+ * @return true:包含
+ * @author shenbowei
+ */
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 识别当前会话设备的品牌：先根据ssh服务端版本号、banner和提示符识别，可信度不足时才执行版本指令
+ * （提示符为华为/h3c形式时只执行display version，为cisco形式时只执行show version），结果会被缓存
+ * @return 识别结果
+ * @author shenbowei
+ */
+func (this *shellSession) DetectBrand() BrandDetection {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("SSHSession DetectBrand err:%s", err)
+		}
+	}()
+	if this.detection.Brand != "" {
+		return this.detection
+	}
+	if this.brand != "" {
+		return BrandDetection{Brand: this.brand, Confidence: 1, Source: BRAND_SOURCE_DEVICE}
+	}
+	prompt := this.prompt
+	if prompt == "" {
+		prompt = this.GetPrompt()
+	}
+	detection := detectBrand(this.serverVersion, this.banner+"\n"+this.motd, prompt)
+	if detection.Confidence < minBrandConfidence {
+		//显示版本后需要多一组空格，避免版本信息过多需要分页，导致分页指令第一个字符失效的问题
+		cmds := []string{"dis version", "     ", "show version", "     "}
+		if brands, _ := brandsFromPrompt(prompt); len(brands) > 0 && brands[0] == CISCO {
+			cmds = cmds[2:]
+		} else if len(brands) > 0 {
+			cmds = cmds[:2]
+		}
+		this.WriteChannel(cmds...)
+		detection = BrandDetection{Confidence: 1, Source: BRAND_SOURCE_COMMAND}
+		detection.Brand = brandFromVersion(this.ReadChannelTiming(this.config.promptTimeout))
+		if detection.Brand == "" {
+			return BrandDetection{}
+		}
+	}
+	this.logger.Debug("The switch brand is <%s> (%s, confidence %.2f).", detection.Brand, detection.Source, detection.Confidence)
+	this.setDetection(detection)
+	return detection
+}
+
+/**
+ * 设置识别结果，同时更新会话的品牌
+ * @param detection 识别结果
+ * @author shenbowei
+ */
+func (this *shellSession) setDetection(detection BrandDetection) {
+	this.detection = detection
+	this.brand = detection.Brand
+}
+
+/**
+ * 获取Device.Brand指定的品牌对应的识别结果
+ * @param device 设备的身份信息
+ * @return 识别结果，Brand为空时返回空的结果
+ * @author shenbowei
+ */
+func deviceDetection(device Device) BrandDetection {
+	if device.Brand == "" {
+		return BrandDetection{}
+	}
+	return BrandDetection{Brand: device.Brand, Confidence: 1, Source: BRAND_SOURCE_DEVICE}
+}
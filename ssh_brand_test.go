@@ -0,0 +1,59 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetectBrand(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverVersion string
+		banner        string
+		prompt        string
+		brand         string
+		source        string
+	}{
+		{"huawei server version", "SSH-2.0-HUAWEI-1.5", "", "<Switch>", HUAWEI, BRAND_SOURCE_SERVER_VERSION},
+		{"h3c server version", "SSH-2.0-Comware-7.1.064", "", "<Switch>", H3C, BRAND_SOURCE_SERVER_VERSION},
+		{"cisco server version", "SSH-2.0-Cisco-1.25", "", "Switch#", CISCO, BRAND_SOURCE_SERVER_VERSION},
+		{"huawei banner", "SSH-2.0--", "Huawei Integrated Access Software", "<Switch>", HUAWEI, BRAND_SOURCE_BANNER},
+		{"h3c banner", "", "Copyright (c) 2004-2026 New H3C Technologies Co., Ltd.", "[Switch]", H3C, BRAND_SOURCE_BANNER},
+		{"conflicting banner", "", "H3C switch, managed by the Huawei NMS", "<Switch>", "", ""},
+		{"bracket prompt only", "SSH-2.0--", "Authorized users only!", "<Switch>", "", ""},
+		{"nothing", "", "", "", "", ""},
+	}
+	for _, test := range tests {
+		detection := detectBrand(test.serverVersion, test.banner, test.prompt)
+		if detection.Brand != test.brand || detection.Source != test.source {
+			t.Errorf("<%s> detectBrand=%+v, expected brand %q from %q", test.name, detection, test.brand, test.source)
+		}
+		if test.brand != "" && detection.Confidence < minBrandConfidence {
+			t.Errorf("<%s> confidence %.2f is below %.2f", test.name, detection.Confidence, minBrandConfidence)
+		}
+	}
+	//cisco提示符单独不足以确定品牌
+	if detection := detectBrand("", "", "Switch#"); detection.Brand != CISCO || detection.Confidence >= minBrandConfidence {
+		t.Errorf("detectBrand from cisco prompt=%+v, expected a low confidence cisco", detection)
+	}
+}
+
+func TestSessionManagerDetectBrandByVersionCommand(t *testing.T) {
+	fake := newFakeSwitch(t, "<Switch>", map[string]string{"dis version": "Huawei Versatile Routing Platform Software"})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	detection, err := manager.DetectDeviceBrand(NewDevice("admin", "admin", fake.addr(), ""))
+	if err != nil {
+		t.Fatalf("DetectDeviceBrand err:%s", err)
+	}
+	if detection.Brand != HUAWEI || detection.Source != BRAND_SOURCE_COMMAND {
+		t.Errorf("unexpected detection:%+v", detection)
+	}
+	//提示符为华为/h3c的形式，不应再执行cisco的版本指令
+	for _, cmd := range fake.getCommands() {
+		if cmd == "show version" {
+			t.Errorf("show version is sent to a device with a huawei prompt")
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 连接池通过控制台服务器连接设备时使用的传输方式
+const consoleTransport = "console"
+
+// 分页提示，如华为/h3c的"---- More ----"，cisco的"--More--"，juniper的"---(more 45%)---"
+var consolePagerRegexp = regexp.MustCompile(`(?i)-{2,}\s*\(?more\b[^-]*-{2,}`)
+
+/**
+ * 控制台服务器（终端服务器）上设备所在线路的连接信息，线路端口提供的是设备的原始登录提示而不是已认证的shell
+ * @attr Host:控制台服务器的ip或域名，Port:线路的反向ssh/telnet端口，Transport:连接线路的方式（SSH_TRANSPORT或TELNET_TRANSPORT，为空时使用ssh），
+ *       User:控制台服务器的用户名（ssh时使用，部分控制台服务器形如"admin:port2"），Credential:控制台服务器的登录凭证（ssh时使用Password）
+ * @author shenbowei
+ */
+type ConsoleServer struct {
+	Host       string
+	Port       int
+	Transport  string
+	User       string
+	Credential *Credential
+}
+
+/**
+ * 获取线路的连接地址
+ * @return host:port
+ * @author shenbowei
+ */
+func (this ConsoleServer) Address() string {
+	return net.JoinHostPort(this.Host, strconv.Itoa(this.Port))
+}
+
+/**
+ * 获取线路的索引键值，形如"user@host:port"
+ * @return 索引键值
+ * @author shenbowei
+ */
+func (this ConsoleServer) key() string {
+	return url.PathEscape(this.User) + "@" + this.Address()
+}
+
+/**
+ * 通过控制台服务器登录的设备会话，关闭时会先退出设备的登录，避免控制台线路保持已登录的状态
+ * @attr Session:连接线路的会话（SSHSession或TelnetSession）
+ * @author shenbowei
+ */
+type ConsoleSession struct {
+	Session
+	logoutOnce sync.Once
+}
+
+/**
+ * 连接控制台服务器的线路并登录设备
+ * @param console 控制台服务器的线路, user 设备的用户名, password 设备的密码
+ * @return 处于设备基础提示符（非配置模式）的ConsoleSession，执行的错误
+ * @author shenbowei
+ */
+func NewConsoleSession(console ConsoleServer, user, password string) (*ConsoleSession, error) {
+	return newConsoleSession(nil, console, user, password, newSessionConfig())
+}
+
+/**
+ * 使用指定的配置连接控制台服务器的线路并登录设备
+ * @param dialer 建立tcp连接的Dialer（为nil时使用配置的Dialer）, console 控制台服务器的线路, user 设备的用户名, password 设备的密码, config session的配置
+ * @return 处于设备基础提示符的ConsoleSession，执行的错误
+ * @author shenbowei
+ */
+func newConsoleSession(dialer Dialer, console ConsoleServer, user, password string, config *sessionConfig) (*ConsoleSession, error) {
+	var session Session
+	if console.Transport == TELNET_TRANSPORT {
+		telnetSession, err := dialTelnetSession(dialer, console.Address(), config)
+		if err != nil {
+			return nil, err
+		}
+		session = telnetSession
+	} else {
+		consolePassword := ""
+		if console.Credential != nil {
+			consolePassword = console.Credential.Password
+		}
+		//控制台服务器的banner不属于设备，不记录
+		client, _, err := dialClientThrough(dialer, console.Address(), config.clientConfig(console.User, consolePassword), config)
+		if err != nil {
+			return nil, err
+		}
+		sshSession, err := newSSHSessionOnClient(client, config, true)
+		if err != nil {
+			return nil, err
+		}
+		//服务端版本号属于控制台服务器，不能用于识别设备品牌
+		sshSession.serverVersion = ""
+		session = sshSession
+	}
+	if err := session.shell().consoleLogin(user, password); err != nil {
+		config.logger.Error("Console<%s> login error:%s", console.key(), err.Error())
+		session.Close()
+		return nil, err
+	}
+	session.UpdateLastUseTime()
+	return &ConsoleSession{Session: session}, nil
+}
+
+/**
+ * 在控制台线路上登录设备：先发送换行唤醒线路，应答Username/Password提示；线路已经处于登录状态时直接使用，
+ * 处于配置模式时退回到基础提示符，处于未知状态（分页、未完成的输入等）时发送Ctrl+C后重新唤醒
+ * @param user 设备的用户名, password 设备的密码
+ * @return 执行的错误（用户名或密码被再次询问时返回ErrLoginFailed，需要修改密码时返回ErrPasswordChangeRequired）
+ * @author shenbowei
+ */
+func (this *shellSession) consoleLogin(user, password string) error {
+	state := new(loginState)
+	output := ""
+	this.ClearChannel()
+	this.WriteChannel("")
+	for i := 0; i < maxLoginPrompts; i++ {
+		output = this.ReadChannelExpect(this.config.promptTimeout, loginExpects...)
+		prompt := lastPromptLine(output)
+		if handled, err := this.answerLogin(state, prompt, user, password); handled {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		switch {
+		case parsePrivilegeMode(prompt) == CONFIG_MODE:
+			this.logger.Debug("Console is in config mode<%s>, return to the base prompt", prompt)
+			this.WriteChannel(returnCommand(prompt))
+		case parsePrivilegeMode(prompt) != UNKNOWN_MODE:
+			this.prompt = prompt
+			return nil
+		case consolePagerRegexp.MatchString(prompt):
+			//停在分页中，退出分页
+			this.WriteChannel("q")
+		default:
+			//线路没有输出或处于未知状态，中断当前的输入后重新唤醒
+			this.WriteChannel("\x03")
+		}
+	}
+	return errors.New("ssh: console login timeout, last output:" + lastPromptLine(output))
+}
+
+/**
+ * 获取从配置模式退回到基础提示符的指令
+ * @param prompt 配置模式的提示符
+ * @return 华为/h3c（"[...]"）为return，cisco为end
+ * @author shenbowei
+ */
+func returnCommand(prompt string) string {
+	if strings.HasPrefix(prompt, "[") {
+		return "return"
+	}
+	return "end"
+}
+
+/**
+ * 退出设备的登录后关闭到控制台服务器的连接，可重复调用
+ * @author shenbowei
+ */
+func (this *ConsoleSession) Close() {
+	this.logoutOnce.Do(func() {
+		if !this.IsClosed() {
+			this.logout()
+		}
+	})
+	this.Session.Close()
+}
+
+/**
+ * 退回到基础提示符并退出设备的登录（华为/h3c执行quit，cisco执行exit）
+ * @author shenbowei
+ */
+func (this *ConsoleSession) logout() {
+	this.ClearChannel()
+	this.WriteChannel("")
+	prompt := lastPromptLine(this.ReadChannelExpect(this.shell().config.promptTimeout, "#", ">", "]"))
+	if parsePrivilegeMode(prompt) == CONFIG_MODE {
+		this.WriteChannel(returnCommand(prompt))
+		prompt = lastPromptLine(this.ReadChannelExpect(this.shell().config.promptTimeout, "#", ">", "]"))
+	}
+	if strings.HasPrefix(prompt, "<") {
+		this.WriteChannel("quit")
+	} else {
+		this.WriteChannel("exit")
+	}
+	this.ReadChannelExpect(this.shell().config.promptTimeout, "sername:", "ogin:", "assword:", "RETURN")
+}
@@ -0,0 +1,187 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+ * 测试使用的模拟控制台线路，线路的登录状态在多次连接之间保持，只在收到输入后才有输出
+ */
+type fakeConsoleLine struct {
+	listener  net.Listener
+	password  string
+	outputs   map[string]string
+	locker    sync.Mutex
+	state     string
+	commands  []string
+	waitGroup sync.WaitGroup
+}
+
+func newFakeConsoleLine(t *testing.T, password, state string, outputs map[string]string) *fakeConsoleLine {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	line := &fakeConsoleLine{listener: listener, password: password, state: state, outputs: outputs}
+	line.waitGroup.Add(1)
+	go line.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		line.waitGroup.Wait()
+	})
+	return line
+}
+
+func (this *fakeConsoleLine) console() ConsoleServer {
+	addr := this.listener.Addr().(*net.TCPAddr)
+	return ConsoleServer{Host: addr.IP.String(), Port: addr.Port, Transport: TELNET_TRANSPORT}
+}
+
+func (this *fakeConsoleLine) getState() string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.state
+}
+
+func (this *fakeConsoleLine) getCommands() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string(nil), this.commands...)
+}
+
+func (this *fakeConsoleLine) serve() {
+	defer this.waitGroup.Done()
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		this.waitGroup.Add(1)
+		go func() {
+			defer this.waitGroup.Done()
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				conn.Write([]byte(this.handle(strings.Trim(line, "\r\n\x03 "))))
+			}
+		}()
+	}
+}
+
+func (this *fakeConsoleLine) handle(cmd string) string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	switch this.state {
+	case "login":
+		this.state = "user"
+		return "\r\nUser Access Verification\r\n\r\nUsername:"
+	case "user":
+		this.state = "password"
+		return cmd + "\r\nPassword:"
+	case "password":
+		if cmd != this.password {
+			this.state = "user"
+			return "\r\n% Login invalid\r\n\r\nUsername:"
+		}
+		this.state = "exec"
+		return "\r\nSwitch#"
+	case "more":
+		//停在分页中，q退出分页，其他输入显示下一页
+		this.commands = append(this.commands, cmd)
+		if cmd == "q" {
+			this.state = "exec"
+			return "\r\nSwitch#"
+		}
+		return "\r\ninterface GigabitEthernet1/0/2\r\n --More-- "
+	case "config":
+		this.commands = append(this.commands, cmd)
+		if cmd == "end" {
+			this.state = "exec"
+			return cmd + "\r\nSwitch#"
+		}
+		return cmd + "\r\nSwitch(config-if)#"
+	}
+	this.commands = append(this.commands, cmd)
+	if cmd == "exit" {
+		this.state = "login"
+		return cmd + "\r\n\r\nSwitch con0 is now available\r\n\r\nPress RETURN to get started.\r\n"
+	}
+	output := cmd + "\r\n"
+	if cmd != "" {
+		output += this.outputs[cmd] + "\r\n"
+	}
+	return output + "Switch#"
+}
+
+func TestSessionManagerConsole(t *testing.T) {
+	for _, state := range []string{"login", "config", "more"} {
+		line := newFakeConsoleLine(t, "admin", state, map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+		manager := newTestSessionManager(WithMaxShellsPerDevice(4))
+		device := Device{Host: "switch-1", User: "admin", Credential: &Credential{Password: "admin"}, Brand: CISCO}
+		console := line.console()
+		device.Console = &console
+
+		result, err := manager.RunDeviceCommands(device, "show clock")
+		if err != nil {
+			t.Fatalf("<%s> RunDeviceCommands err:%s", state, err)
+		}
+		if !strings.Contains(result, "10:00:00.000 UTC Sun Oct 18 2026") {
+			t.Errorf("<%s> unexpected result:%q", state, result)
+		}
+		if stats, _ := manager.GetPoolStats(device); stats.MaxShells != 1 {
+			t.Errorf("<%s> console MaxShells=%d, expected 1", state, stats.MaxShells)
+		}
+		commands := strings.Join(line.getCommands(), ",")
+		if state == "config" && !strings.Contains(commands, "end") {
+			t.Errorf("<%s> console is not returned to the base prompt, commands:%s", state, commands)
+		}
+		if state == "more" && !strings.HasPrefix(commands, ",q,") {
+			t.Errorf("<%s> console pager is not quit, commands:%s", state, commands)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := manager.Shutdown(ctx); err != nil {
+			t.Errorf("<%s> Shutdown err:%s", state, err)
+		}
+		cancel()
+		if line.getState() != "login" {
+			t.Errorf("<%s> console is still logged in after close, state=%s", state, line.getState())
+		}
+	}
+}
+
+func TestConsoleLoginFailed(t *testing.T) {
+	line := newFakeConsoleLine(t, "admin", "login", nil)
+	config := newSessionConfig()
+	config.promptTimeout = 100 * time.Millisecond
+	config.logger = quietLogger{}
+	if _, err := newConsoleSession(nil, line.console(), "admin", "wrong", config); err != ErrLoginFailed {
+		t.Errorf("newConsoleSession err=%v, expected ErrLoginFailed", err)
+	}
+}
+
+func TestConsolePagerRegexp(t *testing.T) {
+	cases := map[string]bool{
+		"  ---- More ----":               true,
+		" --More-- ":                     true,
+		"-- More --":                     true,
+		"---(more 45%)---":               true,
+		"Press any key for more options": false,
+		"more-sw1":                       false,
+		"Username:":                      false,
+	}
+	for prompt, expected := range cases {
+		if matched := consolePagerRegexp.MatchString(prompt); matched != expected {
+			t.Errorf("consolePagerRegexp.MatchString(%q) = %v, expected %v", prompt, matched, expected)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package ssh
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 接口的流量和错误计数
+ * @attr Name:规范化后的接口名，InOctets/OutOctets:收发字节数，InPackets/OutPackets:收发报文数，InErrors/OutErrors:收发错误数，
+ *       InDiscards/OutDiscards:收发丢弃数，CRCErrors:CRC错误数（设备未显示的计数为0）
+ * @author shenbowei
+ */
+type InterfaceCounters struct {
+	Name        string
+	InOctets    uint64
+	OutOctets   uint64
+	InPackets   uint64
+	OutPackets  uint64
+	InErrors    uint64
+	OutErrors   uint64
+	InDiscards  uint64
+	OutDiscards uint64
+	CRCErrors   uint64
+}
+
+/**
+ * 获取各品牌设备接口计数时执行的指令
+ * @return 品牌对应的指令
+ * @author shenbowei
+ */
+func counterCommands() map[string][]string {
+	return map[string][]string{
+		HUAWEI: {HuaweiInterfaceCmd},
+		H3C:    {HuaweiInterfaceCmd},
+		CISCO:  {CiscoInterfaceCmd},
+	}
+}
+
+/**
+ * 获取设备所有接口的流量和错误计数（display interface/show interfaces）
+ * @param device 设备的身份信息
+ * @return 接口计数（按设备输出的顺序），执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetInterfaceCounters(device Device) ([]InterfaceCounters, error) {
+	brand, outputs, err := this.runBrandCommands(device, counterCommands())
+	if err != nil {
+		return nil, err
+	}
+	output := outputs[HuaweiInterfaceCmd]
+	if brand == CISCO {
+		output = outputs[CiscoInterfaceCmd]
+	}
+	return parseInterfaceCounters(brand, output), nil
+}
+
+var (
+	packetsBytesRegexp    = regexp.MustCompile(`(\d+)\s+packets(?: input| output)?,\s*(\d+)\s+bytes`)
+	counterValueRegexp    = regexp.MustCompile(`(?i)([a-z][a-z ]*?)\s*:\s*(\d+)`)
+	counterCountRegexp    = regexp.MustCompile(`(?i)(\d+)\s+([a-z][a-z -]*[a-z])`)
+	ciscoInputQueueRegexp = regexp.MustCompile(`Input queue:\s*\d+/\d+/(\d+)/\d+`)
+)
+
+/**
+ * 解析display interface/show interfaces输出中的接口计数。华为为"Discard:  2,  Total Error:  5"的形式，
+ * h3c和cisco为"5 input errors, 3 CRC"的形式，计数所属的方向由所在的Input/Output段落决定
+ * @param brand 设备品牌, output 指令的输出
+ * @return 接口计数
+ * @author shenbowei
+ */
+func parseInterfaceCounters(brand, output string) []InterfaceCounters {
+	counters := make([]InterfaceCounters, 0)
+	for _, block := range splitInterfaceBlocks(brand, output) {
+		counter := InterfaceCounters{Name: NormalizeInterfaceName(brand, block.name)}
+		input := true
+		for _, line := range block.lines {
+			lower := strings.ToLower(line)
+			if strings.HasPrefix(lower, "input") {
+				input = true
+			} else if strings.HasPrefix(lower, "output") {
+				input = false
+			}
+			if match := packetsBytesRegexp.FindStringSubmatch(line); match != nil {
+				packets, _ := strconv.ParseUint(match[1], 10, 64)
+				bytes, _ := strconv.ParseUint(match[2], 10, 64)
+				//cisco的方向在报文数之后（"packets output"），h3c的Input (total)在Input (normal)之前，只取第一次出现的值
+				input = !strings.Contains(lower, "output")
+				if input && counter.InPackets == 0 && counter.InOctets == 0 {
+					counter.InPackets, counter.InOctets = packets, bytes
+				}
+				if !input && counter.OutPackets == 0 && counter.OutOctets == 0 {
+					counter.OutPackets, counter.OutOctets = packets, bytes
+				}
+				continue
+			}
+			if match := ciscoInputQueueRegexp.FindStringSubmatch(line); match != nil {
+				counter.InDiscards, _ = strconv.ParseUint(match[1], 10, 64)
+			}
+			for _, match := range counterValueRegexp.FindAllStringSubmatch(line, -1) {
+				setCounter(&counter, input, match[1], match[2])
+			}
+			for _, match := range counterCountRegexp.FindAllStringSubmatch(line, -1) {
+				setCounter(&counter, input, match[2], match[1])
+			}
+		}
+		counters = append(counters, counter)
+	}
+	return counters
+}
+
+/**
+ * 按计数的名称设置接口计数
+ * @param counter 接口计数, input 是否处于Input段落, name 计数的名称, value 计数的值
+ * @author shenbowei
+ */
+func setCounter(counter *InterfaceCounters, input bool, name, value string) {
+	count, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "crc":
+		counter.CRCErrors = count
+	case "input errors":
+		counter.InErrors = count
+	case "output errors":
+		counter.OutErrors = count
+	case "total output drops":
+		counter.OutDiscards = count
+	case "total error":
+		if input {
+			counter.InErrors = count
+		} else {
+			counter.OutErrors = count
+		}
+	case "discard", "discards", "drops":
+		if input {
+			counter.InDiscards = count
+		} else {
+			counter.OutDiscards = count
+		}
+	}
+}
+
+/**
+ * 两次采样之间接口计数的变化
+ * @attr Name:接口名，Interval:两次采样的时间间隔，Delta:各计数的增量，Rate:各计数每秒的速率，
+ *       Reset:计数是否被清零（设备重启或clear counters），清零时增量为本次采样的值（32位计数的回绕不认为是清零）
+ * @author shenbowei
+ */
+type CounterDelta struct {
+	Name     string
+	Interval time.Duration
+	Delta    InterfaceCounters
+	Rate     CounterRate
+	Reset    bool
+}
+
+/**
+ * 接口计数每秒的速率
+ * @author shenbowei
+ */
+type CounterRate struct {
+	InOctets    float64
+	OutOctets   float64
+	InPackets   float64
+	OutPackets  float64
+	InErrors    float64
+	OutErrors   float64
+	InDiscards  float64
+	OutDiscards float64
+	CRCErrors   float64
+}
+
+/**
+ * 一次采样的接口计数
+ * @attr time:采样时间，counters:接口名对应的计数
+ * @author shenbowei
+ */
+type counterSample struct {
+	time     time.Time
+	counters map[string]InterfaceCounters
+}
+
+/**
+ * 保存每台设备上一次的接口计数，计算两次采样之间的增量和速率，可以在多个协程中使用
+ * @attr samples:设备索引键值（Device.Key）对应的上一次采样
+ * @author shenbowei
+ */
+type CounterTracker struct {
+	samples map[string]counterSample
+	locker  sync.Mutex
+}
+
+/**
+ * 创建CounterTracker
+ * @return CounterTracker
+ * @author shenbowei
+ */
+func NewCounterTracker() *CounterTracker {
+	return &CounterTracker{samples: make(map[string]counterSample)}
+}
+
+/**
+ * 从设备获取接口计数，并计算与上一次采样之间的增量和速率
+ * @param manager 获取计数的SessionManager（为nil时使用DefaultSessionManager）, device 设备的身份信息
+ * @return 各接口的变化（第一次采样或新出现的接口没有变化），执行的错误
+ * @author shenbowei
+ */
+func (this *CounterTracker) Poll(manager *SessionManager, device Device) ([]CounterDelta, error) {
+	if manager == nil {
+		manager = DefaultSessionManager
+	}
+	counters, err := manager.GetInterfaceCounters(device)
+	if err != nil {
+		return nil, err
+	}
+	return this.Update(device.Key(), time.Now(), counters), nil
+}
+
+/**
+ * 保存一次采样，并计算与该设备上一次采样之间的增量和速率
+ * @param key 设备的索引键值, at 采样时间, counters 接口计数
+ * @return 各接口的变化（第一次采样、新出现的接口或采样时间没有前进时没有变化）
+ * @author shenbowei
+ */
+func (this *CounterTracker) Update(key string, at time.Time, counters []InterfaceCounters) []CounterDelta {
+	sample := counterSample{time: at, counters: make(map[string]InterfaceCounters, len(counters))}
+	for _, counter := range counters {
+		sample.counters[counter.Name] = counter
+	}
+	this.locker.Lock()
+	//通过结构体字面量创建时samples为nil
+	if this.samples == nil {
+		this.samples = make(map[string]counterSample)
+	}
+	previous, ok := this.samples[key]
+	this.samples[key] = sample
+	this.locker.Unlock()
+
+	deltas := make([]CounterDelta, 0)
+	if !ok || !at.After(previous.time) {
+		return deltas
+	}
+	interval := at.Sub(previous.time)
+	for _, counter := range counters {
+		last, ok := previous.counters[counter.Name]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, counterDelta(last, counter, interval))
+	}
+	return deltas
+}
+
+/**
+ * 删除设备保存的采样，设备下线时调用
+ * @param key 设备的索引键值
+ * @author shenbowei
+ */
+func (this *CounterTracker) Forget(key string) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	delete(this.samples, key)
+}
+
+/**
+ * 计算两次采样之间的变化。计数变小时，上一次的值超过2^31且在32位范围内的按32位计数回绕计算增量，
+ * 其他情况认为计数被清零，此时所有计数的增量都为本次采样的值
+ * @param last 上一次的计数, current 本次的计数, interval 两次采样的时间间隔
+ * @return 计数的变化
+ * @author shenbowei
+ */
+func counterDelta(last, current InterfaceCounters, interval time.Duration) CounterDelta {
+	lastValues := counterValues(last)
+	currentValues := counterValues(current)
+	delta := CounterDelta{Name: current.Name, Interval: interval}
+	deltaValues := currentValues
+	for i := range deltaValues {
+		switch {
+		case currentValues[i] >= lastValues[i]:
+			deltaValues[i] -= lastValues[i]
+		case isCounterWrap(lastValues[i], currentValues[i]):
+			deltaValues[i] += math.MaxUint32 + 1 - lastValues[i]
+		default:
+			delta.Reset = true
+		}
+	}
+	if delta.Reset {
+		deltaValues = currentValues
+	}
+	seconds := interval.Seconds()
+	delta.Delta = InterfaceCounters{Name: current.Name,
+		InOctets: deltaValues[0], OutOctets: deltaValues[1], InPackets: deltaValues[2], OutPackets: deltaValues[3],
+		InErrors: deltaValues[4], OutErrors: deltaValues[5], InDiscards: deltaValues[6], OutDiscards: deltaValues[7], CRCErrors: deltaValues[8]}
+	delta.Rate = CounterRate{
+		InOctets: float64(deltaValues[0]) / seconds, OutOctets: float64(deltaValues[1]) / seconds,
+		InPackets: float64(deltaValues[2]) / seconds, OutPackets: float64(deltaValues[3]) / seconds,
+		InErrors: float64(deltaValues[4]) / seconds, OutErrors: float64(deltaValues[5]) / seconds,
+		InDiscards: float64(deltaValues[6]) / seconds, OutDiscards: float64(deltaValues[7]) / seconds,
+		CRCErrors: float64(deltaValues[8]) / seconds}
+	return delta
+}
+
+/**
+ * 判断计数变小是否是32位计数的回绕：上一次的值超过2^31（已接近上限）且在32位范围内，超出32位范围的64位计数不会回绕
+ * @param last 上一次的值, current 本次的值（小于last）
+ * @return true:回绕
+ * @author shenbowei
+ */
+func isCounterWrap(last, current uint64) bool {
+	return last > math.MaxInt32 && last <= math.MaxUint32 && current < last
+}
+
+/**
+ * 按固定顺序获取接口的各个计数，便于统一计算
+ * @param counter 接口计数
+ * @return InOctets, OutOctets, InPackets, OutPackets, InErrors, OutErrors, InDiscards, OutDiscards, CRCErrors
+ * @author shenbowei
+ */
+func counterValues(counter InterfaceCounters) [9]uint64 {
+	return [9]uint64{counter.InOctets, counter.OutOctets, counter.InPackets, counter.OutPackets,
+		counter.InErrors, counter.OutErrors, counter.InDiscards, counter.OutDiscards, counter.CRCErrors}
+}
@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+const huaweiCountersSample = `GigabitEthernet0/0/1 current state : UP
+Line protocol current state : UP
+Last 300 seconds input rate 2640 bits/sec, 2 packets/sec
+Last 300 seconds output rate 1320 bits/sec, 1 packets/sec
+Input:  1234 packets, 567890 bytes
+  Unicast:                  1000,  Multicast:                 200
+  Broadcast:                  34,  Jumbo:                       0
+  Discard:                     2,  Total Error:                 5
+
+  CRC:                         3,  Giants:                      0
+Output:  2345 packets, 678901 bytes
+  Unicast:                  2000,  Multicast:                 300
+  Discard:                     1,  Total Error:                 0
+`
+
+const h3cCountersSample = `GigabitEthernet1/0/1
+Current state: UP
+Line protocol state: UP
+Input (total):  123456 packets, 98765432 bytes
+         100000 unicasts, 20000 broadcasts, 3456 multicasts, 0 pauses
+Input (normal):  123450 packets, - bytes
+Input:  7 input errors, 0 runts, 0 giants, 0 throttles
+         4 CRC, 0 frame, - overruns, 0 aborts
+Output (total): 234567 packets, 87654321 bytes
+Output: 1 output errors, - underruns, - buffer failures
+         0 aborts, 0 deferred, 0 collisions, 0 late collisions
+`
+
+const ciscoCountersSample = `GigabitEthernet1/0/1 is up, line protocol is up (connected)
+  Input queue: 0/75/12/0 (size/max/drops/flushes); Total output drops: 7
+  5 minute input rate 2000 bits/sec, 3 packets/sec
+     123456 packets input, 98765432 bytes, 0 no buffer
+     Received 1000 broadcasts (900 multicasts)
+     5 input errors, 3 CRC, 0 frame, 0 overrun, 0 ignored
+     234567 packets output, 87654321 bytes, 0 underruns
+     2 output errors, 0 collisions, 1 interface resets
+`
+
+func TestParseInterfaceCounters(t *testing.T) {
+	tests := []struct {
+		brand    string
+		output   string
+		expected InterfaceCounters
+	}{
+		{HUAWEI, huaweiCountersSample, InterfaceCounters{Name: "GigabitEthernet0/0/1", InOctets: 567890, OutOctets: 678901,
+			InPackets: 1234, OutPackets: 2345, InErrors: 5, InDiscards: 2, OutDiscards: 1, CRCErrors: 3}},
+		{H3C, h3cCountersSample, InterfaceCounters{Name: "GigabitEthernet1/0/1", InOctets: 98765432, OutOctets: 87654321,
+			InPackets: 123456, OutPackets: 234567, InErrors: 7, OutErrors: 1, CRCErrors: 4}},
+		{CISCO, ciscoCountersSample, InterfaceCounters{Name: "GigabitEthernet1/0/1", InOctets: 98765432, OutOctets: 87654321,
+			InPackets: 123456, OutPackets: 234567, InErrors: 5, OutErrors: 2, InDiscards: 12, OutDiscards: 7, CRCErrors: 3}},
+	}
+	for _, test := range tests {
+		counters := parseInterfaceCounters(test.brand, test.output)
+		if len(counters) != 1 || counters[0] != test.expected {
+			t.Errorf("<%s> counters=%+v, expected %+v", test.brand, counters, test.expected)
+		}
+	}
+}
+
+func TestCounterTracker(t *testing.T) {
+	tracker := NewCounterTracker()
+	start := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	if deltas := tracker.Update("switch-1", start, []InterfaceCounters{{Name: "Gi1/0/1", InOctets: 1000, CRCErrors: 1}}); len(deltas) != 0 {
+		t.Errorf("first sample deltas=%+v, expected none", deltas)
+	}
+	deltas := tracker.Update("switch-1", start.Add(10*time.Second), []InterfaceCounters{
+		{Name: "Gi1/0/1", InOctets: 11000, CRCErrors: 3},
+		{Name: "Gi1/0/2", InOctets: 500},
+	})
+	if len(deltas) != 1 || deltas[0].Reset || deltas[0].Delta.InOctets != 10000 || deltas[0].Delta.CRCErrors != 2 ||
+		deltas[0].Rate.InOctets != 1000 || deltas[0].Rate.CRCErrors != 0.2 || deltas[0].Interval != 10*time.Second {
+		t.Errorf("unexpected deltas:%+v", deltas)
+	}
+	//计数被清零
+	deltas = tracker.Update("switch-1", start.Add(20*time.Second), []InterfaceCounters{{Name: "Gi1/0/1", InOctets: 400}})
+	if len(deltas) != 1 || !deltas[0].Reset || deltas[0].Delta.InOctets != 400 || deltas[0].Rate.InOctets != 40 {
+		t.Errorf("unexpected deltas after reset:%+v", deltas)
+	}
+	tracker.Forget("switch-1")
+	if deltas := tracker.Update("switch-1", start.Add(30*time.Second), []InterfaceCounters{{Name: "Gi1/0/1"}}); len(deltas) != 0 {
+		t.Errorf("deltas after Forget=%+v, expected none", deltas)
+	}
+}
+
+func TestCounterTrackerWrap(t *testing.T) {
+	tracker := &CounterTracker{}
+	start := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	tracker.Update("switch-1", start, []InterfaceCounters{{Name: "Gi1/0/1", InOctets: math.MaxUint32 - 99, OutOctets: 5000}})
+	//32位计数回绕，不是清零
+	deltas := tracker.Update("switch-1", start.Add(10*time.Second), []InterfaceCounters{{Name: "Gi1/0/1", InOctets: 900, OutOctets: 6000}})
+	if len(deltas) != 1 || deltas[0].Reset || deltas[0].Delta.InOctets != 1000 || deltas[0].Delta.OutOctets != 1000 ||
+		deltas[0].Rate.InOctets != 100 {
+		t.Errorf("unexpected deltas after wrap:%+v", deltas)
+	}
+	//上一次的值没有接近32位的上限
+	deltas = tracker.Update("switch-1", start.Add(20*time.Second), []InterfaceCounters{{Name: "Gi1/0/1", InOctets: 1000, OutOctets: 100}})
+	if len(deltas) != 1 || !deltas[0].Reset || deltas[0].Delta.InOctets != 1000 || deltas[0].Delta.OutOctets != 100 {
+		t.Errorf("unexpected deltas after reset:%+v", deltas)
+	}
+	//64位计数变小是清零
+	tracker.Update("switch-1", start.Add(30*time.Second), []InterfaceCounters{{Name: "Gi1/0/1", InOctets: math.MaxUint32 + 100}})
+	deltas = tracker.Update("switch-1", start.Add(40*time.Second), []InterfaceCounters{{Name: "Gi1/0/1", InOctets: 50}})
+	if len(deltas) != 1 || !deltas[0].Reset || deltas[0].Delta.InOctets != 50 {
+		t.Errorf("unexpected deltas after 64-bit reset:%+v", deltas)
+	}
+}
+
+func TestCounterTrackerPoll(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{CiscoInterfaceCmd: ciscoCountersSample})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+
+	tracker := NewCounterTracker()
+	for i := 0; i < 2; i++ {
+		deltas, err := tracker.Poll(manager, device)
+		if err != nil {
+			t.Fatalf("Poll err:%s", err)
+		}
+		if i == 1 && (len(deltas) != 1 || deltas[0].Name != "GigabitEthernet1/0/1" || deltas[0].Delta.InOctets != 0) {
+			t.Errorf("unexpected deltas:%+v", deltas)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package ssh
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultSSHPort    = 22
+	DefaultTelnetPort = 23
+)
+
+// 设备的传输方式
+const (
+	SSH_TRANSPORT    = "ssh"    //只使用ssh（默认）
+	TELNET_TRANSPORT = "telnet" //只使用telnet
+	AUTO_TRANSPORT   = "auto"   //优先使用ssh，ssh连接失败时回退到telnet
+)
+
+/**
+ * 设备的登录凭证，以指针的形式被Device引用，避免密码随Device被到处复制和打印
+ * @attr Password:登录密码，EnablePassword:提权密码（cisco的enable，华为/h3c的super，可为空）
+ * @author shenbowei
+ */
+type Credential struct {
+	Password       string
+	EnablePassword string
+}
+
+/**
+ * 设备的身份信息，SessionManager使用它作为缓存session的索引，密码只以摘要的形式参与索引
+ * @attr Host:设备的ip或域名，Port:ssh端口（为0时使用22），User:登录的用户名，Credential:登录凭证，
+ *       Brand:设备品牌（huawei，h3c，cisco，可为空，为空时自动识别），MaxVTY:设备允许的最大VTY（shell）数量（0为不限制），
+ *       ExecMode:指令的执行方式（SHELL_MODE或EXEC_MODE，为空时使用SessionManager的设置），
+ *       Transport:传输方式（SSH_TRANSPORT、TELNET_TRANSPORT或AUTO_TRANSPORT，为空时使用ssh），TelnetPort:telnet端口（为0时使用23），
+ *       Console:设备所在的控制台服务器线路（不为nil时通过控制台登录设备，同时只能有一个shell），JumpHosts:依次经过的跳板机（为空时直接连接），Via:外层设备（不为nil时先登录外层设备，再在其命令行中通过stelnet/ssh/telnet登录本设备），Dialer:建立tcp连接的Dialer（经过跳板机时用于连接第一个跳板机，为nil时使用SessionManager的设置，不参与索引），
+ *       Tags:自定义标签（不参与索引）
+ * @author shenbowei
+ */
+type Device struct {
+	Host       string
+	Port       int
+	User       string
+	Credential *Credential
+	Brand      string
+	MaxVTY     int
+	ExecMode   string
+	Transport  string
+	TelnetPort int
+	Console    *ConsoleServer
+	JumpHosts  []JumpHost
+	Via        *Device
+	Dialer     Dialer
+	Tags       map[string]string
+}
+
+/**
+ * 根据旧接口的参数创建Device
+ * @param user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口（无端口时使用22）, brand 交换机品牌（可为空）
+ * @return Device
+ * @author shenbowei
+ */
+func NewDevice(user, password, ipPort, brand string) Device {
+	device := Device{
+		Host:       ipPort,
+		User:       user,
+		Credential: &Credential{Password: password},
+		Brand:      brand,
+	}
+	if host, port, err := net.SplitHostPort(ipPort); err == nil {
+		device.Host = host
+		device.Port, _ = strconv.Atoi(port)
+	}
+	return device
+}
+
+/**
+ * 获取设备的ssh端口，未设置时返回22
+ * @return 端口
+ * @author shenbowei
+ */
+func (this Device) GetPort() int {
+	if this.Port <= 0 {
+		return DefaultSSHPort
+	}
+	return this.Port
+}
+
+/**
+ * 获取设备的连接地址
+ * @return host:port
+ * @author shenbowei
+ */
+func (this Device) Address() string {
+	return net.JoinHostPort(this.Host, strconv.Itoa(this.GetPort()))
+}
+
+/**
+ * 获取设备的telnet连接地址
+ * @return host:port
+ * @author shenbowei
+ */
+func (this Device) telnetAddress() string {
+	port := this.TelnetPort
+	if port <= 0 {
+		port = DefaultTelnetPort
+	}
+	return net.JoinHostPort(this.Host, strconv.Itoa(port))
+}
+
+/**
+ * 获取登录密码，未设置Credential时返回""
+ * @return 登录密码
+ * @author shenbowei
+ */
+func (this Device) password() string {
+	if this.Credential == nil {
+		return ""
+	}
+	return this.Credential.Password
+}
+
+/**
+ * 获取提权密码，未设置Credential时返回""
+ * @return 提权密码
+ * @author shenbowei
+ */
+func (this Device) enablePassword() string {
+	if this.Credential == nil {
+		return ""
+	}
+	return this.Credential.EnablePassword
+}
+
+// 计算密码摘要的随机密钥，每个进程生成一次，摘要不能在进程之外用于离线猜测密码
+var passwordDigestSecret = newPasswordDigestSecret()
+
+/**
+ * 生成计算密码摘要的随机密钥
+ * @return 32字节的随机密钥
+ * @author shenbowei
+ */
+func newPasswordDigestSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("ssh: generate password digest secret err:" + err.Error())
+	}
+	return secret
+}
+
+/**
+ * 计算密码的摘要，使用进程内随机密钥的HMAC-SHA256，只保留前16位
+ * @param password 密码
+ * @return 16位十六进制摘要
+ * @author shenbowei
+ */
+func passwordDigest(password string) string {
+	mac := hmac.New(sha256.New, passwordDigestSecret)
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+/**
+ * 获取设备在SessionManager中的索引键值，形如"user@host:port#密码摘要"，
+ * 用户名经过转义，密码只保留进程内随机密钥的HMAC摘要，同一进程中密码相同时键值相同，重启后键值会变化。
+ * 设置了Transport（ssh以外）或ExecMode时追加" over "传输方式和" exec "执行方式，经过跳板机时追加" via "和跳板机链路的键值，
+ * 经过外层设备时追加" from "和外层设备的键值，通过控制台时追加" console "和控制台线路的键值
+ * @return 索引键值
+ * @author shenbowei
+ */
+func (this Device) Key() string {
+	key := url.PathEscape(this.User) + "@" + this.Address() + "#" + passwordDigest(this.password())
+	if this.Transport != "" && this.Transport != SSH_TRANSPORT {
+		key += " over " + this.Transport
+	}
+	if this.ExecMode != "" {
+		key += " exec " + this.ExecMode
+	}
+	if len(this.JumpHosts) > 0 {
+		key += " via " + jumpChainKey(this.JumpHosts)
+	}
+	if this.Via != nil {
+		key += " from " + this.Via.Key()
+	}
+	if this.Console != nil {
+		key += " console " + this.Console.key()
+	}
+	return key
+}
+
+/**
+ * Device的字符串形式，不包含密码，用于日志
+ * @return user@host:port
+ * @author shenbowei
+ */
+func (this Device) String() string {
+	return url.PathEscape(this.User) + "@" + this.Address()
+}
@@ -0,0 +1,332 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+/**
+ * 建立tcp连接的接口，可以通过WithDialer或Device.Dialer替换，用于代理、绑定源地址、切换网络命名空间等场景。
+ * *net.Dialer实现了该接口
+ * @author shenbowei
+ */
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+/**
+ * 函数形式的Dialer
+ * @author shenbowei
+ */
+type DialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (this DialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return this(ctx, network, address)
+}
+
+/**
+ * 代理的认证信息
+ * @attr User:用户名，Password:密码
+ * @author shenbowei
+ */
+type ProxyAuth struct {
+	User     string
+	Password string
+}
+
+/**
+ * 创建绑定本地源地址的Dialer
+ * @param localIP 本地的源ip
+ * @return Dialer，localIP不是合法的ip时返回错误
+ * @author shenbowei
+ */
+func NewLocalAddrDialer(localIP string) (Dialer, error) {
+	ip := net.ParseIP(localIP)
+	if ip == nil {
+		return nil, errors.New("ssh: invalid local ip " + localIP)
+	}
+	return &net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}}, nil
+}
+
+/**
+ * 创建通过SOCKS5代理（RFC 1928）连接的Dialer，由代理解析目标的域名
+ * @param proxyAddr 代理的ip和端口, auth 用户名密码认证（为nil时不认证）, forward 连接代理使用的Dialer（为nil时直接连接）
+ * @return Dialer
+ * @author shenbowei
+ */
+func NewSOCKS5Dialer(proxyAddr string, auth *ProxyAuth, forward Dialer) Dialer {
+	return &socks5Dialer{proxyAddr: proxyAddr, auth: auth, forward: forwardDialer(forward)}
+}
+
+/**
+ * 创建通过HTTP代理的CONNECT方法连接的Dialer
+ * @param proxyAddr 代理的ip和端口, auth Basic认证（为nil时不认证）, forward 连接代理使用的Dialer（为nil时直接连接）
+ * @return Dialer
+ * @author shenbowei
+ */
+func NewHTTPProxyDialer(proxyAddr string, auth *ProxyAuth, forward Dialer) Dialer {
+	return &httpProxyDialer{proxyAddr: proxyAddr, auth: auth, forward: forwardDialer(forward)}
+}
+
+func forwardDialer(forward Dialer) Dialer {
+	if forward == nil {
+		return &net.Dialer{}
+	}
+	return forward
+}
+
+/**
+ * 在ctx取消或超时时关闭连接，使代理握手期间的读写立即返回
+ * @param ctx 上下文, conn 连接
+ * @return 握手完成后调用的函数，返回ctx的错误（ctx已结束时）
+ * @author shenbowei
+ */
+func watchContext(ctx context.Context, conn net.Conn) func() error {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() error {
+		close(done)
+		<-stopped
+		return ctx.Err()
+	}
+}
+
+type socks5Dialer struct {
+	proxyAddr string
+	auth      *ProxyAuth
+	forward   Dialer
+}
+
+// SOCKS5协议的常量
+const (
+	socks5Version        = 5
+	socks5AuthNone       = 0
+	socks5AuthPassword   = 2
+	socks5CmdConnect     = 1
+	socks5AddrIPv4       = 1
+	socks5AddrDomain     = 3
+	socks5AddrIPv6       = 4
+	socks5ReplySucceeded = 0
+)
+
+func (this *socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := this.forward.DialContext(ctx, "tcp", this.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	stop := watchContext(ctx, conn)
+	err = this.connect(conn, address)
+	if ctxErr := stop(); ctxErr != nil && err != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("ssh: socks5 proxy " + this.proxyAddr + ": " + err.Error())
+	}
+	return conn, nil
+}
+
+/**
+ * 完成SOCKS5的认证和CONNECT请求
+ * @param conn 到代理的连接, address 目标的host:port
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func (this *socks5Dialer) connect(conn net.Conn, address string) error {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil || port <= 0 || port > 65535 {
+		return errors.New("invalid port " + portString)
+	}
+	method := byte(socks5AuthNone)
+	if this.auth != nil {
+		method = socks5AuthPassword
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version || reply[1] != method {
+		return errors.New("no acceptable authentication method")
+	}
+	if method == socks5AuthPassword {
+		//用户名密码认证（RFC 1929）
+		if len(this.auth.User) > 255 || len(this.auth.Password) > 255 {
+			return errors.New("user or password is too long")
+		}
+		request := []byte{1, byte(len(this.auth.User))}
+		request = append(request, this.auth.User...)
+		request = append(request, byte(len(this.auth.Password)))
+		request = append(request, this.auth.Password...)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	}
+
+	request := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("host is too long")
+		}
+		request = append(request, socks5AddrDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5AddrIPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5AddrIPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != socks5ReplySucceeded {
+		return fmt.Errorf("connect %s failed, reply code %d", address, header[1])
+	}
+	//跳过代理返回的绑定地址和端口
+	addrLength := 0
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLength = net.IPv4len
+	case socks5AddrIPv6:
+		addrLength = net.IPv6len
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		addrLength = int(length[0])
+	default:
+		return errors.New("unknown address type in reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, addrLength+2))
+	return err
+}
+
+type httpProxyDialer struct {
+	proxyAddr string
+	auth      *ProxyAuth
+	forward   Dialer
+}
+
+func (this *httpProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := this.forward.DialContext(ctx, "tcp", this.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	stop := watchContext(ctx, conn)
+	reader, err := this.connect(conn, address)
+	if ctxErr := stop(); ctxErr != nil && err != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("ssh: http proxy " + this.proxyAddr + ": " + err.Error())
+	}
+	if reader.Buffered() > 0 {
+		//代理在响应后已经发送了目标的数据，需要先读出缓存的部分
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+/**
+ * 发送CONNECT请求并读取代理的响应
+ * @param conn 到代理的连接, address 目标的host:port
+ * @return 读取响应使用的缓存，执行的错误
+ * @author shenbowei
+ */
+func (this *httpProxyDialer) connect(conn net.Conn, address string) (*bufio.Reader, error) {
+	request := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if this.auth != nil {
+		credential := base64.StdEncoding.EncodeToString([]byte(this.auth.User + ":" + this.auth.Password))
+		request += "Proxy-Authorization: Basic " + credential + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("connect " + address + " failed: " + response.Status)
+	}
+	return reader, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (this *bufferedConn) Read(p []byte) (int, error) {
+	return this.reader.Read(p)
+}
+
+/**
+ * 通过跳板机的direct-tcpip通道建立连接的Dialer
+ * @attr client:跳板机的ssh连接
+ * @author shenbowei
+ */
+type sshClientDialer struct {
+	client *ssh.Client
+}
+
+func (this sshClientDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	result := make(chan dialResult, 1)
+	go func() {
+		conn, err := this.client.Dial(network, address)
+		result <- dialResult{conn, err}
+	}()
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-ctx.Done():
+		//超时后到达的连接直接关闭
+		go func() {
+			if r := <-result; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, errors.New("ssh: dial " + address + " through jump host: " + ctx.Err().Error())
+	}
+}
@@ -0,0 +1,220 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+ * 测试使用的模拟代理，记录收到的连接请求，并将连接转发到目标
+ */
+type fakeProxy struct {
+	listener  net.Listener
+	handshake func(conn net.Conn, reader *bufio.Reader) (string, bool)
+	locker    sync.Mutex
+	targets   []string
+	waitGroup sync.WaitGroup
+}
+
+func newFakeProxy(t *testing.T, handshake func(conn net.Conn, reader *bufio.Reader) (string, bool)) *fakeProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	proxy := &fakeProxy{listener: listener, handshake: handshake}
+	proxy.waitGroup.Add(1)
+	go proxy.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		proxy.waitGroup.Wait()
+	})
+	return proxy
+}
+
+func (this *fakeProxy) getTargets() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string(nil), this.targets...)
+}
+
+func (this *fakeProxy) serve() {
+	defer this.waitGroup.Done()
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			target, ok := this.handshake(conn, reader)
+			if !ok {
+				return
+			}
+			this.locker.Lock()
+			this.targets = append(this.targets, target)
+			this.locker.Unlock()
+			targetConn, err := net.Dial("tcp", target)
+			if err != nil {
+				return
+			}
+			defer targetConn.Close()
+			go io.Copy(targetConn, reader)
+			io.Copy(conn, targetConn)
+		}()
+	}
+}
+
+// SOCKS5代理的握手，user不为空时要求用户名密码认证
+func socks5Handshake(user, password string) func(conn net.Conn, reader *bufio.Reader) (string, bool) {
+	return func(conn net.Conn, reader *bufio.Reader) (string, bool) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return "", false
+		}
+		methods := make([]byte, header[1])
+		io.ReadFull(reader, methods)
+		if user == "" {
+			conn.Write([]byte{5, 0})
+		} else {
+			conn.Write([]byte{5, 2})
+			version := make([]byte, 2)
+			io.ReadFull(reader, version)
+			name := make([]byte, version[1])
+			io.ReadFull(reader, name)
+			length, _ := reader.ReadByte()
+			pass := make([]byte, length)
+			io.ReadFull(reader, pass)
+			if string(name) != user || string(pass) != password {
+				conn.Write([]byte{1, 1})
+				return "", false
+			}
+			conn.Write([]byte{1, 0})
+		}
+		request := make([]byte, 4)
+		io.ReadFull(reader, request)
+		host := ""
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			io.ReadFull(reader, ip)
+			host = net.IP(ip).String()
+		case 3:
+			length, _ := reader.ReadByte()
+			name := make([]byte, length)
+			io.ReadFull(reader, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(reader, port)
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+		return net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))), true
+	}
+}
+
+// HTTP CONNECT代理的握手，auth不为空时校验Proxy-Authorization
+func httpConnectHandshake(auth string) func(conn net.Conn, reader *bufio.Reader) (string, bool) {
+	return func(conn net.Conn, reader *bufio.Reader) (string, bool) {
+		request, err := http.ReadRequest(reader)
+		if err != nil || request.Method != http.MethodConnect {
+			return "", false
+		}
+		if auth != "" && request.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)) {
+			conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			return "", false
+		}
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		return request.Host, true
+	}
+}
+
+func TestSessionManagerProxyDialers(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	socksProxy := newFakeProxy(t, socks5Handshake("proxy", "secret"))
+	httpProxy := newFakeProxy(t, httpConnectHandshake("proxy:secret"))
+	auth := &ProxyAuth{User: "proxy", Password: "secret"}
+
+	socksDevice := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+	socksDevice.Dialer = NewSOCKS5Dialer(socksProxy.listener.Addr().String(), auth, nil)
+	manager := newTestSessionManager(WithDialer(NewHTTPProxyDialer(httpProxy.listener.Addr().String(), auth, nil)))
+	defer manager.Shutdown(context.Background())
+	//Device.Dialer优先，其余设备使用WithDialer设置的HTTP代理
+	httpDevice := NewDevice("other", "admin", fake.addr(), HUAWEI)
+
+	for _, device := range []Device{socksDevice, httpDevice} {
+		result, err := manager.RunDeviceCommands(device, "dis clock")
+		if err != nil {
+			t.Fatalf("RunDeviceCommands<%s> err:%s", device, err)
+		}
+		if !strings.Contains(result, "2026-10-18 10:00:00") {
+			t.Errorf("RunDeviceCommands<%s> unexpected result:%q", device, result)
+		}
+	}
+	for _, proxy := range []*fakeProxy{socksProxy, httpProxy} {
+		if targets := proxy.getTargets(); len(targets) != 1 || targets[0] != fake.addr() {
+			t.Errorf("unexpected proxy targets:%v", targets)
+		}
+	}
+}
+
+func TestProxyDialerErrors(t *testing.T) {
+	socksProxy := newFakeProxy(t, socks5Handshake("proxy", "secret"))
+	httpProxy := newFakeProxy(t, httpConnectHandshake("proxy:secret"))
+	//接受连接后不做任何应答的代理
+	silentProxy := newFakeProxy(t, func(conn net.Conn, reader *bufio.Reader) (string, bool) {
+		io.Copy(io.Discard, reader)
+		return "", false
+	})
+	wrongAuth := &ProxyAuth{User: "proxy", Password: "wrong"}
+
+	dialers := map[string]Dialer{
+		"socks5 wrong auth": NewSOCKS5Dialer(socksProxy.listener.Addr().String(), wrongAuth, nil),
+		"http wrong auth":   NewHTTPProxyDialer(httpProxy.listener.Addr().String(), wrongAuth, nil),
+		"silent proxy":      NewSOCKS5Dialer(silentProxy.listener.Addr().String(), nil, nil),
+	}
+	for name, dialer := range dialers {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", "127.0.0.1:22")
+		cancel()
+		if err == nil {
+			conn.Close()
+			t.Errorf("%s: DialContext should fail", name)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Errorf("%s: DialContext does not respect the context", name)
+		}
+	}
+}
+
+func TestLocalAddrDialer(t *testing.T) {
+	if _, err := NewLocalAddrDialer("not an ip"); err == nil {
+		t.Errorf("NewLocalAddrDialer should reject an invalid ip")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	defer listener.Close()
+	dialer, err := NewLocalAddrDialer("127.0.0.1")
+	if err != nil {
+		t.Fatalf("NewLocalAddrDialer err:%s", err)
+	}
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext err:%s", err)
+	}
+	defer conn.Close()
+	if host, _, _ := net.SplitHostPort(conn.LocalAddr().String()); host != "127.0.0.1" {
+		t.Errorf("local address=%s, expected 127.0.0.1", conn.LocalAddr())
+	}
+}
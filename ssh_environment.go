@@ -0,0 +1,657 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 硬件部件的状态
+const (
+	ENV_OK      = "ok"
+	ENV_WARNING = "warning"
+	ENV_FAULT   = "fault"
+	ENV_ABSENT  = "absent"
+)
+
+/**
+ * 风扇
+ * @attr Slot:所在的槽位/堆叠成员（设备没有输出时为""），ID:风扇编号，Status:状态（ENV_OK、ENV_FAULT、ENV_ABSENT等），Speed:转速百分比（未知时为0）
+ * @author shenbowei
+ */
+type Fan struct {
+	Slot   string
+	ID     string
+	Status string
+	Speed  int
+}
+
+/**
+ * 电源
+ * @attr Slot:所在的槽位/堆叠成员，ID:电源编号，Status:状态，Watts:功率（未知时为0）
+ * @author shenbowei
+ */
+type PowerSupply struct {
+	Slot   string
+	ID     string
+	Status string
+	Watts  float64
+}
+
+/**
+ * 温度传感器，温度的单位均为摄氏度
+ * @attr Slot:所在的槽位/堆叠成员，Sensor:传感器名称，Celsius:当前温度，Low:下限告警阈值，High:上限告警阈值，
+ *       Critical:严重告警阈值（设备没有输出的阈值为0），Status:状态（ENV_OK、ENV_WARNING、ENV_FAULT）
+ * @author shenbowei
+ */
+type Temperature struct {
+	Slot     string
+	Sensor   string
+	Celsius  float64
+	Low      float64
+	High     float64
+	Critical float64
+	Status   string
+}
+
+/**
+ * CPU利用率（百分比）
+ * @attr Slot:所在的槽位/堆叠成员，FiveSeconds/OneMinute/FiveMinutes:最近5秒、1分钟、5分钟的利用率（设备没有输出时为0），
+ *       reported:设备输出了的利用率（cpuFiveSeconds等的组合），用于区分0%和没有输出
+ * @author shenbowei
+ */
+type CPUUsage struct {
+	Slot        string
+	FiveSeconds float64
+	OneMinute   float64
+	FiveMinutes float64
+	reported    uint8
+}
+
+// CPUUsage中设备输出了的利用率
+const (
+	cpuFiveSeconds uint8 = 1 << iota
+	cpuOneMinute
+	cpuFiveMinutes
+	cpuAllPeriods = cpuFiveSeconds | cpuOneMinute | cpuFiveMinutes
+)
+
+/**
+ * 内存使用情况
+ * @attr Slot:所在的槽位/堆叠成员，Total:内存总量（字节），Used:已使用量（字节），Percent:使用率百分比
+ * @author shenbowei
+ */
+type MemoryUsage struct {
+	Slot    string
+	Total   int64
+	Used    int64
+	Percent float64
+}
+
+/**
+ * 设备的硬件环境信息
+ * @author shenbowei
+ */
+type Environment struct {
+	Fans          []Fan
+	PowerSupplies []PowerSupply
+	Temperatures  []Temperature
+	CPUs          []CPUUsage
+	Memory        []MemoryUsage
+}
+
+/**
+ * CPU和内存利用率的告警阈值（百分比），为0时不检查
+ * @author shenbowei
+ */
+type EnvironmentThresholds struct {
+	CPU    float64
+	Memory float64
+}
+
+// 默认的CPU和内存告警阈值
+var DefaultEnvironmentThresholds = EnvironmentThresholds{CPU: 80, Memory: 85}
+
+// 获取硬件环境信息时各品牌执行的指令
+var (
+	HuaweiFanCmd         = "display fan"
+	HuaweiPowerCmd       = "display power"
+	HuaweiTemperatureCmd = "display temperature all"
+	HuaweiCPUCmd         = "display cpu-usage"
+	HuaweiMemoryCmd      = "display memory-usage"
+	H3cTemperatureCmd    = "display environment"
+	H3cMemoryCmd         = "display memory"
+	CiscoEnvironmentCmd  = "show environment all"
+	CiscoCPUCmd          = "show processes cpu | include CPU utilization"
+	CiscoMemoryCmd       = "show processes memory | include Processor Pool"
+)
+
+/**
+ * 获取设备的风扇、电源、温度、CPU和内存信息
+ * @param device 设备的身份信息
+ * @return 硬件环境信息，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetEnvironment(device Device) (Environment, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiFanCmd, HuaweiPowerCmd, HuaweiTemperatureCmd, HuaweiCPUCmd, HuaweiMemoryCmd},
+		H3C:    {HuaweiFanCmd, HuaweiPowerCmd, H3cTemperatureCmd, HuaweiCPUCmd, H3cMemoryCmd},
+		CISCO:  {CiscoEnvironmentCmd, CiscoCPUCmd, CiscoMemoryCmd},
+	})
+	if err != nil {
+		return Environment{}, err
+	}
+	switch brand {
+	case H3C:
+		return Environment{
+			Fans:          parseHuaweiFans(outputs[HuaweiFanCmd]),
+			PowerSupplies: parseHuaweiPowerSupplies(outputs[HuaweiPowerCmd]),
+			Temperatures:  parseH3cTemperatures(outputs[H3cTemperatureCmd]),
+			CPUs:          parseCPUUsage(outputs[HuaweiCPUCmd]),
+			Memory:        parseMemoryUsage(outputs[H3cMemoryCmd]),
+		}, nil
+	case CISCO:
+		environment := parseCiscoEnvironment(outputs[CiscoEnvironmentCmd])
+		environment.CPUs = parseCPUUsage(outputs[CiscoCPUCmd])
+		environment.Memory = parseMemoryUsage(outputs[CiscoMemoryCmd])
+		return environment, nil
+	}
+	return Environment{
+		Fans:          parseHuaweiFans(outputs[HuaweiFanCmd]),
+		PowerSupplies: parseHuaweiPowerSupplies(outputs[HuaweiPowerCmd]),
+		Temperatures:  parseHuaweiTemperatures(outputs[HuaweiTemperatureCmd]),
+		CPUs:          parseCPUUsage(outputs[HuaweiCPUCmd]),
+		Memory:        parseMemoryUsage(outputs[HuaweiMemoryCmd]),
+	}, nil
+}
+
+/**
+ * 检查硬件环境，返回异常的描述：风扇、电源、温度的状态不为ENV_OK（不在位的风扇和电源除外），
+ * CPU（5分钟利用率，设备没有输出时依次使用1分钟、5秒的利用率）或内存的利用率达到阈值
+ * @param thresholds CPU和内存的告警阈值
+ * @return 异常的描述，如"power 0/PWR2: fault"、"cpu 1: 92% >= 80%"
+ * @author shenbowei
+ */
+func (this Environment) Alarms(thresholds EnvironmentThresholds) []string {
+	alarms := make([]string, 0)
+	for _, fan := range this.Fans {
+		if fan.Status != ENV_OK && fan.Status != ENV_ABSENT {
+			alarms = append(alarms, fmt.Sprintf("fan %s: %s", slotName(fan.Slot, fan.ID), fan.Status))
+		}
+	}
+	for _, power := range this.PowerSupplies {
+		if power.Status != ENV_OK && power.Status != ENV_ABSENT {
+			alarms = append(alarms, fmt.Sprintf("power %s: %s", slotName(power.Slot, power.ID), power.Status))
+		}
+	}
+	for _, temperature := range this.Temperatures {
+		if temperature.Status != ENV_OK {
+			alarms = append(alarms, fmt.Sprintf("temperature %s: %s %gC", slotName(temperature.Slot, temperature.Sensor),
+				temperature.Status, temperature.Celsius))
+		}
+	}
+	for _, cpu := range this.CPUs {
+		if usage := cpu.usage(); thresholds.CPU > 0 && usage >= thresholds.CPU {
+			alarms = append(alarms, fmt.Sprintf("%s: %g%% >= %g%%", strings.TrimSpace("cpu "+cpu.Slot), usage, thresholds.CPU))
+		}
+	}
+	for _, memory := range this.Memory {
+		if thresholds.Memory > 0 && memory.Percent >= thresholds.Memory {
+			alarms = append(alarms, fmt.Sprintf("memory %s: %g%% >= %g%%", memory.Slot, memory.Percent, thresholds.Memory))
+		}
+	}
+	return alarms
+}
+
+/**
+ * 获取用于告警的CPU利用率：优先使用5分钟的利用率，设备没有输出时（如华为只有"CPU Usage : 6%"）依次使用1分钟、5秒的利用率，
+ * 设备输出的0%也会被使用。不是解析得到的CPUUsage（没有reported）按不为0判断是否有输出
+ * @return 利用率（百分比）
+ * @author shenbowei
+ */
+func (this CPUUsage) usage() float64 {
+	for _, figure := range []struct {
+		period uint8
+		usage  float64
+	}{{cpuFiveMinutes, this.FiveMinutes}, {cpuOneMinute, this.OneMinute}, {cpuFiveSeconds, this.FiveSeconds}} {
+		if this.reported&figure.period != 0 || (this.reported == 0 && figure.usage > 0) {
+			return figure.usage
+		}
+	}
+	return 0
+}
+
+/**
+ * 设置一个时间段的利用率，并记录设备输出了该利用率
+ * @param period 时间段（cpuFiveSeconds、cpuOneMinute或cpuFiveMinutes）, usage 利用率
+ * @author shenbowei
+ */
+func (this *CPUUsage) set(period uint8, usage float64) {
+	switch period {
+	case cpuFiveSeconds:
+		this.FiveSeconds = usage
+	case cpuOneMinute:
+		this.OneMinute = usage
+	case cpuFiveMinutes:
+		this.FiveMinutes = usage
+	}
+	this.reported |= period
+}
+
+/**
+ * 拼接槽位和部件名称
+ * @param slot 槽位, name 部件名称
+ * @return "槽位/名称"，槽位为""时为名称
+ * @author shenbowei
+ */
+func slotName(slot, name string) string {
+	if slot == "" {
+		return name
+	}
+	return slot + "/" + name
+}
+
+/**
+ * 将设备输出的部件状态规范化为ENV_OK、ENV_WARNING、ENV_ABSENT、ENV_FAULT，无法识别的状态（如Abnormal、Faulty、RED）均为ENV_FAULT
+ * @param status 设备输出的状态
+ * @return 规范化的状态
+ * @author shenbowei
+ */
+func environmentStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "ok", "normal", "good", "green", "supply", "yes", "present":
+		return ENV_OK
+	case "yellow", "warning", "minor":
+		return ENV_WARNING
+	case "absent", "not present", "notpresent", "no":
+		return ENV_ABSENT
+	}
+	return ENV_FAULT
+}
+
+/**
+ * 根据阈值判断温度的状态
+ * @param temperature 温度传感器
+ * @return 达到严重告警阈值时为ENV_FAULT，达到上限或低于下限时为ENV_WARNING，否则为ENV_OK
+ * @author shenbowei
+ */
+func temperatureStatus(temperature Temperature) string {
+	switch {
+	case temperature.Critical > 0 && temperature.Celsius >= temperature.Critical:
+		return ENV_FAULT
+	case temperature.High > 0 && temperature.Celsius >= temperature.High, temperature.Celsius < temperature.Low:
+		return ENV_WARNING
+	}
+	return ENV_OK
+}
+
+/**
+ * 将表格的一行按表头转换为"列名（小写）:值"，列数少于表头时返回nil
+ * @param header 表头的各列, fields 一行的各列
+ * @return 各列的值
+ * @author shenbowei
+ */
+func environmentRow(header, fields []string) map[string]string {
+	if len(header) == 0 || len(fields) < len(header) {
+		return nil
+	}
+	row := make(map[string]string)
+	for i, name := range header {
+		row[strings.ToLower(name)] = fields[i]
+	}
+	return row
+}
+
+/**
+ * 获取表格一行中第一个存在的列的值
+ * @param row 各列的值, names 列名（小写）
+ * @return 列的值，都不存在时为""
+ * @author shenbowei
+ */
+func rowValue(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if value, ok := row[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+/**
+ * 获取华为/h3c风扇和电源表格中一行的状态：不在位时为ENV_ABSENT，否则为Status/State列的状态，
+ * 没有状态列时根据Register列判断
+ * @param row 各列的值
+ * @return 规范化的状态
+ * @author shenbowei
+ */
+func rowStatus(row map[string]string) string {
+	if environmentStatus(rowValue(row, "present", "online")) == ENV_ABSENT {
+		return ENV_ABSENT
+	}
+	if status := rowValue(row, "status", "state"); status != "" {
+		return environmentStatus(status)
+	}
+	if strings.EqualFold(row["register"], "no") {
+		return ENV_FAULT
+	}
+	return ENV_OK
+}
+
+/**
+ * 解析数值，"--"等无法解析的值为0
+ * @param text 数值的文本（可以带有"%"）
+ * @return 数值
+ * @author shenbowei
+ */
+func parseEnvironmentValue(text string) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(text), "%"), 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+var (
+	environmentSlotRegexp = regexp.MustCompile(`(?i)^\s*(?:Chassis \d+ )?Slot\s*(\d+)\s*:\s*$`)
+	h3cFanRegexp          = regexp.MustCompile(`^\s*Fan\s*(\d+)\s*:\s*$`)
+)
+
+/**
+ * 解析华为/h3c的风扇（display fan）。华为为"Slot FanID FanNum Present Register Speed ..."的表格，
+ * h3c在"Slot 1:"之后为"Fan 1:"、"State : Normal"形式的段落
+ * @param output 指令的输出
+ * @return 风扇
+ * @author shenbowei
+ */
+func parseHuaweiFans(output string) []Fan {
+	fans := make([]Fan, 0)
+	slot := ""
+	var header []string
+	var current *Fan
+	for _, line := range outputLines(output) {
+		if match := environmentSlotRegexp.FindStringSubmatch(line); match != nil {
+			slot, current = match[1], nil
+			continue
+		}
+		if match := h3cFanRegexp.FindStringSubmatch(line); match != nil {
+			fans = append(fans, Fan{Slot: slot, ID: match[1], Status: ENV_OK})
+			current = &fans[len(fans)-1]
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.EqualFold(fields[0], "Slot") || strings.EqualFold(fields[0], "FanID") {
+			header = fields
+			continue
+		}
+		if current != nil {
+			if key := strings.ToLower(strings.TrimSpace(strings.SplitN(line, ":", 2)[0])); key == "state" || key == "status" {
+				current.Status = environmentStatus(lineValue(line))
+			}
+			continue
+		}
+		row := environmentRow(header, fields)
+		if row == nil || !isDigits(fields[0]) {
+			continue
+		}
+		fan := Fan{Slot: slot, ID: rowValue(row, "fanid", "fan"), Status: rowStatus(row), Speed: int(parseEnvironmentValue(row["speed"]))}
+		if value, ok := row["slot"]; ok {
+			fan.Slot = value
+		}
+		fans = append(fans, fan)
+	}
+	return fans
+}
+
+/**
+ * 解析华为/h3c的电源（display power）。华为为"Slot PowerID Online Mode State Power(W)"的表格，
+ * h3c在"Slot 1:"之后为"PowerID State Mode Current(A) Voltage(V) Power(W) ..."的表格
+ * @param output 指令的输出
+ * @return 电源
+ * @author shenbowei
+ */
+func parseHuaweiPowerSupplies(output string) []PowerSupply {
+	powers := make([]PowerSupply, 0)
+	slot := ""
+	var header []string
+	for _, line := range outputLines(output) {
+		if match := environmentSlotRegexp.FindStringSubmatch(line); match != nil {
+			slot = match[1]
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.EqualFold(fields[0], "Slot") || strings.EqualFold(fields[0], "PowerID") {
+			header = fields
+			continue
+		}
+		row := environmentRow(header, fields)
+		if row == nil || !isDigits(fields[0]) {
+			continue
+		}
+		power := PowerSupply{Slot: slot, ID: rowValue(row, "powerid", "power"), Status: rowStatus(row),
+			Watts: parseEnvironmentValue(row["power(w)"])}
+		if value, ok := row["slot"]; ok {
+			power.Slot = value
+		}
+		powers = append(powers, power)
+	}
+	return powers
+}
+
+/**
+ * 解析华为的温度（display temperature all），表头为"Slot Card Sensor Status Current(C) Lower(C) ... Upper(C) ..."
+ * @param output 指令的输出
+ * @return 温度传感器
+ * @author shenbowei
+ */
+func parseHuaweiTemperatures(output string) []Temperature {
+	temperatures := make([]Temperature, 0)
+	var header []string
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if strings.EqualFold(fields[0], "Slot") {
+			header = fields
+			continue
+		}
+		row := environmentRow(header, fields)
+		if row == nil || !isDigits(fields[0]) {
+			continue
+		}
+		temperature := Temperature{Slot: row["slot"], Sensor: row["sensor"], Celsius: parseEnvironmentValue(row["current(c)"]),
+			Low: parseEnvironmentValue(row["lower(c)"]), High: parseEnvironmentValue(row["upper(c)"])}
+		if status, ok := row["status"]; ok {
+			temperature.Status = environmentStatus(status)
+		} else {
+			temperature.Status = temperatureStatus(temperature)
+		}
+		temperatures = append(temperatures, temperature)
+	}
+	return temperatures
+}
+
+/**
+ * 解析h3c的温度（display environment），每行为"槽位 传感器 温度 Lower Warning Alarm Shutdown"，
+ * 传感器名称可能包含空格（如"hotspot 1"），状态根据阈值判断
+ * @param output 指令的输出
+ * @return 温度传感器
+ * @author shenbowei
+ */
+func parseH3cTemperatures(output string) []Temperature {
+	temperatures := make([]Temperature, 0)
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) < 7 || !isDigits(fields[0]) {
+			continue
+		}
+		values := fields[len(fields)-5:]
+		temperature := Temperature{Slot: fields[0], Sensor: strings.Join(fields[1:len(fields)-5], " "),
+			Celsius: parseEnvironmentValue(values[0]), Low: parseEnvironmentValue(values[1]),
+			High: parseEnvironmentValue(values[2]), Critical: parseEnvironmentValue(values[3])}
+		temperature.Status = temperatureStatus(temperature)
+		temperatures = append(temperatures, temperature)
+	}
+	return temperatures
+}
+
+var (
+	cpuUtilizationRegexp = regexp.MustCompile(`five seconds:\s*([\d.]+)%.*one minute:\s*([\d.]+)%.*five minutes:\s*([\d.]+)%`)
+	huaweiCPUUsageRegexp = regexp.MustCompile(`^\s*CPU Usage\s*:\s*([\d.]+)%`)
+	h3cCPUSlotRegexp     = regexp.MustCompile(`(?i)^\s*(?:Chassis \d+ )?Slot (\d+)(?: CPU \d+)? CPU usage`)
+	h3cCPURegexp         = regexp.MustCompile(`^\s*([\d.]+)% in last (5 seconds|1 minute|5 minutes)`)
+)
+
+/**
+ * 解析CPU利用率：华为display cpu-usage和cisco show processes cpu为"CPU utilization for five seconds: 6%...one minute: 6%...five minutes: 6%"，
+ * 华为没有该行时"CPU Usage : 6%"作为1分钟利用率；h3c display cpu-usage为"Slot 1 CPU 0 CPU usage:"之后的"6% in last 5 seconds"等行
+ * @param output 指令的输出
+ * @return CPU利用率
+ * @author shenbowei
+ */
+func parseCPUUsage(output string) []CPUUsage {
+	cpus := make([]CPUUsage, 0)
+	slot := ""
+	var current *CPUUsage
+	for _, line := range outputLines(output) {
+		if match := environmentSlotRegexp.FindStringSubmatch(line); match != nil {
+			slot, current = match[1], nil
+			continue
+		}
+		if match := h3cCPUSlotRegexp.FindStringSubmatch(line); match != nil {
+			cpus = append(cpus, CPUUsage{Slot: match[1]})
+			current = &cpus[len(cpus)-1]
+			continue
+		}
+		if match := h3cCPURegexp.FindStringSubmatch(line); match != nil && current != nil {
+			period := cpuFiveMinutes
+			switch match[2] {
+			case "5 seconds":
+				period = cpuFiveSeconds
+			case "1 minute":
+				period = cpuOneMinute
+			}
+			current.set(period, parseEnvironmentValue(match[1]))
+			continue
+		}
+		if match := cpuUtilizationRegexp.FindStringSubmatch(line); match != nil {
+			if current == nil {
+				cpus = append(cpus, CPUUsage{Slot: slot})
+				current = &cpus[len(cpus)-1]
+			}
+			current.set(cpuFiveSeconds, parseEnvironmentValue(match[1]))
+			current.set(cpuOneMinute, parseEnvironmentValue(match[2]))
+			current.set(cpuFiveMinutes, parseEnvironmentValue(match[3]))
+		} else if match := huaweiCPUUsageRegexp.FindStringSubmatch(line); match != nil {
+			if current == nil {
+				cpus = append(cpus, CPUUsage{Slot: slot})
+				current = &cpus[len(cpus)-1]
+			}
+			if current.reported&cpuOneMinute == 0 {
+				current.set(cpuOneMinute, parseEnvironmentValue(match[1]))
+			}
+		}
+	}
+	return cpus
+}
+
+var (
+	huaweiMemoryTotalRegexp   = regexp.MustCompile(`Total Memory Is:\s*(\d+)`)
+	huaweiMemoryUsedRegexp    = regexp.MustCompile(`Memory Used Is:\s*(\d+)`)
+	huaweiMemoryPercentRegexp = regexp.MustCompile(`Percentage Is:\s*([\d.]+)%`)
+	h3cMemoryRegexp           = regexp.MustCompile(`^\s*Mem:\s+(\d+)\s+(\d+)`)
+	ciscoMemoryRegexp         = regexp.MustCompile(`Processor Pool Total:\s*(\d+)\s+Used:\s*(\d+)`)
+)
+
+/**
+ * 解析内存使用情况：华为display memory-usage为"System Total Memory Is: 536870912 bytes"等行，
+ * h3c display memory在"Slot 1:"之后为"Mem: 总量 已使用 ..."（单位为KB），cisco为"Processor Pool Total: ... Used: ..."
+ * @param output 指令的输出
+ * @return 内存使用情况
+ * @author shenbowei
+ */
+func parseMemoryUsage(output string) []MemoryUsage {
+	memories := make([]MemoryUsage, 0)
+	slot := ""
+	memory := func() *MemoryUsage {
+		if len(memories) == 0 || memories[len(memories)-1].Slot != slot {
+			memories = append(memories, MemoryUsage{Slot: slot})
+		}
+		return &memories[len(memories)-1]
+	}
+	for _, line := range outputLines(output) {
+		if match := environmentSlotRegexp.FindStringSubmatch(line); match != nil {
+			slot = match[1]
+		} else if match := huaweiMemoryTotalRegexp.FindStringSubmatch(line); match != nil {
+			memory().Total, _ = strconv.ParseInt(match[1], 10, 64)
+		} else if match := huaweiMemoryUsedRegexp.FindStringSubmatch(line); match != nil {
+			memory().Used, _ = strconv.ParseInt(match[1], 10, 64)
+		} else if match := huaweiMemoryPercentRegexp.FindStringSubmatch(line); match != nil {
+			memory().Percent = parseEnvironmentValue(match[1])
+		} else if match := h3cMemoryRegexp.FindStringSubmatch(line); match != nil {
+			total, _ := strconv.ParseInt(match[1], 10, 64)
+			used, _ := strconv.ParseInt(match[2], 10, 64)
+			memory().Total, memory().Used = total*1024, used*1024
+		} else if match := ciscoMemoryRegexp.FindStringSubmatch(line); match != nil {
+			memory().Total, _ = strconv.ParseInt(match[1], 10, 64)
+			memory().Used, _ = strconv.ParseInt(match[2], 10, 64)
+		}
+	}
+	for i := range memories {
+		if memories[i].Percent == 0 && memories[i].Total > 0 {
+			memories[i].Percent = float64(memories[i].Used*1000/memories[i].Total) / 10
+		}
+	}
+	return memories
+}
+
+var (
+	ciscoFanRegexp               = regexp.MustCompile(`^\s*(?:Switch (\d+) )?FAN (\S+) is (.+)$`)
+	ciscoSystemTemperatureRegexp = regexp.MustCompile(`^\s*Switch (\d+): SYSTEM TEMPERATURE is`)
+	ciscoTemperatureRegexp       = regexp.MustCompile(`^\s*(.+?) Temperature Value:\s*([\d.]+)`)
+	ciscoPowerRegexp             = regexp.MustCompile(`^\s*(\d+)([A-Z])\s+(.+)$`)
+)
+
+/**
+ * 解析cisco的风扇、电源和温度（show environment all）：风扇为"Switch 1 FAN 1 is OK"，
+ * 温度在"Switch 1: SYSTEM TEMPERATURE is OK"之后为"Inlet Temperature Value: 30 Degree Celsius"、"Temperature State: GREEN"、
+ * "Yellow Threshold : 46 Degree Celsius"、"Red Threshold : 56 Degree Celsius"，电源为"SW PID Serial# Status ... Watts"的表格
+ * @param output 指令的输出
+ * @return 硬件环境信息（不包含CPU和内存）
+ * @author shenbowei
+ */
+func parseCiscoEnvironment(output string) Environment {
+	environment := Environment{Fans: make([]Fan, 0), PowerSupplies: make([]PowerSupply, 0), Temperatures: make([]Temperature, 0)}
+	slot := ""
+	var current *Temperature
+	for _, line := range outputLines(output) {
+		if match := ciscoFanRegexp.FindStringSubmatch(line); match != nil {
+			environment.Fans = append(environment.Fans, Fan{Slot: match[1], ID: match[2], Status: environmentStatus(match[3])})
+		} else if match := ciscoSystemTemperatureRegexp.FindStringSubmatch(line); match != nil {
+			slot, current = match[1], nil
+		} else if match := ciscoTemperatureRegexp.FindStringSubmatch(line); match != nil {
+			environment.Temperatures = append(environment.Temperatures, Temperature{Slot: slot, Sensor: match[1],
+				Celsius: parseEnvironmentValue(match[2]), Status: ENV_OK})
+			current = &environment.Temperatures[len(environment.Temperatures)-1]
+		} else if current != nil && strings.Contains(line, ":") {
+			value := strings.Fields(lineValue(line))
+			switch key := strings.TrimSpace(strings.SplitN(line, ":", 2)[0]); {
+			case key == "Temperature State" && len(value) > 0:
+				current.Status = environmentStatus(value[0])
+			case key == "Yellow Threshold" && len(value) > 0:
+				current.High = parseEnvironmentValue(value[0])
+			case key == "Red Threshold" && len(value) > 0:
+				current.Critical = parseEnvironmentValue(value[0])
+			}
+		} else if match := ciscoPowerRegexp.FindStringSubmatch(line); match != nil {
+			power := PowerSupply{Slot: match[1], ID: match[2]}
+			fields := strings.Fields(match[3])
+			if strings.HasPrefix(match[3], "Not Present") {
+				power.Status = ENV_ABSENT
+			} else if len(fields) >= 3 {
+				power.Status = environmentStatus(fields[2])
+				power.Watts = parseEnvironmentValue(fields[len(fields)-1])
+			}
+			environment.PowerSupplies = append(environment.PowerSupplies, power)
+		}
+	}
+	return environment
+}
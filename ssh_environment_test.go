@@ -0,0 +1,252 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const huaweiFanSample = `-------------------------------------------------------------------------
+ Slot  FanID   FanNum   Present  Register  Speed   Mode     Airflow
+-------------------------------------------------------------------------
+ 0     0       [1-2]    YES      YES       40%     AUTO     Side-to-Back
+ 1     0       [1-2]    YES      NO        0%      AUTO     Side-to-Back
+ 1     1       -        NO       -         -       -        -
+`
+
+const huaweiPowerSample = `--------------------------------------------------------------------------------
+ Slot    PowerID  Online   Mode   State      Power(W)
+--------------------------------------------------------------------------------
+ 0       PWR1     Present  AC     Supply     350.00
+ 0       PWR2     Absent   -      -          -
+ 1       PWR1     Present  AC     NotSupply  0.00
+`
+
+const huaweiTemperatureSample = `-------------------------------------------------------------------------------
+Slot  Card  Sensor Status    Current(C)  Lower(C)  Lower      Upper(C)  Upper
+                                                   Resume(C)            Resume(C)
+-------------------------------------------------------------------------------
+0     --    1      NORMAL    35          0         4          68        61
+1     --    1      ABNORMAL  70          0         4          68        61
+`
+
+const huaweiCPUSample = `CPU Usage Stat. Cycle: 60 (Second)
+CPU Usage            : 6% Max: 19%
+CPU Usage Stat. Time : 2026-10-18  10:00:00
+CPU utilization for five seconds: 7%: one minute: 6%: five minutes: 5%
+Max CPU Usage Stat. Time : 2026-10-17 08:12:30.
+`
+
+const huaweiMemorySample = `Memory utilization statistics at 2026-10-18 10:00:00+08:00
+System Total Memory Is: 536870912 bytes
+Total Memory Used Is: 229232504 bytes
+Memory Using Percentage Is: 42%
+`
+
+const h3cFanSample = ` Slot 1:
+ Fan 1:
+ State    : Normal
+ Airflow Direction: Port-to-power
+ Fan 2:
+ State    : Fault
+ Slot 2:
+ Fan 1:
+ State    : Absent
+`
+
+const h3cPowerSample = ` Slot 1:
+ PowerID State    Mode   Current(A)  Voltage(V)  Power(W)  FanDirection
+ 1       Normal   AC     --          --          120       Back-to-front
+ 2       Absent   --     --          --          --        --
+`
+
+const h3cEnvironmentSample = ` System temperature information (degree centigrade):
+ ----------------------------------------------------------------------
+ Slot  Sensor    Temperature  Lower  Warning  Alarm  Shutdown
+ 1     hotspot 1  38           0      80       92     NA
+ 1     inflow 1   85           0      80       92     NA
+ 2     hotspot 1  95           0      80       92     NA
+`
+
+const h3cCPUSample = `Slot 1 CPU 0 CPU usage:
+       6% in last 5 seconds
+       5% in last 1 minute
+       4% in last 5 minutes
+
+Slot 2 CPU 0 CPU usage:
+      91% in last 5 seconds
+      90% in last 1 minute
+      88% in last 5 minutes
+`
+
+const h3cMemorySample = `Memory statistics are measured in KB:
+Slot 1:
+             Total      Used      Free    Shared   Buffers    Cached   FreeRatio
+Mem:       1000000    400000    600000         0      1268    140528       60.0%
+-/+ Buffers/Cache:    345476    659200
+Swap:            0         0         0
+Slot 2:
+             Total      Used      Free    Shared   Buffers    Cached   FreeRatio
+Mem:       1000000    900000    100000         0      1268    140528       10.0%
+`
+
+const ciscoEnvironmentSample = `Switch 1 FAN 1 is OK
+Switch 1 FAN 2 is NOT PRESENT
+Switch 1 FAN 3 is FAULTY
+FAN PS-1 is OK
+Switch 1: SYSTEM TEMPERATURE is OK
+Inlet Temperature Value: 30 Degree Celsius
+Temperature State: GREEN
+Yellow Threshold : 46 Degree Celsius
+Red Threshold    : 56 Degree Celsius
+
+Hotspot Temperature Value: 50 Degree Celsius
+Temperature State: YELLOW
+Yellow Threshold : 105 Degree Celsius
+Red Threshold    : 125 Degree Celsius
+SW  PID                 Serial#     Status           Sys Pwr  PoE Pwr  Watts
+--  ------------------  ----------  ---------------  -------  -------  -----
+1A  PWR-C1-350WAC       DCB1234X0AB  OK              Good     n/a      350
+1B  Not Present
+`
+
+const ciscoCPUSample = `CPU utilization for five seconds: 5%/0%; one minute: 6%; five minutes: 7%`
+
+const ciscoMemorySample = `Processor Pool Total:  800000000 Used:  200000000 Free:  600000000`
+
+func TestParseHuaweiEnvironment(t *testing.T) {
+	fans := parseHuaweiFans(huaweiFanSample)
+	if !reflect.DeepEqual(fans, []Fan{{Slot: "0", ID: "0", Status: ENV_OK, Speed: 40}, {Slot: "1", ID: "0", Status: ENV_FAULT},
+		{Slot: "1", ID: "1", Status: ENV_ABSENT}}) {
+		t.Errorf("huawei fans:%+v", fans)
+	}
+	powers := parseHuaweiPowerSupplies(huaweiPowerSample)
+	if !reflect.DeepEqual(powers, []PowerSupply{{Slot: "0", ID: "PWR1", Status: ENV_OK, Watts: 350}, {Slot: "0", ID: "PWR2", Status: ENV_ABSENT},
+		{Slot: "1", ID: "PWR1", Status: ENV_FAULT}}) {
+		t.Errorf("huawei powers:%+v", powers)
+	}
+	temperatures := parseHuaweiTemperatures(huaweiTemperatureSample)
+	if len(temperatures) != 2 || temperatures[0] != (Temperature{Slot: "0", Sensor: "1", Celsius: 35, High: 68, Status: ENV_OK}) ||
+		temperatures[1].Status != ENV_FAULT {
+		t.Errorf("huawei temperatures:%+v", temperatures)
+	}
+	cpus := parseCPUUsage(huaweiCPUSample)
+	if !reflect.DeepEqual(cpus, []CPUUsage{{FiveSeconds: 7, OneMinute: 6, FiveMinutes: 5, reported: cpuAllPeriods}}) {
+		t.Errorf("huawei cpus:%+v", cpus)
+	}
+	memories := parseMemoryUsage(huaweiMemorySample)
+	if !reflect.DeepEqual(memories, []MemoryUsage{{Total: 536870912, Used: 229232504, Percent: 42}}) {
+		t.Errorf("huawei memories:%+v", memories)
+	}
+}
+
+func TestParseH3cEnvironment(t *testing.T) {
+	fans := parseHuaweiFans(h3cFanSample)
+	if !reflect.DeepEqual(fans, []Fan{{Slot: "1", ID: "1", Status: ENV_OK}, {Slot: "1", ID: "2", Status: ENV_FAULT},
+		{Slot: "2", ID: "1", Status: ENV_ABSENT}}) {
+		t.Errorf("h3c fans:%+v", fans)
+	}
+	powers := parseHuaweiPowerSupplies(h3cPowerSample)
+	if !reflect.DeepEqual(powers, []PowerSupply{{Slot: "1", ID: "1", Status: ENV_OK, Watts: 120}, {Slot: "1", ID: "2", Status: ENV_ABSENT}}) {
+		t.Errorf("h3c powers:%+v", powers)
+	}
+	temperatures := parseH3cTemperatures(h3cEnvironmentSample)
+	if len(temperatures) != 3 ||
+		temperatures[0] != (Temperature{Slot: "1", Sensor: "hotspot 1", Celsius: 38, High: 80, Critical: 92, Status: ENV_OK}) ||
+		temperatures[1].Status != ENV_WARNING || temperatures[2].Status != ENV_FAULT || temperatures[2].Slot != "2" {
+		t.Errorf("h3c temperatures:%+v", temperatures)
+	}
+	cpus := parseCPUUsage(h3cCPUSample)
+	if !reflect.DeepEqual(cpus, []CPUUsage{{Slot: "1", FiveSeconds: 6, OneMinute: 5, FiveMinutes: 4, reported: cpuAllPeriods},
+		{Slot: "2", FiveSeconds: 91, OneMinute: 90, FiveMinutes: 88, reported: cpuAllPeriods}}) {
+		t.Errorf("h3c cpus:%+v", cpus)
+	}
+	memories := parseMemoryUsage(h3cMemorySample)
+	if !reflect.DeepEqual(memories, []MemoryUsage{{Slot: "1", Total: 1024000000, Used: 409600000, Percent: 40},
+		{Slot: "2", Total: 1024000000, Used: 921600000, Percent: 90}}) {
+		t.Errorf("h3c memories:%+v", memories)
+	}
+}
+
+func TestParseCiscoEnvironment(t *testing.T) {
+	environment := parseCiscoEnvironment(ciscoEnvironmentSample)
+	if !reflect.DeepEqual(environment.Fans, []Fan{{Slot: "1", ID: "1", Status: ENV_OK}, {Slot: "1", ID: "2", Status: ENV_ABSENT},
+		{Slot: "1", ID: "3", Status: ENV_FAULT}, {ID: "PS-1", Status: ENV_OK}}) {
+		t.Errorf("cisco fans:%+v", environment.Fans)
+	}
+	if !reflect.DeepEqual(environment.Temperatures, []Temperature{
+		{Slot: "1", Sensor: "Inlet", Celsius: 30, High: 46, Critical: 56, Status: ENV_OK},
+		{Slot: "1", Sensor: "Hotspot", Celsius: 50, High: 105, Critical: 125, Status: ENV_WARNING}}) {
+		t.Errorf("cisco temperatures:%+v", environment.Temperatures)
+	}
+	if !reflect.DeepEqual(environment.PowerSupplies, []PowerSupply{{Slot: "1", ID: "A", Status: ENV_OK, Watts: 350},
+		{Slot: "1", ID: "B", Status: ENV_ABSENT}}) {
+		t.Errorf("cisco powers:%+v", environment.PowerSupplies)
+	}
+	if cpus := parseCPUUsage(ciscoCPUSample); !reflect.DeepEqual(cpus, []CPUUsage{{FiveSeconds: 5, OneMinute: 6, FiveMinutes: 7, reported: cpuAllPeriods}}) {
+		t.Errorf("cisco cpus:%+v", cpus)
+	}
+	if memories := parseMemoryUsage(ciscoMemorySample); !reflect.DeepEqual(memories, []MemoryUsage{{Total: 800000000, Used: 200000000, Percent: 25}}) {
+		t.Errorf("cisco memories:%+v", memories)
+	}
+}
+
+func TestEnvironmentAlarms(t *testing.T) {
+	environment := Environment{
+		Fans:          parseHuaweiFans(h3cFanSample),
+		PowerSupplies: parseHuaweiPowerSupplies(huaweiPowerSample),
+		Temperatures:  parseH3cTemperatures(h3cEnvironmentSample),
+		CPUs:          parseCPUUsage(h3cCPUSample),
+		Memory:        parseMemoryUsage(h3cMemorySample),
+	}
+	expected := []string{"fan 1/2: fault", "power 1/PWR1: fault", "temperature 1/inflow 1: warning 85C", "temperature 2/hotspot 1: fault 95C",
+		"cpu 2: 88% >= 80%", "memory 2: 90% >= 85%"}
+	if alarms := environment.Alarms(DefaultEnvironmentThresholds); !reflect.DeepEqual(alarms, expected) {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+	if alarms := environment.Alarms(EnvironmentThresholds{}); len(alarms) != 4 {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+	//华为只有"CPU Usage : 92%"时使用1分钟的利用率
+	environment = Environment{CPUs: parseCPUUsage(`CPU Usage Stat. Cycle: 60 (Second)
+CPU Usage            : 92% Max: 99%
+CPU Usage Stat. Time : 2026-10-18  10:00:00
+`)}
+	if alarms := environment.Alarms(DefaultEnvironmentThresholds); !reflect.DeepEqual(alarms, []string{"cpu: 92% >= 80%"}) {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+	//5分钟的利用率为0%时不使用5秒的利用率
+	environment = Environment{CPUs: parseCPUUsage(`Slot 1 CPU 0 CPU usage:
+      95% in last 5 seconds
+       0% in last 1 minute
+       0% in last 5 minutes
+`)}
+	if alarms := environment.Alarms(DefaultEnvironmentThresholds); len(alarms) != 0 {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+	environment = Environment{CPUs: parseCPUUsage("CPU utilization for five seconds: 95%/0%; one minute: 0%; five minutes: 0%")}
+	if alarms := environment.Alarms(DefaultEnvironmentThresholds); len(alarms) != 0 {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+	//手动创建的CPUUsage按不为0判断
+	environment = Environment{CPUs: []CPUUsage{{FiveSeconds: 95}}}
+	if alarms := environment.Alarms(DefaultEnvironmentThresholds); !reflect.DeepEqual(alarms, []string{"cpu: 95% >= 80%"}) {
+		t.Errorf("unexpected alarms:%q", alarms)
+	}
+}
+
+func TestSessionManagerGetEnvironment(t *testing.T) {
+	fake := newFakeSwitch(t, "SW1#", map[string]string{
+		CiscoEnvironmentCmd: ciscoEnvironmentSample,
+		CiscoCPUCmd:         ciscoCPUSample,
+		CiscoMemoryCmd:      ciscoMemorySample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	environment, err := manager.GetEnvironment(NewDevice("admin", "admin", fake.addr(), CISCO))
+	if err != nil || len(environment.Fans) != 4 || len(environment.PowerSupplies) != 2 || len(environment.CPUs) != 1 ||
+		environment.Memory[0].Percent != 25 {
+		t.Errorf("GetEnvironment environment:%+v err:%v", environment, err)
+	}
+}
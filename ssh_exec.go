@@ -0,0 +1,276 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"strings"
+	"time"
+)
+
+// 指令的执行方式
+const (
+	SHELL_MODE = "shell" //通过交互式的PTY shell执行（默认），需要根据提示符截取输出
+	EXEC_MODE  = "exec"  //每条指令通过独立的exec通道执行（session.Run），输出干净且有退出码，设备拒绝时自动回退到shell
+)
+
+var (
+	errExecRejected = errors.New("ssh: exec request is rejected by the device")
+	ErrExecTimeout  = errors.New("ssh: exec command timeout")
+)
+
+/**
+ * exec方式执行一条指令的结果
+ * @attr Command:执行的指令，Output:标准输出和标准错误，ExitStatus:退出码（通过shell执行或设备未返回退出码时为-1）
+ * @author shenbowei
+ */
+type ExecResult struct {
+	Command    string
+	Output     string
+	ExitStatus int
+}
+
+/**
+ * 在ssh连接上打开一个exec通道执行指令，超时后关闭通道
+ * @param client ssh连接, cmd 执行的指令, timeout 执行的超时时间
+ * @return 执行结果，执行的错误（设备拒绝exec请求时返回errExecRejected，超时返回ErrExecTimeout）
+ * @author shenbowei
+ */
+func runExec(client *ssh.Client, cmd string, timeout time.Duration) (ExecResult, error) {
+	result := ExecResult{Command: cmd, ExitStatus: -1}
+	session, err := client.NewSession()
+	if err != nil {
+		//设备限制了每个连接的通道数量时拒绝打开新的通道
+		if _, ok := err.(*ssh.OpenChannelError); ok {
+			return result, errExecRejected
+		}
+		return result, err
+	}
+	defer session.Close()
+	//标准输出和标准错误由不同的协程写入，需要分别缓存
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Start(cmd); err != nil {
+		//连接已断开时返回io.EOF，否则为设备拒绝了exec请求
+		if err == io.EOF {
+			return result, err
+		}
+		return result, errExecRejected
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		//关闭通道后Wait在设备应答或连接断开时返回，不再读取仍可能被写入的输出
+		session.Close()
+		return result, ErrExecTimeout
+	}
+	result.Output = stdout.String() + stderr.String()
+	switch exitErr := err.(type) {
+	case nil:
+		result.ExitStatus = 0
+	case *ssh.ExitError:
+		result.ExitStatus = exitErr.ExitStatus()
+	case *ssh.ExitMissingError:
+		//部分设备接受exec请求后不执行指令直接关闭通道
+		if result.Output == "" {
+			return result, errExecRejected
+		}
+	default:
+		return result, err
+	}
+	return result, nil
+}
+
+/**
+ * 通过exec通道逐条执行指令，占用连接池的名额以限制同时打开的通道数量
+ * @param ctx 排队等待的上下文, cmds 执行的指令(可以多个)
+ * @return 每条指令的执行结果，执行的错误（设备不支持exec时返回errExecRejected，指令超时返回ErrExecTimeout）
+ * @author shenbowei
+ */
+func (this *devicePool) execCommands(ctx context.Context, cmds ...string) ([]ExecResult, error) {
+	//telnet没有exec通道
+	if this.client == nil || this.isExecRejected() {
+		return nil, errExecRejected
+	}
+	select {
+	case this.slots <- struct{}{}:
+	case <-this.closing:
+		return nil, errPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	this.locker.Lock()
+	this.inUse++
+	this.locker.Unlock()
+	defer this.releaseSlot()
+
+	results := make([]ExecResult, 0, len(cmds))
+	for _, cmd := range cmds {
+		result, err := runExec(this.client, cmd, this.config.commandTimeout)
+		if err == ErrExecTimeout {
+			return nil, err
+		}
+		if err == errExecRejected {
+			this.config.logger.Debug("Device<%s> rejects exec request, fallback to shell", this.device)
+			this.locker.Lock()
+			this.execRejected = true
+			this.locker.Unlock()
+			return nil, err
+		}
+		if err != nil {
+			//无法打开通道说明连接已不可用，由调用者重新连接
+			this.locker.Lock()
+			this.broken = true
+			this.locker.Unlock()
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+/**
+ * 通过一次性的exec通道识别设备的品牌，不打开shell：先根据ssh服务端版本号和banner识别，可信度不足时依次执行dis version和show version，
+ * 设备拒绝exec请求时记录下来，之后直接使用shell
+ * @return true:识别成功（结果保存在detection中），false:需要通过shell识别
+ * @author shenbowei
+ */
+func (this *devicePool) detectBrandByExec() bool {
+	detection := detectBrand(string(this.client.ServerVersion()), this.banner, "")
+	if detection.Confidence < minBrandConfidence {
+		detection = BrandDetection{Confidence: 1, Source: BRAND_SOURCE_COMMAND}
+		for _, cmd := range []string{"dis version", "show version"} {
+			result, err := runExec(this.client, cmd, this.config.commandTimeout)
+			if err == errExecRejected {
+				this.config.logger.Debug("Device<%s> rejects exec request, detect the brand in shell", this.device)
+				this.locker.Lock()
+				this.execRejected = true
+				this.locker.Unlock()
+				return false
+			}
+			if err != nil {
+				return false
+			}
+			if detection.Brand = brandFromVersion(result.Output); detection.Brand != "" {
+				break
+			}
+		}
+		if detection.Brand == "" {
+			return false
+		}
+	}
+	this.config.logger.Debug("The switch brand is <%s> (%s, confidence %.2f).", detection.Brand, detection.Source, detection.Confidence)
+	this.locker.Lock()
+	this.detection = detection
+	this.locker.Unlock()
+	return true
+}
+
+/**
+ * 判断设备是否已经拒绝过exec请求
+ * @return true:设备不支持exec
+ * @author shenbowei
+ */
+func (this *devicePool) isExecRejected() bool {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.execRejected
+}
+
+/**
+ * 获取设备指令的执行方式：优先使用Device.ExecMode，其次是WithExecMode按品牌的设置，最后是WithExecMode的全局设置，默认SHELL_MODE
+ * @param device 设备的身份信息, brand 设备的品牌（Device.Brand为空时为识别出的品牌）
+ * @return SHELL_MODE 或 EXEC_MODE
+ * @author shenbowei
+ */
+func (this *sessionConfig) getExecMode(device Device, brand string) string {
+	if device.ExecMode != "" {
+		return device.ExecMode
+	}
+	if mode, ok := this.execModes[brand]; ok {
+		return mode
+	}
+	if mode, ok := this.execModes[""]; ok {
+		return mode
+	}
+	return SHELL_MODE
+}
+
+/**
+ * 获取设备指令的执行方式，WithExecMode按品牌设置且设备的品牌需要自动识别时，先连接设备再按识别出的品牌查找。
+ * 连接池优先通过exec通道识别品牌，使用EXEC_MODE的设备不会为识别品牌打开shell
+ * @param device 设备的身份信息
+ * @return SHELL_MODE 或 EXEC_MODE，连接设备的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) resolveExecMode(device Device) (string, error) {
+	brand := device.Brand
+	if device.ExecMode == "" && brand == "" && len(this.config.execModes) > 0 {
+		pool, err := this.getPool(device)
+		if err != nil {
+			return "", err
+		}
+		brand = pool.getBrand()
+	}
+	return this.config.getExecMode(device, brand), nil
+}
+
+/**
+ * 按设备的执行方式逐条执行指令，返回每条指令的执行结果。EXEC_MODE下设备拒绝exec请求时自动回退到shell（退出码为-1）
+ * @param device 设备的身份信息, cmds 执行的指令(可以多个)
+ * @return 每条指令的执行结果，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) RunDeviceExec(device Device, cmds ...string) ([]ExecResult, error) {
+	mode, err := this.resolveExecMode(device)
+	if err != nil {
+		return nil, err
+	}
+	if mode == EXEC_MODE {
+		pool, err := this.getPool(device)
+		if err != nil {
+			return nil, err
+		}
+		results, err := pool.execCommands(context.Background(), cmds...)
+		if err == nil || (err != errExecRejected && !pool.isBroken()) {
+			return results, err
+		}
+	}
+	sshSession, err := this.CheckoutSession(context.Background(), device)
+	if err != nil {
+		this.config.logger.Error("GetSession<%s> error:%s", device, err)
+		return nil, err
+	}
+	defer this.ReleaseSession(sshSession)
+	results := make([]ExecResult, 0, len(cmds))
+	for _, cmd := range cmds {
+		sshSession.WriteChannel(cmd)
+		output := filterResult(sshSession.ReadChannelTiming(this.config.commandTimeout), cmd)
+		results = append(results, ExecResult{Command: cmd, Output: output, ExitStatus: -1})
+	}
+	return results, nil
+}
+
+/**
+ * 将exec方式的执行结果拼接成与shell方式相同的形式（指令行后跟随指令的输出）
+ * @param results 每条指令的执行结果
+ * @return 拼接后的输出
+ * @author shenbowei
+ */
+func joinExecResults(results []ExecResult) string {
+	output := ""
+	for _, result := range results {
+		output += result.Command + "\n" + result.Output
+		if !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+	}
+	return output
+}
@@ -0,0 +1,358 @@
+package ssh
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+/**
+ * 设备的基本信息
+ * @attr Vendor:品牌（huawei,h3c,cisco），Platform:操作系统（VRP、Comware、IOS、IOS-XE、NX-OS），Model:型号，
+ *       SoftwareVersion:软件版本，Patch:补丁版本，SerialNumbers:各成员设备（框式为机框）的序列号，Uptime:运行时间，
+ *       Hostname:设备名称，Members:堆叠成员或机框的槽位
+ * @author shenbowei
+ */
+type Facts struct {
+	Vendor          string
+	Platform        string
+	Model           string
+	SoftwareVersion string
+	Patch           string
+	SerialNumbers   []string
+	Uptime          time.Duration
+	Hostname        string
+	Members         []ChassisMember
+}
+
+/**
+ * 堆叠成员或机框中的槽位
+ * @attr Slot:槽位号（堆叠成员号），Model:型号，SerialNumber:序列号，Role:角色（如Master、Standby），Status:状态
+ * @author shenbowei
+ */
+type ChassisMember struct {
+	Slot         string
+	Model        string
+	SerialNumber string
+	Role         string
+	Status       string
+}
+
+// 获取设备基本信息时各品牌执行的指令
+var (
+	HuaweiVersionCmd  = "display version"
+	HuaweiDeviceCmd   = "display device"
+	HuaweiEsnCmd      = "display esn"
+	HuaweiSysnameCmd  = "display current-configuration | include sysname"
+	H3cManuinfoCmd    = "display device manuinfo"
+	CiscoVersionCmd   = "show version"
+	CiscoInventoryCmd = "show inventory"
+)
+
+/**
+ * 获取各品牌设备基本信息时执行的指令
+ * @return 品牌对应的指令
+ * @author shenbowei
+ */
+func factsCommands() map[string][]string {
+	return map[string][]string{
+		HUAWEI: {HuaweiVersionCmd, HuaweiDeviceCmd, HuaweiEsnCmd, HuaweiSysnameCmd},
+		H3C:    {HuaweiVersionCmd, HuaweiDeviceCmd, H3cManuinfoCmd, HuaweiSysnameCmd},
+		CISCO:  {CiscoVersionCmd, CiscoInventoryCmd},
+	}
+}
+
+/**
+ * 获取设备的基本信息（型号、软件版本、序列号、运行时间、设备名称、堆叠成员等）
+ * @param device 设备的身份信息
+ * @return 设备的基本信息，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetFacts(device Device) (Facts, error) {
+	brand, outputs, err := this.runBrandCommands(device, factsCommands())
+	if err != nil {
+		return Facts{}, err
+	}
+	switch brand {
+	case HUAWEI:
+		return parseHuaweiFacts(outputs[HuaweiVersionCmd], outputs[HuaweiDeviceCmd], outputs[HuaweiEsnCmd], outputs[HuaweiSysnameCmd]), nil
+	case H3C:
+		return parseH3cFacts(outputs[HuaweiVersionCmd], outputs[HuaweiDeviceCmd], outputs[H3cManuinfoCmd], outputs[HuaweiSysnameCmd]), nil
+	default:
+		return parseCiscoFacts(outputs[CiscoVersionCmd], outputs[CiscoInventoryCmd]), nil
+	}
+}
+
+var (
+	vrpVersionRegexp     = regexp.MustCompile(`Version\s+([\d.]+)(?:\s*\(([^)]*)\))?`)
+	comwareVersionRegexp = regexp.MustCompile(`Version\s+([^,\s]+)(?:,\s*Release\s+(\S+))?`)
+	huaweiUptimeRegexp   = regexp.MustCompile(`^(?i:huawei|h3c)\s+(\S+).*\suptime is (.*)$`)
+	huaweiSlotRegexp     = regexp.MustCompile(`(?i)slot\s*(\d+)`)
+	ciscoVersionRegexp   = regexp.MustCompile(`(?i)version\s+([^\s,]+)`)
+	ciscoUptimeRegexp    = regexp.MustCompile(`^(\S+)\s+uptime is (.*)$`)
+	ciscoModelRegexp     = regexp.MustCompile(`^[Cc]isco\s+(\S+)\s.*(?:processor|chassis)`)
+	ciscoStackRegexp     = regexp.MustCompile(`^(\*?)\s*(\d+)\s+\d+\s+(\S+)\s+(\S+)`)
+	ciscoInventoryRegexp = regexp.MustCompile(`NAME:\s*"([^"]*)"`)
+	ciscoSwitchRegexp    = regexp.MustCompile(`^(?i:switch\s*)?(\d+)$`)
+)
+
+/**
+ * 解析华为设备的基本信息
+ * @param version display version的输出, device display device的输出, esn display esn的输出, sysname 配置中sysname的输出
+ * @return 设备的基本信息
+ * @author shenbowei
+ */
+func parseHuaweiFacts(version, device, esn, sysname string) Facts {
+	facts := Facts{Vendor: HUAWEI, Platform: "VRP", Hostname: parseSysname(sysname)}
+	for _, line := range outputLines(version) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.Contains(trimmed, "VRP") && strings.Contains(trimmed, "Version") && facts.SoftwareVersion == "":
+			if match := vrpVersionRegexp.FindStringSubmatch(trimmed); match != nil {
+				//括号中为产品版本，如"S5720 V200R011C10SPC500"
+				facts.SoftwareVersion = match[1]
+				if fields := strings.Fields(match[2]); len(fields) > 0 {
+					facts.SoftwareVersion = fields[len(fields)-1]
+				}
+			}
+		case strings.HasPrefix(strings.ToLower(trimmed), "patch version"):
+			facts.Patch = lineValue(trimmed)
+		case facts.Model == "" && huaweiUptimeRegexp.MatchString(trimmed):
+			match := huaweiUptimeRegexp.FindStringSubmatch(trimmed)
+			facts.Model = match[1]
+			facts.Uptime = parseUptime(match[2])
+		}
+	}
+	facts.Members = parseHuaweiDevice(device)
+	serials := map[string]string{}
+	for _, line := range outputLines(esn) {
+		if !strings.Contains(strings.ToUpper(line), "ESN") || lineValue(line) == "" {
+			continue
+		}
+		slot := ""
+		if match := huaweiSlotRegexp.FindStringSubmatch(line); match != nil {
+			slot = match[1]
+		}
+		serials[slot] = lineValue(line)
+		facts.SerialNumbers = append(facts.SerialNumbers, lineValue(line))
+	}
+	for i := range facts.Members {
+		facts.Members[i].SerialNumber = serials[facts.Members[i].Slot]
+	}
+	if len(facts.Members) == 1 && len(facts.SerialNumbers) == 1 {
+		//单机设备的ESN可能不带槽位号（"ESN of device: ..."）
+		facts.Members[0].SerialNumber = facts.SerialNumbers[0]
+	}
+	if facts.Model == "" && len(facts.Members) > 0 {
+		facts.Model = facts.Members[0].Model
+	}
+	return facts
+}
+
+/**
+ * 解析华为display device的输出，按表头定位各列（S系列为Slot/Sub/Type/.../Primary，CE系列为Slot/Card/Type/.../Status/Role）
+ * @param output display device的输出
+ * @return 各槽位（堆叠成员）的信息，只包含槽位行，不包含电源、风扇等子卡
+ * @author shenbowei
+ */
+func parseHuaweiDevice(output string) []ChassisMember {
+	members := make([]ChassisMember, 0)
+	columns := map[string]int{}
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "Slot" {
+			for i, field := range fields {
+				columns[field] = i
+			}
+			continue
+		}
+		if len(columns) == 0 || len(fields) < len(columns) || !isDigits(fields[0]) {
+			continue
+		}
+		member := ChassisMember{Slot: fields[0], Model: fields[columns["Type"]]}
+		if index, ok := columns["Primary"]; ok {
+			member.Role = fields[index]
+		} else if index, ok := columns["Role"]; ok {
+			member.Role = fields[index]
+		}
+		if index, ok := columns["Status"]; ok {
+			member.Status = fields[index]
+		} else if index, ok := columns["Alarm"]; ok {
+			member.Status = fields[index]
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+/**
+ * 解析h3c设备的基本信息
+ * @param version display version的输出, device display device的输出, manuinfo display device manuinfo的输出, sysname 配置中sysname的输出
+ * @return 设备的基本信息
+ * @author shenbowei
+ */
+func parseH3cFacts(version, device, manuinfo, sysname string) Facts {
+	facts := Facts{Vendor: H3C, Platform: "Comware", Hostname: parseSysname(sysname)}
+	for _, line := range outputLines(version) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.Contains(trimmed, "Comware") && strings.Contains(trimmed, "Version") && facts.SoftwareVersion == "":
+			if match := comwareVersionRegexp.FindStringSubmatch(trimmed); match != nil {
+				facts.SoftwareVersion = strings.TrimSpace(match[1] + " Release " + match[2])
+				if match[2] == "" {
+					facts.SoftwareVersion = match[1]
+				}
+			}
+		case facts.Model == "" && huaweiUptimeRegexp.MatchString(trimmed):
+			match := huaweiUptimeRegexp.FindStringSubmatch(trimmed)
+			facts.Model = match[1]
+			facts.Uptime = parseUptime(match[2])
+		}
+	}
+	//Slot Type State Subslot Soft-Ver Patch-Ver，表头中的"Soft Ver"、"Patch Ver"含有空格，按位置解析
+	for _, line := range outputLines(device) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !isDigits(fields[0]) {
+			continue
+		}
+		facts.Members = append(facts.Members, ChassisMember{Slot: fields[0], Model: fields[1], Role: fields[2]})
+		if len(fields) >= 6 && facts.Patch == "" && !strings.EqualFold(fields[5], "none") {
+			facts.Patch = fields[5]
+		}
+	}
+	//manuinfo按"Slot 1 CPU 0:"、"Fan 1:"等分段，只取槽位段的序列号
+	slot := ""
+	for _, line := range outputLines(manuinfo) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") {
+			slot = ""
+			if match := huaweiSlotRegexp.FindStringSubmatch(trimmed); match != nil && strings.HasPrefix(strings.ToLower(trimmed), "slot") {
+				slot = match[1]
+			}
+			continue
+		}
+		if slot == "" || !strings.HasPrefix(trimmed, "DEVICE_SERIAL_NUMBER") {
+			continue
+		}
+		serial := lineValue(trimmed)
+		facts.SerialNumbers = append(facts.SerialNumbers, serial)
+		for i := range facts.Members {
+			if facts.Members[i].Slot == slot {
+				facts.Members[i].SerialNumber = serial
+			}
+		}
+	}
+	if facts.Model == "" && len(facts.Members) > 0 {
+		facts.Model = facts.Members[0].Model
+	}
+	return facts
+}
+
+/**
+ * 解析配置中的sysname
+ * @param output display current-configuration | include sysname的输出
+ * @return 设备名称，没有时返回""
+ * @author shenbowei
+ */
+func parseSysname(output string) string {
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "sysname" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+/**
+ * 解析cisco设备的基本信息
+ * @param version show version的输出, inventory show inventory的输出
+ * @return 设备的基本信息
+ * @author shenbowei
+ */
+func parseCiscoFacts(version, inventory string) Facts {
+	facts := Facts{Vendor: CISCO}
+	systemSerial := ""
+	inStack := false
+	for _, line := range outputLines(version) {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case facts.Platform == "" && strings.HasPrefix(lower, "cisco") && strings.Contains(lower, "software"):
+			facts.Platform = "IOS"
+			if strings.Contains(lower, "nx-os") {
+				facts.Platform = "NX-OS"
+			} else if strings.Contains(lower, "ios xe") || strings.Contains(lower, "ios-xe") || strings.Contains(lower, "iosxe") {
+				facts.Platform = "IOS-XE"
+			}
+			if match := ciscoVersionRegexp.FindStringSubmatch(trimmed); match != nil {
+				facts.SoftwareVersion = match[1]
+			}
+		case facts.Platform == "NX-OS" && facts.SoftwareVersion == "" &&
+			(strings.HasPrefix(lower, "nxos:") || strings.HasPrefix(lower, "system:")):
+			if match := ciscoVersionRegexp.FindStringSubmatch(trimmed); match != nil {
+				facts.SoftwareVersion = match[1]
+			}
+		case strings.HasPrefix(lower, "device name:"):
+			facts.Hostname = lineValue(trimmed)
+		case strings.HasPrefix(lower, "kernel uptime is"):
+			facts.Uptime = parseUptime(trimmed)
+		case facts.Uptime == 0 && ciscoUptimeRegexp.MatchString(trimmed):
+			match := ciscoUptimeRegexp.FindStringSubmatch(trimmed)
+			facts.Hostname = match[1]
+			facts.Uptime = parseUptime(match[2])
+		case strings.HasPrefix(lower, "model number"):
+			facts.Model = lineValue(trimmed)
+		case facts.Model == "" && ciscoModelRegexp.MatchString(trimmed):
+			facts.Model = ciscoModelRegexp.FindStringSubmatch(trimmed)[1]
+		case strings.HasPrefix(lower, "system serial number"):
+			systemSerial = lineValue(trimmed)
+		case systemSerial == "" && strings.HasPrefix(lower, "processor board id"):
+			systemSerial = strings.TrimSpace(trimmed[len("processor board id"):])
+		case strings.HasPrefix(trimmed, "Switch") && strings.Contains(trimmed, "Model"):
+			inStack = true
+		case inStack && ciscoStackRegexp.MatchString(trimmed):
+			match := ciscoStackRegexp.FindStringSubmatch(trimmed)
+			member := ChassisMember{Slot: match[2], Model: match[3], Role: "Member"}
+			if match[1] == "*" {
+				member.Role = "Master"
+			}
+			facts.Members = append(facts.Members, member)
+		}
+	}
+	//show inventory中的机框（"1"、"Switch 1"、"Chassis"）的序列号
+	name := ""
+	for _, line := range outputLines(inventory) {
+		if match := ciscoInventoryRegexp.FindStringSubmatch(line); match != nil {
+			name = match[1]
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(line), "PID:") {
+			continue
+		}
+		serial := ""
+		for _, part := range strings.Split(line, ",") {
+			if strings.HasPrefix(strings.TrimSpace(part), "SN:") {
+				serial = lineValue(part)
+			}
+		}
+		slot := ""
+		if match := ciscoSwitchRegexp.FindStringSubmatch(strings.TrimSpace(name)); match != nil {
+			slot = match[1]
+		} else if !strings.EqualFold(name, "chassis") {
+			continue
+		}
+		if serial != "" {
+			facts.SerialNumbers = append(facts.SerialNumbers, serial)
+		}
+		for i := range facts.Members {
+			if facts.Members[i].Slot == slot {
+				facts.Members[i].SerialNumber = serial
+			}
+		}
+	}
+	if len(facts.SerialNumbers) == 0 && systemSerial != "" {
+		facts.SerialNumbers = []string{systemSerial}
+	}
+	return facts
+}
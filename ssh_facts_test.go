@@ -0,0 +1,167 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const huaweiVersionSample = `Huawei Versatile Routing Platform Software
+VRP (R) software, Version 5.170 (S5720 V200R011C10SPC500)
+Copyright (C) 2000-2018 HUAWEI TECH CO., LTD
+HUAWEI S5720-28X-SI-AC Routing Switch uptime is 0 week, 3 days, 21 hours, 35 minutes
+Patch Version: V200R011SPH011
+
+ES5D2T28S005 0(Master) : uptime is 0 week, 3 days, 21 hours, 34 minutes
+DDR             Memory Size : 512   M bytes
+`
+
+const huaweiDeviceSample = `S5720-28X-SI-AC's Device status:
+Slot Sub  Type                   Online    Power    Register     Alarm     Primary
+- - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+0    -    S5720-28X-SI-AC        Present   PowerOn  Registered   Normal    Master
+     PWR1 POWER                  Present   PowerOn  Registered   Normal    NA
+1    -    S5720-28X-SI-AC        Present   PowerOn  Registered   Normal    Standby
+`
+
+const huaweiEsnSample = `ESN of slot 0: 2102351931P0K1000123
+ESN of slot 1: 2102351931P0K1000456
+`
+
+const h3cVersionSample = `H3C Comware Software, Version 7.1.070, Release 6318P01
+Copyright (c) 2004-2021 New H3C Technologies Co., Ltd. All rights reserved.
+H3C S5130S-28S-EI uptime is 1 week, 0 days, 2 hours, 1 minute
+Last reboot reason : Cold reboot
+
+Boot image: flash:/s5130s_ei-cmw710-boot-r6318p01.bin
+`
+
+const h3cDeviceSample = `Slot Type                  State    Subslot  Soft Ver             Patch Ver
+1    S5130S-28S-EI         Master   0        S5130S-EI-6318P01    6318P01H01
+2    S5130S-28S-EI         Standby  0        S5130S-EI-6318P01    6318P01H01
+`
+
+const h3cManuinfoSample = `Slot 1 CPU 0:
+DEVICE_NAME          : S5130S-28S-EI
+DEVICE_SERIAL_NUMBER : 210235A1JTH123000012
+MAC_ADDRESS          : 0CDA-41B1-2345
+Fan 1:
+DEVICE_SERIAL_NUMBER : NONE
+Slot 2 CPU 0:
+DEVICE_NAME          : S5130S-28S-EI
+DEVICE_SERIAL_NUMBER : 210235A1JTH123000034
+`
+
+const ciscoVersionSample = `Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(2)E7, RELEASE SOFTWARE (fc3)
+Technical Support: http://www.cisco.com/techsupport
+Copyright (c) 1986-2017 by Cisco Systems, Inc.
+
+ROM: Bootstrap program is C2960X boot loader
+BOOTLDR: C2960X Boot Loader (C2960X-HBOOT-M) Version 15.2(3r)E1, RELEASE SOFTWARE (fc1)
+
+Access-SW1 uptime is 1 year, 2 weeks, 3 days, 4 hours, 5 minutes
+System returned to ROM by power-on
+System image file is "flash:c2960x-universalk9-mz.152-2.E7.bin"
+
+cisco WS-C2960X-48FPD-L (APM86XXX) processor (revision B0) with 524288K bytes of memory.
+Processor board ID FOC1234X5YZ
+Model number                    : WS-C2960X-48FPD-L
+System serial number            : FOC1234X5YZ
+
+Switch Ports Model                     SW Version            SW Image
+------ ----- -----                     ----------            ----------
+*    1 52    WS-C2960X-48FPD-L         15.2(2)E7             C2960X-UNIVERSALK9-M
+     2 52    WS-C2960X-48FPD-L         15.2(2)E7             C2960X-UNIVERSALK9-M
+
+Configuration register is 0xF
+`
+
+const ciscoInventorySample = `NAME: "1", DESCR: "WS-C2960X-48FPD-L"
+PID: WS-C2960X-48FPD-L , VID: V05  , SN: FOC1234X5YZ
+
+NAME: "Switch 1 - FlexStackPlus Module", DESCR: "Stacking Module"
+PID: C2960X-STACK      , VID: V01  , SN: FOC9999X9YZ
+
+NAME: "2", DESCR: "WS-C2960X-48FPD-L"
+PID: WS-C2960X-48FPD-L , VID: V05  , SN: FOC2222X5YZ
+`
+
+func TestParseHuaweiFacts(t *testing.T) {
+	facts := parseHuaweiFacts(huaweiVersionSample, huaweiDeviceSample, huaweiEsnSample, " sysname Core-SW1\n")
+	if facts.Platform != "VRP" || facts.Model != "S5720-28X-SI-AC" || facts.SoftwareVersion != "V200R011C10SPC500" ||
+		facts.Patch != "V200R011SPH011" || facts.Hostname != "Core-SW1" {
+		t.Errorf("unexpected facts:%+v", facts)
+	}
+	if facts.Uptime != 3*24*time.Hour+21*time.Hour+35*time.Minute {
+		t.Errorf("uptime=%s", facts.Uptime)
+	}
+	if len(facts.Members) != 2 || facts.Members[1].Role != "Standby" || facts.Members[1].SerialNumber != "2102351931P0K1000456" {
+		t.Errorf("unexpected members:%+v", facts.Members)
+	}
+	if strings.Join(facts.SerialNumbers, ",") != "2102351931P0K1000123,2102351931P0K1000456" {
+		t.Errorf("unexpected serial numbers:%v", facts.SerialNumbers)
+	}
+}
+
+func TestParseH3cFacts(t *testing.T) {
+	facts := parseH3cFacts(h3cVersionSample, h3cDeviceSample, h3cManuinfoSample, " sysname Agg-SW1\n")
+	if facts.Platform != "Comware" || facts.Model != "S5130S-28S-EI" || facts.SoftwareVersion != "7.1.070 Release 6318P01" ||
+		facts.Patch != "6318P01H01" || facts.Hostname != "Agg-SW1" || facts.Uptime != 7*24*time.Hour+2*time.Hour+time.Minute {
+		t.Errorf("unexpected facts:%+v", facts)
+	}
+	if len(facts.Members) != 2 || facts.Members[0].Role != "Master" || facts.Members[1].SerialNumber != "210235A1JTH123000034" {
+		t.Errorf("unexpected members:%+v", facts.Members)
+	}
+	if strings.Join(facts.SerialNumbers, ",") != "210235A1JTH123000012,210235A1JTH123000034" {
+		t.Errorf("unexpected serial numbers:%v", facts.SerialNumbers)
+	}
+}
+
+func TestParseCiscoFacts(t *testing.T) {
+	facts := parseCiscoFacts(ciscoVersionSample, ciscoInventorySample)
+	if facts.Platform != "IOS" || facts.Model != "WS-C2960X-48FPD-L" || facts.SoftwareVersion != "15.2(2)E7" || facts.Hostname != "Access-SW1" {
+		t.Errorf("unexpected facts:%+v", facts)
+	}
+	if facts.Uptime != 365*24*time.Hour+17*24*time.Hour+4*time.Hour+5*time.Minute {
+		t.Errorf("uptime=%s", facts.Uptime)
+	}
+	if len(facts.Members) != 2 || facts.Members[0].Role != "Master" || facts.Members[1].SerialNumber != "FOC2222X5YZ" {
+		t.Errorf("unexpected members:%+v", facts.Members)
+	}
+	if strings.Join(facts.SerialNumbers, ",") != "FOC1234X5YZ,FOC2222X5YZ" {
+		t.Errorf("unexpected serial numbers:%v", facts.SerialNumbers)
+	}
+
+	nxos := parseCiscoFacts(`Cisco Nexus Operating System (NX-OS) Software
+  NXOS: version 9.3(5)
+  cisco Nexus9000 C93180YC-EX chassis
+  Device name: Leaf-1
+Kernel uptime is 10 day(s), 2 hour(s), 3 minute(s), 4 second(s)
+`, `NAME: "Chassis",  DESCR: "Nexus9000 C93180YC-EX chassis"
+PID: N9K-C93180YC-EX     ,  VID: V03 ,  SN: FDO12345678
+`)
+	if nxos.Platform != "NX-OS" || nxos.SoftwareVersion != "9.3(5)" || nxos.Hostname != "Leaf-1" ||
+		nxos.Uptime != 10*24*time.Hour+2*time.Hour+3*time.Minute+4*time.Second || strings.Join(nxos.SerialNumbers, ",") != "FDO12345678" {
+		t.Errorf("unexpected nx-os facts:%+v", nxos)
+	}
+}
+
+func TestSessionManagerGetFacts(t *testing.T) {
+	fake := newFakeSwitch(t, "<Core-SW1>", map[string]string{
+		HuaweiVersionCmd: huaweiVersionSample,
+		HuaweiDeviceCmd:  huaweiDeviceSample,
+		HuaweiEsnCmd:     huaweiEsnSample,
+		HuaweiSysnameCmd: " sysname Core-SW1",
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	facts, err := manager.GetFacts(NewDevice("admin", "admin", fake.addr(), HUAWEI))
+	if err != nil {
+		t.Fatalf("GetFacts err:%s", err)
+	}
+	if facts.Vendor != HUAWEI || facts.Model != "S5720-28X-SI-AC" || facts.Hostname != "Core-SW1" || len(facts.Members) != 2 {
+		t.Errorf("unexpected facts:%+v", facts)
+	}
+}
@@ -0,0 +1,129 @@
+package ssh
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedBrand = errors.New("ssh: the device brand is not supported")
+
+/**
+ * 识别设备品牌（Device.Brand为空时），按品牌执行结构化getter需要的指令
+ * @param device 设备的身份信息, commands 每个品牌需要执行的指令
+ * @return 设备品牌，每条指令去掉回显和提示符后的输出，执行的错误（品牌没有对应的指令时返回ErrUnsupportedBrand）
+ * @author shenbowei
+ */
+func (this *SessionManager) runBrandCommands(device Device, commands map[string][]string) (string, map[string]string, error) {
+	brand := device.Brand
+	if brand != HUAWEI && brand != H3C && brand != CISCO {
+		var err error
+		if brand, err = this.GetDeviceBrand(device); err != nil {
+			return "", nil, err
+		}
+	}
+	cmds, ok := commands[brand]
+	if !ok {
+		return brand, nil, ErrUnsupportedBrand
+	}
+	results, err := this.RunDeviceExec(device, cmds...)
+	if err != nil {
+		return brand, nil, err
+	}
+	outputs := make(map[string]string, len(results))
+	for _, result := range results {
+		outputs[result.Command] = commandOutput(result)
+	}
+	return brand, outputs, nil
+}
+
+/**
+ * 获取指令的输出：统一换行符，去掉shell方式下的指令回显行
+ * @param result 指令的执行结果
+ * @return 指令的输出
+ * @author shenbowei
+ */
+func commandOutput(result ExecResult) string {
+	output := strings.Replace(result.Output, "\r", "", -1)
+	if index := strings.Index(output, "\n"); index >= 0 && strings.Contains(output[:index], result.Command) {
+		output = output[index+1:]
+	}
+	return output
+}
+
+/**
+ * 获取输出中的非空行（去掉行尾空白）
+ * @param output 指令的输出
+ * @return 非空行
+ * @author shenbowei
+ */
+func outputLines(output string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.Replace(output, "\r", "", -1), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+/**
+ * 获取"key : value"形式的行中冒号之后的内容
+ * @param line 输出的一行
+ * @return 去除首尾空白后的值，没有冒号时返回""
+ * @author shenbowei
+ */
+func lineValue(line string) string {
+	index := strings.Index(line, ":")
+	if index < 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[index+1:])
+}
+
+var uptimeRegexp = regexp.MustCompile(`(\d+)\s*(year|week|day|hour|minute|second)`)
+
+var uptimeUnits = map[string]time.Duration{
+	"year":   365 * 24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"day":    24 * time.Hour,
+	"hour":   time.Hour,
+	"minute": time.Minute,
+	"second": time.Second,
+}
+
+/**
+ * 解析设备输出的运行时间，如"3 weeks, 2 days, 21 hours, 35 minutes"、"10 day(s), 2 hour(s), 3 minute(s)"
+ * @param text 运行时间的文本
+ * @return 运行时间，无法解析时为0
+ * @author shenbowei
+ */
+func parseUptime(text string) time.Duration {
+	uptime := time.Duration(0)
+	for _, match := range uptimeRegexp.FindAllStringSubmatch(strings.ToLower(text), -1) {
+		value, _ := strconv.Atoi(match[1])
+		uptime += time.Duration(value) * uptimeUnits[match[2]]
+	}
+	return uptime
+}
+
+/**
+ * 判断字符串是否全部由数字组成
+ * @param text 字符串
+ * @return true:非空且全部为数字
+ * @author shenbowei
+ */
+func isDigits(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
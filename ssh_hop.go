@@ -0,0 +1,253 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrHopFailed = errors.New("ssh: failed to connect to the inner device from the outer device")
+
+/**
+ * 从外层设备的命令行（stelnet/ssh/telnet）登录到的内层设备的会话，与外层会话共用输入输出管道，实现相同的Session接口
+ * @attr outer:外层设备的会话，outerPrompt:外层设备的提示符，用于判断是否已经退回到外层设备
+ * @author shenbowei
+ */
+type HopSession struct {
+	shellSession
+	outer       Session
+	outerPrompt string
+}
+
+/**
+ * 在外层设备的会话中执行品牌对应的登录指令，应答用户名、密码和主机密钥确认的提示，登录到内层设备
+ * @param outer 外层设备的会话, device 内层设备的身份信息（使用Host、Port/TelnetPort、User、Credential和Transport）
+ * @return 内层设备的会话，执行的错误（登录失败时外层会话会退回到外层设备的提示符，无法退回时关闭外层会话）
+ * @author shenbowei
+ */
+func NewHopSession(outer Session, device Device) (*HopSession, error) {
+	outerShell := outer.shell()
+	hopSession := new(HopSession)
+	hopSession.initShellSession(outerShell.config, outerShell.lineEnding)
+	//与外层会话共用管道，读写协程属于外层会话
+	hopSession.in = outerShell.in
+	hopSession.out = outerShell.out
+	hopSession.outer = outer
+	hopSession.outerPrompt = outer.GetPrompt()
+	cmd := hopCommand(outer.GetSSHBrand(), device)
+	if err := hopSession.connect(cmd, device.User, device.password()); err != nil {
+		hopSession.logger.Error("NewHopSession<%s> err:%s", device, err.Error())
+		if !hopSession.exitToOuter() {
+			outer.Close()
+		}
+		close(hopSession.done)
+		return nil, err
+	}
+	hopSession.UpdateLastUseTime()
+	return hopSession, nil
+}
+
+/**
+ * 生成在外层设备上登录内层设备的指令：华为stelnet，h3c ssh2，cisco ssh -l，telnet时都使用telnet
+ * @param outerBrand 外层设备的品牌, device 内层设备的身份信息
+ * @return 登录指令
+ * @author shenbowei
+ */
+func hopCommand(outerBrand string, device Device) string {
+	if device.Transport == TELNET_TRANSPORT {
+		cmd := "telnet " + device.Host
+		if device.TelnetPort > 0 && device.TelnetPort != DefaultTelnetPort {
+			cmd += " " + strconv.Itoa(device.TelnetPort)
+		}
+		return cmd
+	}
+	port := ""
+	if device.GetPort() != DefaultSSHPort {
+		port = strconv.Itoa(device.GetPort())
+	}
+	switch outerBrand {
+	case HUAWEI:
+		return strings.TrimSpace("stelnet " + device.Host + " " + port)
+	case H3C:
+		return strings.TrimSpace("ssh2 " + device.Host + " " + port)
+	default:
+		cmd := "ssh -l " + device.User
+		if port != "" {
+			cmd += " -p " + port
+		}
+		return cmd + " " + device.Host
+	}
+}
+
+/**
+ * 执行登录指令并应答提示，直到出现内层设备的提示符
+ * @param cmd 登录指令, user 内层设备的用户名, password 密码
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func (this *HopSession) connect(cmd, user, password string) error {
+	this.ClearChannel()
+	this.WriteChannel(cmd)
+	state := new(loginState)
+	output := ""
+	for i := 0; i < maxLoginPrompts; i++ {
+		output = this.ReadChannelExpect(this.config.dialTimeout, loginExpects...)
+		prompt := lastPromptLine(output)
+		lowerPrompt := strings.ToLower(prompt)
+		if handled, err := this.answerLogin(state, prompt, user, password); handled {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		isPrompt := parsePrivilegeMode(prompt) != UNKNOWN_MODE
+		switch {
+		case strings.Contains(lowerPrompt, "(yes/no"):
+			//首次连接时确认内层设备的主机密钥
+			this.WriteChannel("yes")
+		case strings.Contains(lowerPrompt, "[y/n]"):
+			this.WriteChannel("y")
+		case isPrompt && prompt != this.outerPrompt:
+			//先判断内层设备的提示符，登录后banner中的"error"等字样不影响登录结果
+			this.motd = textBeforePrompt(output)
+			this.prompt = prompt
+			return nil
+		case isHopFailed(hopResponse(output, cmd)) || (prompt == this.outerPrompt && !state.passwordSent):
+			//连接出错或者直接回到了外层设备的提示符
+			return errors.New(ErrHopFailed.Error() + ": " + strings.TrimSpace(output))
+		case isPrompt:
+			//内层设备的提示符与外层设备相同
+			this.motd = textBeforePrompt(output)
+			this.prompt = prompt
+			return nil
+		case output == "":
+			return errors.New(ErrHopFailed.Error() + ": timeout")
+		}
+	}
+	return errors.New(ErrHopFailed.Error() + ": " + lastPromptLine(output))
+}
+
+/**
+ * 获取登录指令自身的应答行，去掉指令的回显和最后的提示符行
+ * @param output 登录指令的输出, cmd 登录指令
+ * @return 应答行
+ * @author shenbowei
+ */
+func hopResponse(output, cmd string) string {
+	lines := strings.Split(strings.Replace(output, "\r", "", -1), "\n")
+	response := make([]string, 0, len(lines))
+	for _, line := range lines[:len(lines)-1] {
+		if !strings.Contains(line, cmd) {
+			response = append(response, line)
+		}
+	}
+	return strings.Join(response, "\n")
+}
+
+/**
+ * 判断登录指令的输出是否表示连接失败
+ * @param output 登录指令的输出
+ * @return true:连接失败
+ * @author shenbowei
+ */
+func isHopFailed(output string) bool {
+	lowerOutput := strings.ToLower(output)
+	for _, failure := range []string{"error", "refused", "unreachable", "timed out", "failed", "closed by foreign host", "unknown host"} {
+		if strings.Contains(lowerOutput, failure) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 退出内层设备（配置模式下先退回到用户视图），直到回到外层设备的提示符
+ * @return true:已经回到外层设备
+ * @author shenbowei
+ */
+func (this *HopSession) exitToOuter() bool {
+	for i := 0; i < 3; i++ {
+		this.ClearChannel()
+		this.WriteChannel("")
+		prompt := lastPromptLine(this.ReadChannelExpect(this.config.promptTimeout, "#", ">", "]"))
+		if prompt == this.outerPrompt {
+			return true
+		}
+		exitCmds := []string{"exit"}
+		if this.brand == HUAWEI || this.brand == H3C {
+			exitCmds = []string{"quit"}
+		}
+		if parsePrivilegeMode(prompt) == CONFIG_MODE {
+			if this.brand == HUAWEI || this.brand == H3C {
+				exitCmds = []string{"return", "quit"}
+			} else {
+				exitCmds = []string{"end", "exit"}
+			}
+		}
+		this.WriteChannel(exitCmds...)
+		this.ReadChannelExpect(this.config.promptTimeout, "#", ">", "]")
+	}
+	return false
+}
+
+/**
+ * 判断会话是否已经关闭，外层会话关闭时内层会话也不可用
+ * @return true:已关闭
+ * @author shenbowei
+ */
+func (this *HopSession) IsClosed() bool {
+	return this.shellSession.IsClosed() || this.outer.IsClosed()
+}
+
+/**
+ * 退出内层设备并回到外层设备，可重复调用。外层会话属于连接池时归还给连接池（无法退回时关闭），否则保持打开由调用者关闭
+ * @author shenbowei
+ */
+func (this *HopSession) Close() {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("HopSession Close err:%s", err)
+		}
+	}()
+	this.closeOnce.Do(func() {
+		returned := !this.outer.IsClosed() && this.exitToOuter()
+		close(this.done)
+		if !returned {
+			this.outer.Close()
+		}
+		if pool := this.outer.shell().pool; pool != nil {
+			pool.release(this.outer)
+		}
+	})
+}
+
+/**
+ * 通过外层设备的连接池打开内层设备的shell，等待外层设备的shell最多dialTimeout
+ * @return 内层设备的shell，执行的错误（外层连接池已关闭或不可用时将连接池标记为broken）
+ * @author shenbowei
+ */
+func (this *devicePool) openHopShell() (Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), this.config.dialTimeout)
+	defer cancel()
+	outer, err := this.outer.checkout(ctx)
+	if err != nil {
+		if err == errPoolClosed || this.outer.isBroken() {
+			this.locker.Lock()
+			this.broken = true
+			this.locker.Unlock()
+		}
+		return nil, err
+	}
+	hopSession, err := NewHopSession(outer, this.device)
+	if err != nil {
+		this.outer.release(outer)
+		if this.outer.isBroken() {
+			this.locker.Lock()
+			this.broken = true
+			this.locker.Unlock()
+		}
+		return nil, err
+	}
+	return hopSession, nil
+}
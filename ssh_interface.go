@@ -0,0 +1,418 @@
+package ssh
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 接口的管理状态和运行状态
+const (
+	INTERFACE_UP   = "up"
+	INTERFACE_DOWN = "down"
+)
+
+// 接口的二层模式
+const (
+	INTERFACE_ACCESS = "access"
+	INTERFACE_TRUNK  = "trunk"
+	INTERFACE_HYBRID = "hybrid"
+	INTERFACE_ROUTED = "routed" //三层接口（VLAN接口、路由口、Loopback等）
+)
+
+/**
+ * 接口的信息
+ * @attr Name:规范化后的接口名（见NormalizeInterfaceName），AdminStatus:管理状态（INTERFACE_UP/INTERFACE_DOWN），
+ *       OperStatus:运行状态（INTERFACE_UP/INTERFACE_DOWN），Speed:速率（Mbps，未知时为0），Duplex:双工模式（full、half、auto，未知时为""），
+ *       MTU:最大传输单元（设备未显示时为0），Description:描述（设备自动生成的描述为""），Mode:二层模式（INTERFACE_ACCESS等，未知时为""），
+ *       VLAN:access的VLAN或trunk/hybrid的PVID（未知时为0），IPAddresses:IPv4地址（"10.1.1.1/24"）
+ * @author shenbowei
+ */
+type Interface struct {
+	Name        string
+	AdminStatus string
+	OperStatus  string
+	Speed       int64
+	Duplex      string
+	MTU         int
+	Description string
+	Mode        string
+	VLAN        int
+	IPAddresses []string
+}
+
+// 获取接口信息时各品牌执行的指令
+var (
+	HuaweiInterfaceCmd    = "display interface"
+	HuaweiPortVlanCmd     = "display port vlan"
+	CiscoInterfaceCmd     = "show interfaces"
+	CiscoInterfaceStatCmd = "show interfaces status"
+)
+
+/**
+ * 获取各品牌设备接口信息时执行的指令
+ * @return 品牌对应的指令
+ * @author shenbowei
+ */
+func interfaceCommands() map[string][]string {
+	return map[string][]string{
+		HUAWEI: {HuaweiInterfaceCmd, HuaweiPortVlanCmd},
+		H3C:    {HuaweiInterfaceCmd},
+		CISCO:  {CiscoInterfaceCmd, CiscoInterfaceStatCmd},
+	}
+}
+
+/**
+ * 获取设备所有接口的状态、速率、双工、MTU、描述、VLAN模式和IP地址
+ * @param device 设备的身份信息
+ * @return 接口信息（按设备输出的顺序），执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetInterfaces(device Device) ([]Interface, error) {
+	brand, outputs, err := this.runBrandCommands(device, interfaceCommands())
+	if err != nil {
+		return nil, err
+	}
+	switch brand {
+	case HUAWEI:
+		return parseHuaweiInterfaces(outputs[HuaweiInterfaceCmd], outputs[HuaweiPortVlanCmd]), nil
+	case H3C:
+		return parseH3cInterfaces(outputs[HuaweiInterfaceCmd]), nil
+	default:
+		return parseCiscoInterfaces(outputs[CiscoInterfaceCmd], outputs[CiscoInterfaceStatCmd]), nil
+	}
+}
+
+// 各品牌接口名缩写（小写）对应的完整名称，品牌之间不同的缩写放在各自的表中
+var interfaceAbbreviations = map[string]map[string]string{
+	"": {
+		"ge": "GigabitEthernet", "gi": "GigabitEthernet", "gig": "GigabitEthernet", "gigabitethernet": "GigabitEthernet",
+		"fa": "FastEthernet", "fastethernet": "FastEthernet",
+		"eth": "Ethernet", "ethernet": "Ethernet",
+		"tu": "Tunnel", "tunnel": "Tunnel",
+	},
+	HUAWEI: {
+		"xge": "XGigabitEthernet", "xgigabitethernet": "XGigabitEthernet",
+		"10ge": "10GE", "25ge": "25GE", "40ge": "40GE", "100ge": "100GE",
+		"eth-trunk": "Eth-Trunk", "vlanif": "Vlanif", "meth": "MEth",
+		"lo": "LoopBack", "loopback": "LoopBack", "null": "NULL",
+	},
+	H3C: {
+		"xge": "Ten-GigabitEthernet", "ten-gigabitethernet": "Ten-GigabitEthernet",
+		"wge": "Twenty-FiveGigE", "twenty-fivegige": "Twenty-FiveGigE",
+		"fge": "FortyGigE", "fortygige": "FortyGigE", "hge": "HundredGigE", "hundredgige": "HundredGigE",
+		"bagg": "Bridge-Aggregation", "bridge-aggregation": "Bridge-Aggregation",
+		"ragg": "Route-Aggregation", "route-aggregation": "Route-Aggregation",
+		"vlan": "Vlan-interface", "vlan-interface": "Vlan-interface", "m-ge": "M-GigabitEthernet", "m-gigabitethernet": "M-GigabitEthernet",
+		"loop": "LoopBack", "loopback": "LoopBack", "null": "NULL",
+	},
+	CISCO: {
+		"te": "TenGigabitEthernet", "ten": "TenGigabitEthernet", "tengigabitethernet": "TenGigabitEthernet",
+		"twe": "TwentyFiveGigE", "twentyfivegige": "TwentyFiveGigE",
+		"fo": "FortyGigabitEthernet", "fortygigabitethernet": "FortyGigabitEthernet",
+		"hu": "HundredGigE", "hundredgige": "HundredGigE",
+		"po": "Port-channel", "port-channel": "Port-channel",
+		"vl": "Vlan", "vlan": "Vlan", "lo": "Loopback", "loopback": "Loopback", "nu": "Null", "null": "Null",
+		"ma": "mgmt", "mgmt": "mgmt",
+	},
+}
+
+var interfaceNameRegexp = regexp.MustCompile(`^([0-9]*[A-Za-z][A-Za-z_-]*)\s*([0-9].*)$`)
+
+/**
+ * 将接口名的缩写转换为设备使用的完整名称，如华为"GE0/0/1"为"GigabitEthernet0/0/1"，
+ * h3c"XGE1/0/49"为"Ten-GigabitEthernet1/0/49"，cisco"Gi1/0/1"为"GigabitEthernet1/0/1"、"Po1"为"Port-channel1"
+ * @param brand 设备品牌（huawei,h3c,cisco，为""时只转换各品牌通用的缩写）, name 接口名
+ * @return 完整的接口名，无法识别的缩写原样返回
+ * @author shenbowei
+ */
+func NormalizeInterfaceName(brand, name string) string {
+	name = strings.TrimSpace(name)
+	match := interfaceNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return name
+	}
+	prefix := strings.ToLower(match[1])
+	if full, ok := interfaceAbbreviations[brand][prefix]; ok {
+		return full + match[2]
+	}
+	if full, ok := interfaceAbbreviations[""][prefix]; ok {
+		return full + match[2]
+	}
+	return name
+}
+
+/**
+ * 一个接口在display interface/show interfaces输出中的段落
+ * @attr name:接口名，header:接口名所在的行，lines:段落中其余的行（已去除首尾空白）
+ * @author shenbowei
+ */
+type interfaceBlock struct {
+	name   string
+	header string
+	lines  []string
+}
+
+var (
+	huaweiInterfaceHeaderRegexp = regexp.MustCompile(`^(\S+) current state\s*:\s*(.*)$`)
+	//NX-OS的物理接口没有", line protocol is ..."，如"Ethernet1/2 is down (Link not connected)"
+	ciscoInterfaceHeaderRegexp = regexp.MustCompile(`^(\S+) is ((?:administratively )?(?:up|down|deleted)(?: \([^)]*\))?)(?:, line protocol is (\S+))?`)
+)
+
+/**
+ * 将display interface/show interfaces的输出按接口拆分为段落
+ * @param brand 设备品牌, output 指令的输出
+ * @return 各接口的段落
+ * @author shenbowei
+ */
+func splitInterfaceBlocks(brand, output string) []interfaceBlock {
+	blocks := make([]interfaceBlock, 0)
+	lines := outputLines(output)
+	for i, line := range lines {
+		name := ""
+		switch brand {
+		case HUAWEI:
+			if match := huaweiInterfaceHeaderRegexp.FindStringSubmatch(line); match != nil {
+				name = match[1]
+			}
+		case H3C:
+			//h3c的接口名单独一行，下一行为"Current state: UP"
+			if !strings.HasPrefix(line, " ") && !strings.Contains(line, ":") && i+1 < len(lines) &&
+				strings.HasPrefix(strings.TrimSpace(lines[i+1]), "Current state") {
+				name = strings.TrimSpace(line)
+			}
+		default:
+			if match := ciscoInterfaceHeaderRegexp.FindStringSubmatch(line); match != nil {
+				name = match[1]
+			}
+		}
+		if name != "" {
+			blocks = append(blocks, interfaceBlock{name: name, header: line})
+			continue
+		}
+		if len(blocks) > 0 {
+			blocks[len(blocks)-1].lines = append(blocks[len(blocks)-1].lines, strings.TrimSpace(line))
+		}
+	}
+	return blocks
+}
+
+var (
+	ipAddressRegexp    = regexp.MustCompile(`(?i)internet address(?: is|:)\s*(\d+\.\d+\.\d+\.\d+/\d+)`)
+	mtuRegexp          = regexp.MustCompile(`(?i)(?:maximum transmit unit is|maximum transmission unit:|^mtu)\s*(\d+)`)
+	huaweiSpeedRegexp  = regexp.MustCompile(`^Speed\s*:\s*(\d+)`)
+	huaweiDuplexRegexp = regexp.MustCompile(`^Duplex\s*:\s*(\w+)`)
+	h3cSpeedRegexp     = regexp.MustCompile(`(?i)^(\d+)([mg])bps-speed mode,\s*(\w+)-duplex mode`)
+	ciscoSpeedRegexp   = regexp.MustCompile(`(?i)^(full|half|auto)-duplex,\s*(?:(\d+)\s*(mb|gb)/s|auto-speed)`)
+	h3cPvidRegexp      = regexp.MustCompile(`^PVID\s*:\s*(\d+)`)
+	h3cLinkTypeRegexp  = regexp.MustCompile(`(?i)^port link-type\s*:\s*(\w+)`)
+	ciscoStatusRegexp  = regexp.MustCompile(`^(\S+)\s+(.*?)\s*\b(connected|notconnect|disabled|err-disabled|inactive|monitoring|suspended|sfpAbsent|xcvrAbsent|noOperMem|faulty)\s+(\S+)\s+(\S+)\s+(\S+)`)
+)
+
+/**
+ * 解析管理状态和运行状态，如"UP"、"DOWN"、"Administratively DOWN"、"UP (spoofing)"
+ * @param state 设备输出的状态
+ * @return 管理状态，运行状态
+ * @author shenbowei
+ */
+func parseInterfaceState(state string) (string, string) {
+	state = strings.ToLower(strings.TrimSpace(state))
+	if strings.Contains(state, "administratively") {
+		return INTERFACE_DOWN, INTERFACE_DOWN
+	}
+	if strings.HasPrefix(state, "up") {
+		return INTERFACE_UP, INTERFACE_UP
+	}
+	return INTERFACE_UP, INTERFACE_DOWN
+}
+
+/**
+ * 去掉设备自动生成的接口描述（如"GigabitEthernet1/0/1 Interface"、"HUAWEI, AR Series, GigabitEthernet0/0/1 Interface"）
+ * @param name 接口名, description 设备输出的描述
+ * @return 用户配置的描述，自动生成时返回""
+ * @author shenbowei
+ */
+func interfaceDescription(name, description string) string {
+	description = strings.TrimSpace(description)
+	if strings.HasSuffix(description, name+" Interface") {
+		return ""
+	}
+	return description
+}
+
+/**
+ * 根据接口名判断是否为三层接口（VLAN接口、Loopback、NULL、Tunnel）
+ * @param name 完整的接口名
+ * @return true:三层接口
+ * @author shenbowei
+ */
+func isRoutedInterfaceName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, prefix := range []string{"vlanif", "vlan-interface", "vlan", "loopback", "null", "tunnel"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 解析华为设备的接口信息
+ * @param output display interface的输出, portVlan display port vlan的输出
+ * @return 接口信息
+ * @author shenbowei
+ */
+func parseHuaweiInterfaces(output, portVlan string) []Interface {
+	//Port  Link Type  PVID  Trunk VLAN List
+	modes := map[string][]string{}
+	for _, line := range outputLines(portVlan) {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && isDigits(fields[2]) {
+			modes[NormalizeInterfaceName(HUAWEI, fields[0])] = fields[1:3]
+		}
+	}
+	interfaces := make([]Interface, 0)
+	for _, block := range splitInterfaceBlocks(HUAWEI, output) {
+		intf := Interface{Name: NormalizeInterfaceName(HUAWEI, block.name)}
+		intf.AdminStatus, intf.OperStatus = parseInterfaceState(huaweiInterfaceHeaderRegexp.FindStringSubmatch(block.header)[2])
+		routed := isRoutedInterfaceName(intf.Name)
+		for _, line := range block.lines {
+			switch {
+			case strings.HasPrefix(line, "Description:"):
+				intf.Description = interfaceDescription(intf.Name, line[len("Description:"):])
+			case strings.HasPrefix(line, "Route Port"):
+				routed = true
+			}
+			parseInterfaceLine(&intf, line)
+			if match := huaweiSpeedRegexp.FindStringSubmatch(line); match != nil {
+				intf.Speed, _ = strconv.ParseInt(match[1], 10, 64)
+			}
+			if match := huaweiDuplexRegexp.FindStringSubmatch(line); match != nil {
+				intf.Duplex = strings.ToLower(match[1])
+			}
+		}
+		if mode, ok := modes[intf.Name]; ok {
+			intf.Mode = strings.ToLower(mode[0])
+			intf.VLAN, _ = strconv.Atoi(mode[1])
+		} else if routed {
+			intf.Mode = INTERFACE_ROUTED
+		}
+		interfaces = append(interfaces, intf)
+	}
+	return interfaces
+}
+
+/**
+ * 解析各品牌通用的接口信息行（IP地址、MTU）
+ * @param intf 接口信息, line 段落中的一行
+ * @author shenbowei
+ */
+func parseInterfaceLine(intf *Interface, line string) {
+	if match := ipAddressRegexp.FindStringSubmatch(line); match != nil {
+		intf.IPAddresses = append(intf.IPAddresses, match[1])
+	}
+	if match := mtuRegexp.FindStringSubmatch(line); match != nil && intf.MTU == 0 {
+		intf.MTU, _ = strconv.Atoi(match[1])
+	}
+}
+
+/**
+ * 根据速率的数值和单位计算Mbps
+ * @param value 速率的数值, unit 单位（m/g，不区分大小写）
+ * @return 速率（Mbps），无法解析时为0
+ * @author shenbowei
+ */
+func speedMbps(value, unit string) int64 {
+	speed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if strings.EqualFold(unit, "g") || strings.EqualFold(unit, "gb") {
+		speed *= 1000
+	}
+	return speed
+}
+
+/**
+ * 解析h3c设备的接口信息
+ * @param output display interface的输出
+ * @return 接口信息
+ * @author shenbowei
+ */
+func parseH3cInterfaces(output string) []Interface {
+	interfaces := make([]Interface, 0)
+	for _, block := range splitInterfaceBlocks(H3C, output) {
+		intf := Interface{Name: NormalizeInterfaceName(H3C, block.name)}
+		for _, line := range block.lines {
+			switch {
+			case strings.HasPrefix(line, "Current state:"):
+				intf.AdminStatus, intf.OperStatus = parseInterfaceState(lineValue(line))
+			case strings.HasPrefix(line, "Description:"):
+				intf.Description = interfaceDescription(intf.Name, lineValue(line))
+			case h3cSpeedRegexp.MatchString(line):
+				match := h3cSpeedRegexp.FindStringSubmatch(line)
+				intf.Speed = speedMbps(match[1], match[2])
+				if duplex := strings.ToLower(match[3]); duplex != "unknown" {
+					intf.Duplex = duplex
+				}
+			case h3cPvidRegexp.MatchString(line):
+				intf.VLAN, _ = strconv.Atoi(h3cPvidRegexp.FindStringSubmatch(line)[1])
+			case h3cLinkTypeRegexp.MatchString(line):
+				intf.Mode = strings.ToLower(h3cLinkTypeRegexp.FindStringSubmatch(line)[1])
+			}
+			parseInterfaceLine(&intf, line)
+		}
+		if intf.Mode == "" && (isRoutedInterfaceName(intf.Name) || len(intf.IPAddresses) > 0) {
+			intf.Mode = INTERFACE_ROUTED
+		}
+		interfaces = append(interfaces, intf)
+	}
+	return interfaces
+}
+
+/**
+ * 解析cisco设备的接口信息
+ * @param output show interfaces的输出, status show interfaces status的输出（VLAN为trunk、routed或access的VLAN号）
+ * @return 接口信息
+ * @author shenbowei
+ */
+func parseCiscoInterfaces(output, status string) []Interface {
+	modes := map[string]string{}
+	for _, line := range outputLines(status) {
+		if match := ciscoStatusRegexp.FindStringSubmatch(line); match != nil {
+			modes[NormalizeInterfaceName(CISCO, match[1])] = match[4]
+		}
+	}
+	interfaces := make([]Interface, 0)
+	for _, block := range splitInterfaceBlocks(CISCO, output) {
+		intf := Interface{Name: NormalizeInterfaceName(CISCO, block.name)}
+		header := ciscoInterfaceHeaderRegexp.FindStringSubmatch(block.header)
+		intf.AdminStatus, intf.OperStatus = parseInterfaceState(header[2])
+		//IOS的运行状态以line protocol为准，如"is up, line protocol is down"为down
+		if intf.AdminStatus == INTERFACE_UP && header[3] != "" {
+			_, intf.OperStatus = parseInterfaceState(header[3])
+		}
+		for _, line := range block.lines {
+			switch {
+			case strings.HasPrefix(line, "Description:"):
+				intf.Description = strings.TrimSpace(line[len("Description:"):])
+			case ciscoSpeedRegexp.MatchString(line):
+				match := ciscoSpeedRegexp.FindStringSubmatch(line)
+				intf.Duplex = strings.ToLower(match[1])
+				intf.Speed = speedMbps(match[2], strings.TrimSuffix(strings.ToLower(match[3]), "b"))
+			}
+			parseInterfaceLine(&intf, line)
+		}
+		switch vlan := modes[intf.Name]; {
+		case vlan == INTERFACE_TRUNK:
+			intf.Mode = INTERFACE_TRUNK
+		case vlan == INTERFACE_ROUTED || (vlan == "" && (isRoutedInterfaceName(intf.Name) || len(intf.IPAddresses) > 0)):
+			intf.Mode = INTERFACE_ROUTED
+		case isDigits(vlan):
+			intf.Mode = INTERFACE_ACCESS
+			intf.VLAN, _ = strconv.Atoi(vlan)
+		}
+		interfaces = append(interfaces, intf)
+	}
+	return interfaces
+}
@@ -0,0 +1,229 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const huaweiInterfaceSample = `GigabitEthernet0/0/1 current state : UP
+Line protocol current state : UP
+Description:uplink to core
+Switch Port, PVID :   10, TPID : 8100(Hex), The Maximum Frame Length is 9216
+IP Sending Frames' Format is PKTFMT_ETHNT_2, Hardware address is 4c1f-cc11-2233
+Port Mode: COMMON COPPER
+Speed : 1000,  Loopback: NONE
+Duplex: FULL,  Negotiation: ENABLE
+Input:  123456 packets, 98765432 bytes
+Output:  234567 packets, 87654321 bytes
+GigabitEthernet0/0/2 current state : Administratively DOWN
+Line protocol current state : DOWN
+Description:HUAWEI, Quidway Series, GigabitEthernet0/0/2 Interface
+Switch Port, PVID :    1, TPID : 8100(Hex), The Maximum Frame Length is 9216
+Speed : 1000,  Loopback: NONE
+Duplex: HALF,  Negotiation: ENABLE
+Vlanif10 current state : UP
+Line protocol current state : UP
+Description:
+Route Port,The Maximum Transmit Unit is 1500
+Internet Address is 10.1.10.1/24
+Internet Address is 10.1.11.1/24 Sub
+`
+
+const huaweiPortVlanSample = `Port                    Link Type    PVID  Trunk VLAN List
+-------------------------------------------------------------------------------
+GE0/0/1                 access       10    -
+GE0/0/2                 trunk        1     1-4094
+`
+
+const h3cInterfaceSample = `GigabitEthernet1/0/1
+Current state: UP
+Line protocol state: UP
+IP packet frame type: Ethernet II, hardware address: 0cda-41b1-2345
+Description: GigabitEthernet1/0/1 Interface
+Bandwidth: 1000000 kbps
+1000Mbps-speed mode, full-duplex mode
+Link speed type is autonegotiation, link duplex type is autonegotiation
+Maximum frame length: 10000
+PVID: 20
+Port link-type: Trunk
+ Tagged VLANs:   10, 20
+ Untagged VLANs: 1
+Ten-GigabitEthernet1/0/49
+Current state: DOWN
+Line protocol state: DOWN
+Description: to server-1
+Unknown-speed mode, unknown-duplex mode
+PVID: 1
+Port link-type: Access
+Vlan-interface10
+Current state: UP
+Line protocol state: UP
+Description: Vlan-interface10 Interface
+Maximum transmission unit: 1500
+Internet address: 10.2.10.1/24 (primary)
+`
+
+const ciscoInterfaceSample = `GigabitEthernet1/0/1 is up, line protocol is up (connected)
+  Hardware is Gigabit Ethernet, address is 0011.2233.4455 (bia 0011.2233.4455)
+  Description: uplink to core
+  MTU 1500 bytes, BW 1000000 Kbit/sec, DLY 10 usec,
+  Full-duplex, 1000Mb/s, media type is 10/100/1000BaseTX
+GigabitEthernet1/0/2 is administratively down, line protocol is down (disabled)
+  Hardware is Gigabit Ethernet, address is 0011.2233.4456 (bia 0011.2233.4456)
+  MTU 1500 bytes, BW 10000 Kbit/sec, DLY 1000 usec,
+  Auto-duplex, Auto-speed, media type is 10/100/1000BaseTX
+TenGigabitEthernet1/1/1 is down, line protocol is down (notconnect)
+  Full-duplex, 10Gb/s, link type is auto, media type is SFP-10GBase-SR
+Vlan10 is up, line protocol is up
+  Hardware is EtherSVI, address is 0011.2233.4400 (bia 0011.2233.4400)
+  Internet address is 10.3.10.1/24
+  MTU 1500 bytes, BW 1000000 Kbit/sec, DLY 10 usec,
+`
+
+const ciscoInterfaceStatusSample = `Port      Name               Status       Vlan       Duplex  Speed Type
+Gi1/0/1   uplink to core     connected    trunk      a-full a-1000 10/100/1000BaseTX
+Gi1/0/2                      disabled     20           auto   auto 10/100/1000BaseTX
+Te1/1/1                      notconnect   1            full    10G SFP-10GBase-SR
+`
+
+const nxosInterfaceSample = `Ethernet1/1 is up
+admin state is up, Dedicated Interface
+  Hardware: 1000/10000 Ethernet, address: 5254.0012.3456 (bia 5254.0012.3456)
+  Description: to spine-1
+  MTU 9216 bytes, BW 10000000 Kbit, DLY 10 usec
+  full-duplex, 10 Gb/s, media type is 10G
+Ethernet1/2 is down (Link not connected)
+admin state is up, Dedicated Interface
+  MTU 1500 bytes, BW 10000000 Kbit, DLY 10 usec
+  auto-duplex, auto-speed
+Ethernet1/3 is down (Administratively down)
+admin state is down, Dedicated Interface
+  MTU 1500 bytes, BW 10000000 Kbit, DLY 10 usec
+Vlan20 is up, line protocol is down, autostate enabled
+  Hardware is EtherSVI, address is  5254.0012.3400
+  Internet Address is 10.4.20.1/24
+  MTU 1500 bytes, BW 1000000 Kbit, DLY 10 usec
+`
+
+func TestNormalizeInterfaceName(t *testing.T) {
+	tests := []struct {
+		brand    string
+		name     string
+		expected string
+	}{
+		{HUAWEI, "GE0/0/1", "GigabitEthernet0/0/1"},
+		{HUAWEI, "XGE0/0/1", "XGigabitEthernet0/0/1"},
+		{HUAWEI, "Eth-Trunk1", "Eth-Trunk1"},
+		{HUAWEI, "25GE1/0/1", "25GE1/0/1"},
+		{H3C, "XGE1/0/49", "Ten-GigabitEthernet1/0/49"},
+		{H3C, "BAGG1", "Bridge-Aggregation1"},
+		{H3C, "Vlan10", "Vlan-interface10"},
+		{CISCO, "Gi1/0/1", "GigabitEthernet1/0/1"},
+		{CISCO, "Te1/1/1", "TenGigabitEthernet1/1/1"},
+		{CISCO, "Po1", "Port-channel1"},
+		{CISCO, "Vl10", "Vlan10"},
+		{"", "Eth1/1", "Ethernet1/1"},
+		{"", "unknown", "unknown"},
+	}
+	for _, test := range tests {
+		if name := NormalizeInterfaceName(test.brand, test.name); name != test.expected {
+			t.Errorf("NormalizeInterfaceName(%s, %s)=%q, expected %q", test.brand, test.name, name, test.expected)
+		}
+	}
+}
+
+func checkInterface(t *testing.T, intf Interface, expected Interface) {
+	if intf.Name != expected.Name || intf.AdminStatus != expected.AdminStatus || intf.OperStatus != expected.OperStatus ||
+		intf.Speed != expected.Speed || intf.Duplex != expected.Duplex || intf.MTU != expected.MTU ||
+		intf.Description != expected.Description || intf.Mode != expected.Mode || intf.VLAN != expected.VLAN ||
+		strings.Join(intf.IPAddresses, ",") != strings.Join(expected.IPAddresses, ",") {
+		t.Errorf("interface=%+v, expected %+v", intf, expected)
+	}
+}
+
+func TestParseHuaweiInterfaces(t *testing.T) {
+	interfaces := parseHuaweiInterfaces(huaweiInterfaceSample, huaweiPortVlanSample)
+	if len(interfaces) != 3 {
+		t.Fatalf("unexpected interfaces:%+v", interfaces)
+	}
+	checkInterface(t, interfaces[0], Interface{Name: "GigabitEthernet0/0/1", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		Speed: 1000, Duplex: "full", Description: "uplink to core", Mode: INTERFACE_ACCESS, VLAN: 10})
+	checkInterface(t, interfaces[1], Interface{Name: "GigabitEthernet0/0/2", AdminStatus: INTERFACE_DOWN, OperStatus: INTERFACE_DOWN,
+		Speed: 1000, Duplex: "half", Mode: INTERFACE_TRUNK, VLAN: 1})
+	checkInterface(t, interfaces[2], Interface{Name: "Vlanif10", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		MTU: 1500, Mode: INTERFACE_ROUTED, IPAddresses: []string{"10.1.10.1/24", "10.1.11.1/24"}})
+}
+
+func TestParseH3cInterfaces(t *testing.T) {
+	interfaces := parseH3cInterfaces(h3cInterfaceSample)
+	if len(interfaces) != 3 {
+		t.Fatalf("unexpected interfaces:%+v", interfaces)
+	}
+	checkInterface(t, interfaces[0], Interface{Name: "GigabitEthernet1/0/1", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		Speed: 1000, Duplex: "full", Mode: INTERFACE_TRUNK, VLAN: 20})
+	checkInterface(t, interfaces[1], Interface{Name: "Ten-GigabitEthernet1/0/49", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_DOWN,
+		Description: "to server-1", Mode: INTERFACE_ACCESS, VLAN: 1})
+	checkInterface(t, interfaces[2], Interface{Name: "Vlan-interface10", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		MTU: 1500, Mode: INTERFACE_ROUTED, IPAddresses: []string{"10.2.10.1/24"}})
+}
+
+func TestParseCiscoInterfaces(t *testing.T) {
+	interfaces := parseCiscoInterfaces(ciscoInterfaceSample, ciscoInterfaceStatusSample)
+	if len(interfaces) != 4 {
+		t.Fatalf("unexpected interfaces:%+v", interfaces)
+	}
+	checkInterface(t, interfaces[0], Interface{Name: "GigabitEthernet1/0/1", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		Speed: 1000, Duplex: "full", MTU: 1500, Description: "uplink to core", Mode: INTERFACE_TRUNK})
+	checkInterface(t, interfaces[1], Interface{Name: "GigabitEthernet1/0/2", AdminStatus: INTERFACE_DOWN, OperStatus: INTERFACE_DOWN,
+		Duplex: "auto", MTU: 1500, Mode: INTERFACE_ACCESS, VLAN: 20})
+	checkInterface(t, interfaces[2], Interface{Name: "TenGigabitEthernet1/1/1", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_DOWN,
+		Speed: 10000, Duplex: "full", Mode: INTERFACE_ACCESS, VLAN: 1})
+	checkInterface(t, interfaces[3], Interface{Name: "Vlan10", AdminStatus: INTERFACE_UP, OperStatus: INTERFACE_UP,
+		MTU: 1500, Mode: INTERFACE_ROUTED, IPAddresses: []string{"10.3.10.1/24"}})
+}
+
+func TestParseNxosInterfaces(t *testing.T) {
+	interfaces := parseCiscoInterfaces(nxosInterfaceSample, "")
+	if len(interfaces) != 4 {
+		t.Fatalf("unexpected interfaces:%+v", interfaces)
+	}
+	expected := []struct {
+		name        string
+		adminStatus string
+		operStatus  string
+		speed       int64
+	}{
+		{"Ethernet1/1", INTERFACE_UP, INTERFACE_UP, 10000},
+		{"Ethernet1/2", INTERFACE_UP, INTERFACE_DOWN, 0},
+		{"Ethernet1/3", INTERFACE_DOWN, INTERFACE_DOWN, 0},
+		//接口up但line protocol down时运行状态为down
+		{"Vlan20", INTERFACE_UP, INTERFACE_DOWN, 0},
+	}
+	for i, test := range expected {
+		intf := interfaces[i]
+		if intf.Name != test.name || intf.AdminStatus != test.adminStatus || intf.OperStatus != test.operStatus || intf.Speed != test.speed {
+			t.Errorf("interface %d: expected %+v, got %+v", i, test, intf)
+		}
+	}
+	if interfaces[0].Duplex != "full" || interfaces[0].MTU != 9216 || interfaces[0].Description != "to spine-1" {
+		t.Errorf("unexpected interface:%+v", interfaces[0])
+	}
+}
+
+func TestSessionManagerGetInterfaces(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{
+		CiscoInterfaceCmd:     ciscoInterfaceSample,
+		CiscoInterfaceStatCmd: ciscoInterfaceStatusSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	interfaces, err := manager.GetInterfaces(NewDevice("admin", "admin", fake.addr(), CISCO))
+	if err != nil {
+		t.Fatalf("GetInterfaces err:%s", err)
+	}
+	if len(interfaces) != 4 || interfaces[0].Mode != INTERFACE_TRUNK || interfaces[1].VLAN != 20 {
+		t.Errorf("unexpected interfaces:%+v", interfaces)
+	}
+}
@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"golang.org/x/crypto/ssh"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * 跳板机（堡垒机）的连接信息，设备通过Device.JumpHosts中的跳板机依次跳转后连接
+ * @attr Host:跳板机的ip或域名，Port:ssh端口（为0时使用22），User:登录的用户名，Credential:登录凭证（使用Password），
+ *       AuthMethods:认证方式（如公钥，不为空时不使用Credential），HostKeyCallback:主机密钥校验（为nil时使用SessionManager的设置）
+ * @author shenbowei
+ */
+type JumpHost struct {
+	Host            string
+	Port            int
+	User            string
+	Credential      *Credential
+	AuthMethods     []ssh.AuthMethod
+	HostKeyCallback ssh.HostKeyCallback
+}
+
+/**
+ * 获取跳板机的连接地址
+ * @return host:port
+ * @author shenbowei
+ */
+func (this JumpHost) Address() string {
+	port := this.Port
+	if port <= 0 {
+		port = DefaultSSHPort
+	}
+	return net.JoinHostPort(this.Host, strconv.Itoa(port))
+}
+
+/**
+ * 获取跳板机的索引键值，形如"user@host:port#密码摘要"，与Device.Key相同不包含明文密码
+ * @return 索引键值
+ * @author shenbowei
+ */
+func (this JumpHost) key() string {
+	password := ""
+	if this.Credential != nil {
+		password = this.Credential.Password
+	}
+	return url.PathEscape(this.User) + "@" + this.Address() + "#" + passwordDigest(password)
+}
+
+/**
+ * 根据跳板机自己的认证方式和主机密钥校验生成ClientConfig，算法和超时使用SessionManager的配置
+ * @param config SessionManager的配置
+ * @return *ssh.ClientConfig
+ * @author shenbowei
+ */
+func (this JumpHost) clientConfig(config *sessionConfig) *ssh.ClientConfig {
+	password := ""
+	if this.Credential != nil {
+		password = this.Credential.Password
+	}
+	clientConfig := config.clientConfig(this.User, password)
+	if len(this.AuthMethods) > 0 {
+		clientConfig.Auth = this.AuthMethods
+	}
+	if this.HostKeyCallback != nil {
+		clientConfig.HostKeyCallback = this.HostKeyCallback
+	}
+	return clientConfig
+}
+
+/**
+ * 生成跳板机链路的索引键值，各跳板机的键值用">"连接
+ * @param jumpHosts 跳板机链路
+ * @return 索引键值
+ * @author shenbowei
+ */
+func jumpChainKey(jumpHosts []JumpHost) string {
+	keys := make([]string, 0, len(jumpHosts))
+	for _, jumpHost := range jumpHosts {
+		keys = append(keys, jumpHost.key())
+	}
+	return strings.Join(keys, ">")
+}
+
+/**
+ * 到跳板机的共享连接，被所有经过它的设备连接池引用，引用数为0时关闭
+ * @attr key:跳板机链路的索引键值，client:到最后一个跳板机的ssh连接，parent:上一级跳板机的连接（第一级为nil），
+ *       refs:引用数（受jumpCache.locker保护），closed:连接已断开或已关闭（受jumpCache.locker保护）
+ * @author shenbowei
+ */
+type jumpClient struct {
+	key    string
+	client *ssh.Client
+	parent *jumpClient
+	refs   int
+	closed bool
+}
+
+/**
+ * 跳板机连接的缓存，同一条跳板机链路只建立一个ssh连接
+ * @attr config:配置，clients:缓存的跳板机连接，dialCalls:正在建立的连接（同一条链路只会有一个，连接期间不持有locker），
+ *       locker:clients、dialCalls及引用数的锁
+ * @author shenbowei
+ */
+type jumpCache struct {
+	config    *sessionConfig
+	clients   map[string]*jumpClient
+	dialCalls map[string]*dialCall
+	locker    sync.Mutex
+}
+
+func newJumpCache(config *sessionConfig) *jumpCache {
+	return &jumpCache{
+		config:    config,
+		clients:   make(map[string]*jumpClient),
+		dialCalls: make(map[string]*dialCall),
+	}
+}
+
+/**
+ * 获取到跳板机链路最后一级的连接，没有缓存或已断开时依次建立连接，获取后引用数加1，使用完后需要调用release。
+ * 同一条链路并发的调用只会连接一次，其余调用等待连接完成后共享该连接
+ * @param jumpHosts 跳板机链路, dialer 连接第一个跳板机使用的Dialer（为nil时使用配置的Dialer）
+ * @return 跳板机的连接，执行的错误
+ * @author shenbowei
+ */
+func (this *jumpCache) acquire(jumpHosts []JumpHost, dialer Dialer) (*jumpClient, error) {
+	key := jumpChainKey(jumpHosts)
+	for {
+		this.locker.Lock()
+		if jump, ok := this.clients[key]; ok && !jump.closed {
+			jump.refs++
+			this.locker.Unlock()
+			return jump, nil
+		}
+		if call, ok := this.dialCalls[key]; ok {
+			this.locker.Unlock()
+			<-call.done
+			if call.err != nil {
+				return nil, call.err
+			}
+			//连接完成后重新从缓存中获取并增加引用
+			continue
+		}
+		call := &dialCall{done: make(chan struct{})}
+		this.dialCalls[key] = call
+		this.locker.Unlock()
+
+		jump, err := this.dial(key, jumpHosts, dialer)
+		this.locker.Lock()
+		delete(this.dialCalls, key)
+		if err == nil {
+			this.clients[key] = jump
+		}
+		this.locker.Unlock()
+		call.err = err
+		close(call.done)
+		return jump, err
+	}
+}
+
+/**
+ * 建立到跳板机链路最后一级的连接，上一级跳板机通过acquire获取（共享缓存中的连接）
+ * @param key 跳板机链路的索引键值, jumpHosts 跳板机链路, dialer 连接第一个跳板机使用的Dialer
+ * @return 引用数为1的跳板机连接，执行的错误
+ * @author shenbowei
+ */
+func (this *jumpCache) dial(key string, jumpHosts []JumpHost, dialer Dialer) (*jumpClient, error) {
+	var parent *jumpClient
+	if len(jumpHosts) > 1 {
+		var err error
+		parent, err = this.acquire(jumpHosts[:len(jumpHosts)-1], dialer)
+		if err != nil {
+			return nil, err
+		}
+		dialer = sshClientDialer{client: parent.client}
+	}
+	jumpHost := jumpHosts[len(jumpHosts)-1]
+	this.config.logger.Debug("Connect jump host<%s>", jumpHost.key())
+	client, _, err := dialClientThrough(dialer, jumpHost.Address(), jumpHost.clientConfig(this.config), this.config)
+	if err != nil {
+		this.config.logger.Error("Connect jump host<%s> err:%s", jumpHost.key(), err.Error())
+		if parent != nil {
+			this.release(parent)
+		}
+		return nil, err
+	}
+	jump := &jumpClient{key: key, client: client, parent: parent, refs: 1}
+	//连接断开后标记为不可用，之后的调用会重新连接
+	go func() {
+		client.Wait()
+		this.locker.Lock()
+		jump.closed = true
+		if this.clients[key] == jump {
+			delete(this.clients, key)
+		}
+		this.locker.Unlock()
+	}()
+	return jump, nil
+}
+
+/**
+ * 释放跳板机连接的一次引用，引用数为0时关闭连接并释放上一级跳板机
+ * @param jump 跳板机的连接
+ * @author shenbowei
+ */
+func (this *jumpCache) release(jump *jumpClient) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	this.releaseLocked(jump)
+}
+
+func (this *jumpCache) releaseLocked(jump *jumpClient) {
+	jump.refs--
+	if jump.refs > 0 {
+		return
+	}
+	jump.closed = true
+	if this.clients[jump.key] == jump {
+		delete(this.clients, jump.key)
+	}
+	if err := jump.client.Close(); err != nil {
+		this.config.logger.Debug("Close jump host<%s> err:%s", jump.key, err.Error())
+	}
+	if jump.parent != nil {
+		this.releaseLocked(jump.parent)
+	}
+}
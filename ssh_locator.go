@@ -0,0 +1,175 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+var ErrHostNotFound = errors.New("ssh: the host is not found on any edge port")
+
+/**
+ * 主机所在的接入端口
+ * @attr Device:端口所在的设备，Interface:规范化后的端口名，VLAN:主机所在的VLAN，MAC:主机的MAC地址，IP:主机的IP地址（ARP表中没有时为""）
+ * @author shenbowei
+ */
+type HostLocation struct {
+	Device    Device
+	Interface string
+	VLAN      int
+	MAC       string
+	IP        string
+}
+
+// 默认被认为是上联口的接口模式，hybrid口也常用作接入端口，需要时通过HostLocator.UplinkModes加入
+var DefaultUplinkModes = []string{INTERFACE_TRUNK}
+
+// 聚合口的名称前缀（规范化后），聚合口总是被认为是上联口
+var aggregateInterfacePrefixes = []string{"Eth-Trunk", "Bridge-Aggregation", "Route-Aggregation", "Port-channel"}
+
+/**
+ * 根据MAC或IP地址在一组设备中查找主机所在的接入端口，上联模式的端口、聚合口和学习到过多MAC地址的端口被认为是上联口而忽略
+ * @attr Manager:执行指令的SessionManager（为nil时使用DefaultSessionManager），
+ *       MaxEdgeMACs:接入端口最多学习到的MAC地址数量，超过时认为是上联口（为0时只根据接口模式和名称判断），
+ *       UplinkModes:被认为是上联口的接口模式（为nil时使用DefaultUplinkModes，即trunk），聚合口不受该设置影响
+ * @author shenbowei
+ */
+type HostLocator struct {
+	Manager     *SessionManager
+	MaxEdgeMACs int
+	UplinkModes []string
+}
+
+/**
+ * 一台设备的MAC地址表和ARP表
+ * @author shenbowei
+ */
+type deviceTables struct {
+	macs []MACEntry
+	arps []ARPEntry
+	err  error
+}
+
+/**
+ * 查找主机所在的接入端口：并发获取各设备的MAC地址表和ARP表，IP地址通过ARP表解析为MAC地址，
+ * 再排除上联模式的端口、聚合口和学习到过多MAC地址的端口，剩下的端口即为主机所在的接入端口
+ * @param devices 查找的设备, target 主机的MAC地址或IPv4地址
+ * @return 主机所在的接入端口（按devices的顺序），执行的错误（所有设备都没有找到时返回ErrHostNotFound或第一个设备的错误）
+ * @author shenbowei
+ */
+func (this *HostLocator) Locate(devices []Device, target string) ([]HostLocation, error) {
+	manager := this.Manager
+	if manager == nil {
+		manager = DefaultSessionManager
+	}
+	tables := make([]deviceTables, len(devices))
+	var waitGroup sync.WaitGroup
+	for i := range devices {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			if tables[i].macs, tables[i].err = manager.GetMACTable(devices[i]); tables[i].err == nil {
+				tables[i].arps, tables[i].err = manager.GetARPTable(devices[i])
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	mac, ip := NormalizeMAC(target), ""
+	if mac == "" && net.ParseIP(target) != nil {
+		ip = target
+	}
+	var firstErr error
+	for _, table := range tables {
+		if table.err != nil && firstErr == nil {
+			firstErr = table.err
+		}
+		for _, arp := range table.arps {
+			if ip != "" && arp.IP == ip && mac == "" {
+				mac = arp.MAC
+			} else if ip == "" && mac != "" && arp.MAC == mac {
+				ip = arp.IP
+			}
+		}
+	}
+
+	locations := make([]HostLocation, 0)
+	for i, table := range tables {
+		if mac == "" || table.err != nil {
+			continue
+		}
+		location, err := this.edgePort(manager, devices[i], table.macs, mac)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if location != nil {
+			location.IP = ip
+			locations = append(locations, *location)
+		}
+	}
+	if len(locations) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, ErrHostNotFound
+	}
+	return locations, nil
+}
+
+/**
+ * 在一台设备上查找MAC地址所在的接入端口，只有MAC地址出现在该设备上时才获取接口的模式
+ * @param manager 执行指令的SessionManager, device 设备的身份信息, macs 设备的MAC地址表, mac 主机的MAC地址
+ * @return 接入端口（MAC地址不在该设备或者只在上联口上时为nil），执行的错误
+ * @author shenbowei
+ */
+func (this *HostLocator) edgePort(manager *SessionManager, device Device, macs []MACEntry, mac string) (*HostLocation, error) {
+	counts := map[string]int{}
+	var found *MACEntry
+	for i := range macs {
+		counts[macs[i].Interface]++
+		if macs[i].MAC == mac && found == nil {
+			found = &macs[i]
+		}
+	}
+	if found == nil || isAggregateInterface(found.Interface) || (this.MaxEdgeMACs > 0 && counts[found.Interface] > this.MaxEdgeMACs) {
+		return nil, nil
+	}
+	interfaces, err := manager.GetInterfaces(device)
+	if err != nil {
+		return nil, err
+	}
+	uplinkModes := this.UplinkModes
+	if uplinkModes == nil {
+		uplinkModes = DefaultUplinkModes
+	}
+	for _, intf := range interfaces {
+		if intf.Name != found.Interface {
+			continue
+		}
+		for _, mode := range uplinkModes {
+			if intf.Mode == mode {
+				return nil, nil
+			}
+		}
+	}
+	return &HostLocation{Device: device, Interface: found.Interface, VLAN: found.VLAN, MAC: mac}, nil
+}
+
+/**
+ * 判断接口是否为聚合口（Eth-Trunk、Bridge-Aggregation、Port-channel等）
+ * @param name 规范化后的接口名
+ * @return true:聚合口
+ * @author shenbowei
+ */
+func isAggregateInterface(name string) bool {
+	for _, prefix := range aggregateInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package ssh
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrLoginFailed = errors.New("ssh: login failed, the device asks for the username or password again")
+var ErrPasswordChangeRequired = errors.New("ssh: login failed, the device requires the password to be changed")
+
+// 登录后遇到修改密码提示时的处理方式
+const (
+	PASSWORD_CHANGE_SKIP = "skip" //回答N跳过修改（默认），设备强制修改时返回ErrPasswordChangeRequired
+	PASSWORD_CHANGE_FAIL = "fail" //直接返回ErrPasswordChangeRequired
+)
+
+// 登录过程最多应答的提示次数，避免设备反复提示时无法返回
+const maxLoginPrompts = 10
+
+// 登录过程中等待的提示
+var loginExpects = []string{"sername:", "ogin:", "assword:", "[Y/N]", "[y/n]", "yes/no", "#", ">", "]"}
+
+/**
+ * 登录过程的应答状态，用户名、密码和修改密码的提示都只应答一次，再次出现说明登录失败
+ * @author shenbowei
+ */
+type loginState struct {
+	userSent       bool
+	passwordSent   bool
+	changeAnswered bool
+}
+
+/**
+ * 完成shell中的登录过程：应答Username/Password提示以及登录后的交互提示，直到出现设备的提示符
+ * @param user 登录的用户名, password 密码, timeout 等待设备输出的超时时间
+ * @return 执行的错误（用户名或密码被再次询问时返回ErrLoginFailed，需要修改密码时返回ErrPasswordChangeRequired）
+ * @author shenbowei
+ */
+func (this *shellSession) login(user, password string, timeout time.Duration) error {
+	state := new(loginState)
+	sawOutput := false
+	output := ""
+	//最后一次应答之后的输出，登录完成时即为MOTD和提示符
+	afterAnswer := ""
+	for i := 0; i < maxLoginPrompts; i++ {
+		output = this.ReadChannelExpect(timeout, loginExpects...)
+		afterAnswer += output
+		prompt := lastPromptLine(output)
+		if handled, err := this.answerLogin(state, prompt, user, password); handled {
+			if err != nil {
+				return err
+			}
+			if this.banner == "" && (state.userSent || state.passwordSent) && !sawOutput {
+				//telnet等没有ssh banner的传输方式，用户名提示之前的输出即为banner
+				this.banner = textBeforePrompt(afterAnswer)
+			}
+			sawOutput = true
+			afterAnswer = ""
+			continue
+		}
+		switch {
+		case parsePrivilegeMode(prompt) != UNKNOWN_MODE:
+			this.motd = textBeforePrompt(afterAnswer)
+			this.prompt = prompt
+			return nil
+		case output == "" && sawOutput:
+			//设备停在无法识别的提示符上，认为已经登录
+			this.motd = textBeforePrompt(afterAnswer)
+			this.prompt = lastPromptLine(afterAnswer)
+			return nil
+		case output == "":
+			//设备没有任何输出，发送换行唤醒
+			this.WriteChannel("")
+		}
+		sawOutput = sawOutput || output != ""
+	}
+	return errors.New("ssh: login timeout, last output:" + lastPromptLine(output))
+}
+
+/**
+ * 应答登录过程中的提示：用户名、密码、修改密码（按WithPasswordChangePolicy处理）、初始配置对话框和按键继续
+ * @param state 登录的应答状态, prompt 设备当前的提示, user 登录的用户名, password 密码
+ * @return 是否是登录过程中的提示（已应答），执行的错误
+ * @author shenbowei
+ */
+func (this *shellSession) answerLogin(state *loginState, prompt, user, password string) (bool, error) {
+	lowerPrompt := strings.ToLower(prompt)
+	switch {
+	case strings.Contains(lowerPrompt, "old password") || strings.Contains(lowerPrompt, "new password"):
+		//设备强制修改密码
+		return true, ErrPasswordChangeRequired
+	case strings.Contains(lowerPrompt, "change now?") ||
+		(strings.Contains(lowerPrompt, "password") && strings.Contains(lowerPrompt, "[y/n]")):
+		if this.config.passwordChangePolicy == PASSWORD_CHANGE_FAIL || state.changeAnswered {
+			return true, ErrPasswordChangeRequired
+		}
+		this.logger.Debug("The device asks to change the password, skip it")
+		this.WriteChannel("N")
+		state.changeAnswered = true
+	case strings.Contains(lowerPrompt, "initial configuration dialog"):
+		this.WriteChannel("no")
+	case strings.HasSuffix(lowerPrompt, "username:") || strings.HasSuffix(lowerPrompt, "login:"):
+		if state.userSent {
+			return true, ErrLoginFailed
+		}
+		this.WriteChannel(user)
+		state.userSent = true
+	case strings.HasSuffix(lowerPrompt, "password:"):
+		if state.passwordSent {
+			return true, ErrLoginFailed
+		}
+		this.writeSecret(password)
+		state.passwordSent = true
+	case strings.Contains(lowerPrompt, "press return") || strings.Contains(lowerPrompt, "press enter") ||
+		strings.Contains(lowerPrompt, "press any key"):
+		this.WriteChannel("")
+	default:
+		return false, nil
+	}
+	return true, nil
+}
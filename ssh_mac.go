@@ -0,0 +1,242 @@
+package ssh
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MAC地址表和ARP表项的类型
+const (
+	ENTRY_DYNAMIC   = "dynamic"
+	ENTRY_STATIC    = "static"
+	ENTRY_INTERFACE = "interface" //设备自身接口的ARP表项
+)
+
+/**
+ * MAC地址表项
+ * @attr MAC:规范化后的MAC地址（见NormalizeMAC），VLAN:所属VLAN（未知时为0），Interface:规范化后的出接口，Type:表项类型（ENTRY_DYNAMIC、ENTRY_STATIC等）
+ * @author shenbowei
+ */
+type MACEntry struct {
+	MAC       string
+	VLAN      int
+	Interface string
+	Type      string
+}
+
+/**
+ * ARP表项
+ * @attr IP:IPv4地址，MAC:规范化后的MAC地址，Interface:规范化后的接口（华为/h3c交换机为学习到ARP的物理口，cisco为VLAN接口），
+ *       VLAN:所属VLAN（未知时为0），Type:表项类型（ENTRY_DYNAMIC、ENTRY_STATIC、ENTRY_INTERFACE）
+ * @author shenbowei
+ */
+type ARPEntry struct {
+	IP        string
+	MAC       string
+	Interface string
+	VLAN      int
+	Type      string
+}
+
+// 获取MAC地址表和ARP表时各品牌执行的指令
+var (
+	HuaweiMACCmd = "display mac-address"
+	HuaweiARPCmd = "display arp"
+	CiscoMACCmd  = "show mac address-table"
+	CiscoARPCmd  = "show ip arp"
+)
+
+/**
+ * 获取设备的MAC地址表
+ * @param device 设备的身份信息
+ * @return MAC地址表项，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetMACTable(device Device) ([]MACEntry, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiMACCmd},
+		H3C:    {HuaweiMACCmd},
+		CISCO:  {CiscoMACCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if brand == CISCO {
+		return parseMACTable(brand, outputs[CiscoMACCmd]), nil
+	}
+	return parseMACTable(brand, outputs[HuaweiMACCmd]), nil
+}
+
+/**
+ * 获取设备的ARP表
+ * @param device 设备的身份信息
+ * @return ARP表项，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetARPTable(device Device) ([]ARPEntry, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiARPCmd},
+		H3C:    {HuaweiARPCmd},
+		CISCO:  {CiscoARPCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if brand == CISCO {
+		return parseARPTable(brand, outputs[CiscoARPCmd]), nil
+	}
+	return parseARPTable(brand, outputs[HuaweiARPCmd]), nil
+}
+
+var (
+	macRegexp       = regexp.MustCompile(`(?i)^(?:[0-9a-f]{4}[.-][0-9a-f]{4}[.-][0-9a-f]{4}|[0-9a-f]{2}(?:[:-][0-9a-f]{2}){5})$`)
+	vlanFieldRegexp = regexp.MustCompile(`^(\d+)(?:/\S*)?$`)
+)
+
+/**
+ * 将MAC地址转换为小写、冒号分隔的形式，支持"0011-2233-4455"、"0011.2233.4455"、"00:11:22:33:44:55"、"00-11-22-33-44-55"
+ * @param mac MAC地址
+ * @return 规范化后的MAC地址，如"00:11:22:33:44:55"，不是MAC地址时返回""
+ * @author shenbowei
+ */
+func NormalizeMAC(mac string) string {
+	mac = strings.TrimSpace(mac)
+	if !macRegexp.MatchString(mac) {
+		return ""
+	}
+	hex := strings.ToLower(strings.NewReplacer(".", "", "-", "", ":", "").Replace(mac))
+	parts := make([]string, 0, 6)
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+/**
+ * 判断字段是否为接口名（含数字编号，如"GE0/0/1"、"Gi1/0/1"、"Eth-Trunk1"、"Vlanif10"）
+ * @param field 字段
+ * @return true:接口名
+ * @author shenbowei
+ */
+func isInterfaceField(field string) bool {
+	match := interfaceNameRegexp.FindStringSubmatch(field)
+	return match != nil && len(match[1]) > 1
+}
+
+/**
+ * 根据表项类型的字段获取统一的类型
+ * @param field 设备输出的类型，如"dynamic"、"Learned"、"DYNAMIC"、"D-0"、"S"、"I"、"static"、"Config"
+ * @return ENTRY_DYNAMIC、ENTRY_STATIC、ENTRY_INTERFACE，无法识别时返回""
+ * @author shenbowei
+ */
+func entryType(field string) string {
+	lower := strings.ToLower(field)
+	switch {
+	case lower == "dynamic" || lower == "learned" || lower == "d" || strings.HasPrefix(lower, "d-"):
+		return ENTRY_DYNAMIC
+	case lower == "static" || lower == "config" || lower == "s" || strings.HasPrefix(lower, "s-") ||
+		lower == "sticky" || lower == "security":
+		return ENTRY_STATIC
+	case lower == "i" || strings.HasPrefix(lower, "i-"):
+		return ENTRY_INTERFACE
+	}
+	return ""
+}
+
+/**
+ * 解析MAC地址表，各品牌的列顺序不同，按字段的形式识别：MAC地址、第一个数字字段为VLAN、类型关键字、最后一个接口名字段为出接口
+ * @param brand 设备品牌, output display mac-address/show mac address-table的输出
+ * @return MAC地址表项（没有出接口的表项，如cisco的CPU表项，会被忽略）
+ * @author shenbowei
+ */
+func parseMACTable(brand, output string) []MACEntry {
+	entries := make([]MACEntry, 0)
+	for _, line := range outputLines(output) {
+		entry := MACEntry{}
+		for _, field := range strings.Fields(line) {
+			switch {
+			case entry.MAC == "" && NormalizeMAC(field) != "":
+				entry.MAC = NormalizeMAC(field)
+			case entry.VLAN == 0 && vlanFieldRegexp.MatchString(field):
+				entry.VLAN, _ = strconv.Atoi(vlanFieldRegexp.FindStringSubmatch(field)[1])
+			case entry.Type == "" && entryType(field) != "":
+				entry.Type = entryType(field)
+			case isInterfaceField(field):
+				entry.Interface = NormalizeInterfaceName(brand, field)
+			}
+		}
+		if entry.MAC != "" && entry.Interface != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+/**
+ * 解析ARP表，按字段的形式识别：IP地址、MAC地址、接口名和类型。华为的VLAN在下一行（"10/-"），
+ * h3c的VLAN为MAC之后的数字字段，cisco根据年龄"-"识别设备自身的接口地址
+ * @param brand 设备品牌, output display arp/show ip arp的输出
+ * @return ARP表项
+ * @author shenbowei
+ */
+func parseARPTable(brand, output string) []ARPEntry {
+	entries := make([]ARPEntry, 0)
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(entries) > 0 && len(fields) == 1 && vlanFieldRegexp.MatchString(fields[0]) && brand == HUAWEI {
+			//华为表项的第二行为VLAN/CEVLAN
+			entries[len(entries)-1].VLAN, _ = strconv.Atoi(vlanFieldRegexp.FindStringSubmatch(fields[0])[1])
+			continue
+		}
+		entry := ARPEntry{}
+		afterMAC := false
+		for i, field := range fields {
+			switch {
+			case entry.IP == "" && net.ParseIP(field) != nil && net.ParseIP(field).To4() != nil:
+				entry.IP = field
+			case entry.MAC == "" && NormalizeMAC(field) != "":
+				entry.MAC = NormalizeMAC(field)
+				afterMAC = true
+			case brand == CISCO && entry.IP != "" && entry.MAC == "" && field == "-":
+				entry.Type = ENTRY_INTERFACE
+			case brand == H3C && afterMAC && entry.VLAN == 0 && isDigits(field) && i < len(fields)-2:
+				entry.VLAN, _ = strconv.Atoi(field)
+			case entry.Type == "" && entryType(field) != "":
+				//华为的类型"D-0"也符合接口名的形式，需要先判断
+				entry.Type = entryType(field)
+			case isInterfaceField(field):
+				entry.Interface = NormalizeInterfaceName(brand, field)
+			}
+		}
+		if entry.IP == "" || entry.MAC == "" {
+			continue
+		}
+		if brand == CISCO && entry.Type == "" {
+			entry.Type = ENTRY_DYNAMIC
+		}
+		if entry.VLAN == 0 {
+			entry.VLAN = interfaceVLAN(entry.Interface)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+var vlanInterfaceRegexp = regexp.MustCompile(`(?i)^(?:vlanif|vlan-interface|vlan)(\d+)$`)
+
+/**
+ * 获取VLAN接口对应的VLAN
+ * @param name 规范化后的接口名
+ * @return VLAN，不是VLAN接口时返回0
+ * @author shenbowei
+ */
+func interfaceVLAN(name string) int {
+	match := vlanInterfaceRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return 0
+	}
+	vlan, _ := strconv.Atoi(match[1])
+	return vlan
+}
@@ -0,0 +1,215 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+)
+
+const huaweiMACSample = `-------------------------------------------------------------------------------
+MAC Address    VLAN/       PEVLAN CEVLAN Port            Type      LSP/LSR-ID
+               VSI/SI                                              MAC-Tunnel
+-------------------------------------------------------------------------------
+00e0-fc12-3456 10          -      -      GE0/0/1         dynamic   0/-
+5489-98aa-bb01 20          -      -      Eth-Trunk1      dynamic   0/-
+0011-2233-4455 10          -      -      GE0/0/1         static    -
+-------------------------------------------------------------------------------
+Total matching items on slot 0 displayed = 3
+`
+
+const h3cMACSample = `MAC Address      VLAN ID    State            Port/Nickname            Aging
+0cda-41b1-0001   1          Learned          GE1/0/1                  Y
+0cda-41b1-0002   20         Config static    BAGG1                    N
+`
+
+const ciscoMACSample = `          Mac Address Table
+-------------------------------------------
+
+Vlan    Mac Address       Type        Ports
+----    -----------       --------    -----
+ All    0100.0ccc.cccc    STATIC      CPU
+  10    00e0.fc12.3456    DYNAMIC     Gi1/0/1
+  20    0011.2233.4455    STATIC      Po1
+Total Mac Addresses for this criterion: 3
+`
+
+const huaweiARPSample = `IP ADDRESS      MAC ADDRESS     EXPIRE(M) TYPE        INTERFACE   VPN-INSTANCE
+                                      VLAN/CEVLAN(SIP/DIP)
+------------------------------------------------------------------------------
+10.1.10.1       4c1f-cc11-2233            I -         Vlanif10
+10.1.10.100     00e0-fc12-3456  20        D-0         GE0/0/1
+                                           10/-
+------------------------------------------------------------------------------
+Total:2         Dynamic:1       Static:0     Interface:1
+`
+
+const h3cARPSample = `  Type: S-Static   D-Dynamic   O-Openflow   R-Rule   M-Multiport  I-Invalid
+IP address      MAC address    VLAN/VSI name Interface                Aging Type
+10.2.10.5       0cda-41b1-0001 10            GE1/0/1                  1080  D
+10.2.10.6       0cda-41b1-0002 10            BAGG1                    N/A   S
+`
+
+const ciscoARPSample = `Protocol  Address          Age (min)  Hardware Addr   Type   Interface
+Internet  10.3.10.1               -   0011.2233.4400  ARPA   Vlan10
+Internet  10.3.10.100            12   00e0.fc12.3456  ARPA   Vlan10
+`
+
+func TestNormalizeMAC(t *testing.T) {
+	cases := map[string]string{
+		"0011-2233-4455":    "00:11:22:33:44:55",
+		"0011.2233.44AA":    "00:11:22:33:44:aa",
+		"00:11:22:33:44:55": "00:11:22:33:44:55",
+		"00-11-22-33-44-55": "00:11:22:33:44:55",
+		"10.1.1.1":          "",
+		"GE0/0/1":           "",
+	}
+	for mac, expected := range cases {
+		if normalized := NormalizeMAC(mac); normalized != expected {
+			t.Errorf("NormalizeMAC(%q) = %q, expected %q", mac, normalized, expected)
+		}
+	}
+}
+
+func TestParseMACTable(t *testing.T) {
+	entries := parseMACTable(HUAWEI, huaweiMACSample)
+	if len(entries) != 3 {
+		t.Fatalf("huawei entries:%+v", entries)
+	}
+	if entries[0] != (MACEntry{MAC: "00:e0:fc:12:34:56", VLAN: 10, Interface: "GigabitEthernet0/0/1", Type: ENTRY_DYNAMIC}) {
+		t.Errorf("huawei entry 0:%+v", entries[0])
+	}
+	if entries[1].Interface != "Eth-Trunk1" || entries[2].Type != ENTRY_STATIC {
+		t.Errorf("huawei entries:%+v", entries)
+	}
+
+	entries = parseMACTable(H3C, h3cMACSample)
+	if len(entries) != 2 {
+		t.Fatalf("h3c entries:%+v", entries)
+	}
+	if entries[0] != (MACEntry{MAC: "0c:da:41:b1:00:01", VLAN: 1, Interface: "GigabitEthernet1/0/1", Type: ENTRY_DYNAMIC}) {
+		t.Errorf("h3c entry 0:%+v", entries[0])
+	}
+	if entries[1].Interface != "Bridge-Aggregation1" || entries[1].VLAN != 20 || entries[1].Type != ENTRY_STATIC {
+		t.Errorf("h3c entry 1:%+v", entries[1])
+	}
+
+	entries = parseMACTable(CISCO, ciscoMACSample)
+	if len(entries) != 2 {
+		t.Fatalf("cisco entries:%+v", entries)
+	}
+	if entries[0] != (MACEntry{MAC: "00:e0:fc:12:34:56", VLAN: 10, Interface: "GigabitEthernet1/0/1", Type: ENTRY_DYNAMIC}) {
+		t.Errorf("cisco entry 0:%+v", entries[0])
+	}
+	if entries[1].Interface != "Port-channel1" || entries[1].Type != ENTRY_STATIC {
+		t.Errorf("cisco entry 1:%+v", entries[1])
+	}
+}
+
+func TestParseARPTable(t *testing.T) {
+	entries := parseARPTable(HUAWEI, huaweiARPSample)
+	if len(entries) != 2 {
+		t.Fatalf("huawei entries:%+v", entries)
+	}
+	if entries[0] != (ARPEntry{IP: "10.1.10.1", MAC: "4c:1f:cc:11:22:33", Interface: "Vlanif10", VLAN: 10, Type: ENTRY_INTERFACE}) {
+		t.Errorf("huawei entry 0:%+v", entries[0])
+	}
+	if entries[1] != (ARPEntry{IP: "10.1.10.100", MAC: "00:e0:fc:12:34:56", Interface: "GigabitEthernet0/0/1", VLAN: 10, Type: ENTRY_DYNAMIC}) {
+		t.Errorf("huawei entry 1:%+v", entries[1])
+	}
+
+	entries = parseARPTable(H3C, h3cARPSample)
+	if len(entries) != 2 {
+		t.Fatalf("h3c entries:%+v", entries)
+	}
+	if entries[0] != (ARPEntry{IP: "10.2.10.5", MAC: "0c:da:41:b1:00:01", Interface: "GigabitEthernet1/0/1", VLAN: 10, Type: ENTRY_DYNAMIC}) {
+		t.Errorf("h3c entry 0:%+v", entries[0])
+	}
+	if entries[1].Interface != "Bridge-Aggregation1" || entries[1].Type != ENTRY_STATIC {
+		t.Errorf("h3c entry 1:%+v", entries[1])
+	}
+
+	entries = parseARPTable(CISCO, ciscoARPSample)
+	if len(entries) != 2 {
+		t.Fatalf("cisco entries:%+v", entries)
+	}
+	if entries[0] != (ARPEntry{IP: "10.3.10.1", MAC: "00:11:22:33:44:00", Interface: "Vlan10", VLAN: 10, Type: ENTRY_INTERFACE}) {
+		t.Errorf("cisco entry 0:%+v", entries[0])
+	}
+	if entries[1].Type != ENTRY_DYNAMIC || entries[1].MAC != "00:e0:fc:12:34:56" {
+		t.Errorf("cisco entry 1:%+v", entries[1])
+	}
+}
+
+func TestHostLocatorLocate(t *testing.T) {
+	core := newFakeSwitch(t, "Core#", map[string]string{
+		CiscoMACCmd:           ciscoMACSample,
+		CiscoARPCmd:           ciscoARPSample,
+		CiscoInterfaceCmd:     ciscoInterfaceSample,
+		CiscoInterfaceStatCmd: ciscoInterfaceStatusSample,
+	})
+	access := newFakeSwitch(t, "<Access>", map[string]string{
+		HuaweiMACCmd:       huaweiMACSample,
+		HuaweiARPCmd:       "",
+		HuaweiInterfaceCmd: huaweiInterfaceSample,
+		HuaweiPortVlanCmd:  huaweiPortVlanSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	devices := []Device{NewDevice("admin", "admin", core.addr(), CISCO), NewDevice("admin", "admin", access.addr(), HUAWEI)}
+	locator := &HostLocator{Manager: manager}
+
+	//核心交换机上主机的MAC在trunk口上，只有接入交换机的access口是主机所在的端口
+	locations, err := locator.Locate(devices, "10.3.10.100")
+	if err != nil {
+		t.Fatalf("Locate err:%s", err)
+	}
+	if len(locations) != 1 {
+		t.Fatalf("unexpected locations:%+v", locations)
+	}
+	location := locations[0]
+	if location.Device.Key() != devices[1].Key() || location.Interface != "GigabitEthernet0/0/1" || location.VLAN != 10 ||
+		location.MAC != "00:e0:fc:12:34:56" || location.IP != "10.3.10.100" {
+		t.Errorf("unexpected location:%+v", location)
+	}
+
+	//GE0/0/1上学习到两个MAC地址，超过MaxEdgeMACs时被认为是上联口
+	locator.MaxEdgeMACs = 1
+	if _, err := locator.Locate(devices, "00e0.fc12.3456"); err != ErrHostNotFound {
+		t.Errorf("expected ErrHostNotFound, got %v", err)
+	}
+
+	//分布层交换机通过hybrid口GE0/0/2连接接入交换机，聚合口Eth-Trunk1上的MAC地址不是接入端口
+	dist := newFakeSwitch(t, "<Dist>", map[string]string{
+		HuaweiMACCmd: `00e0-fc12-3456 10          -      -      GE0/0/2         dynamic   0/-
+5489-98aa-bb01 20          -      -      Eth-Trunk1      dynamic   0/-
+`,
+		HuaweiARPCmd:       "",
+		HuaweiInterfaceCmd: huaweiInterfaceSample,
+		HuaweiPortVlanCmd: `Port                    Link Type    PVID  Trunk VLAN List
+GE0/0/1                 access       10    -
+GE0/0/2                 hybrid       1     10 20
+`,
+	})
+	devices = []Device{NewDevice("admin", "admin", dist.addr(), HUAWEI), devices[1]}
+	locator.MaxEdgeMACs = 0
+	if _, err := locator.Locate(devices, "5489-98aa-bb01"); err != ErrHostNotFound {
+		t.Errorf("MAC on Eth-Trunk1: expected ErrHostNotFound, got %v", err)
+	}
+	//默认只把trunk口作为上联口，hybrid口被认为是接入端口
+	locations, err = locator.Locate(devices, "00e0.fc12.3456")
+	if err != nil {
+		t.Fatalf("Locate err:%s", err)
+	}
+	if len(locations) != 2 || locations[0].Interface != "GigabitEthernet0/0/2" {
+		t.Errorf("unexpected locations:%+v", locations)
+	}
+
+	//hybrid口用作上联口时，需要加入UplinkModes
+	locator.UplinkModes = []string{INTERFACE_TRUNK, INTERFACE_HYBRID}
+	locations, err = locator.Locate(devices, "00e0.fc12.3456")
+	if err != nil {
+		t.Fatalf("Locate err:%s", err)
+	}
+	if len(locations) != 1 || locations[0].Device.Key() != devices[1].Key() || locations[0].Interface != "GigabitEthernet0/0/1" {
+		t.Errorf("unexpected locations:%+v", locations)
+	}
+}
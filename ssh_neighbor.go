@@ -0,0 +1,321 @@
+package ssh
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// 邻居的发现协议
+const (
+	NEIGHBOR_LLDP = "lldp"
+	NEIGHBOR_CDP  = "cdp"
+)
+
+/**
+ * LLDP/CDP邻居
+ * @attr Protocol:发现协议（NEIGHBOR_LLDP、NEIGHBOR_CDP），LocalInterface:规范化后的本端接口，RemoteName:邻居的设备名称，
+ *       RemoteChassisID:邻居的机框ID（一般为规范化后的MAC地址），RemoteInterface:邻居的接口（能识别邻居品牌时规范化），
+ *       RemotePortDescription:邻居接口的描述，RemoteBrand:根据系统描述识别的邻居品牌（无法识别时为""），
+ *       Platform:邻居的平台（LLDP为系统描述的第一行，CDP为Platform），ManagementAddress:邻居的IPv4管理地址，
+ *       Capabilities:邻居启用的能力（小写，如bridge、router）
+ * @author shenbowei
+ */
+type Neighbor struct {
+	Protocol              string
+	LocalInterface        string
+	RemoteName            string
+	RemoteChassisID       string
+	RemoteInterface       string
+	RemotePortDescription string
+	RemoteBrand           string
+	Platform              string
+	ManagementAddress     string
+	Capabilities          []string
+}
+
+// 获取LLDP/CDP邻居时各品牌执行的指令
+var (
+	HuaweiLLDPCmd = "display lldp neighbor"
+	H3cLLDPCmd    = "display lldp neighbor-information verbose"
+	CiscoLLDPCmd  = "show lldp neighbors detail"
+	CiscoCDPCmd   = "show cdp neighbors detail"
+)
+
+/**
+ * 获取设备的LLDP邻居
+ * @param device 设备的身份信息
+ * @return LLDP邻居，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetLLDPNeighbors(device Device) ([]Neighbor, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiLLDPCmd},
+		H3C:    {H3cLLDPCmd},
+		CISCO:  {CiscoLLDPCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseLLDPNeighbors(brand, outputs[lldpCommand(brand)]), nil
+}
+
+/**
+ * 获取设备的CDP邻居，只支持cisco设备
+ * @param device 设备的身份信息
+ * @return CDP邻居，执行的错误（非cisco设备返回ErrUnsupportedBrand）
+ * @author shenbowei
+ */
+func (this *SessionManager) GetCDPNeighbors(device Device) ([]Neighbor, error) {
+	_, outputs, err := this.runBrandCommands(device, map[string][]string{CISCO: {CiscoCDPCmd}})
+	if err != nil {
+		return nil, err
+	}
+	return parseCDPNeighbors(outputs[CiscoCDPCmd]), nil
+}
+
+/**
+ * 获取设备的所有邻居：LLDP邻居，cisco设备再加上CDP邻居（与LLDP邻居的本端接口和对端接口都相同的CDP邻居被忽略）
+ * @param device 设备的身份信息
+ * @return 邻居，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetNeighbors(device Device) ([]Neighbor, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiLLDPCmd},
+		H3C:    {H3cLLDPCmd},
+		CISCO:  {CiscoLLDPCmd, CiscoCDPCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	neighbors := parseLLDPNeighbors(brand, outputs[lldpCommand(brand)])
+	if brand == CISCO {
+		neighbors = mergeNeighbors(neighbors, parseCDPNeighbors(outputs[CiscoCDPCmd]))
+	}
+	return neighbors, nil
+}
+
+/**
+ * 获取品牌查看LLDP邻居的指令
+ * @param brand 设备品牌
+ * @return 指令
+ * @author shenbowei
+ */
+func lldpCommand(brand string) string {
+	switch brand {
+	case H3C:
+		return H3cLLDPCmd
+	case CISCO:
+		return CiscoLLDPCmd
+	}
+	return HuaweiLLDPCmd
+}
+
+/**
+ * 合并两组邻居，本端接口和对端接口都相同的邻居只保留第一组中的
+ * @param neighbors 优先保留的邻居, others 其他邻居
+ * @return 合并后的邻居
+ * @author shenbowei
+ */
+func mergeNeighbors(neighbors, others []Neighbor) []Neighbor {
+	seen := make(map[string]bool, len(neighbors))
+	for _, neighbor := range neighbors {
+		seen[neighbor.LocalInterface+"|"+neighbor.RemoteInterface] = true
+	}
+	for _, neighbor := range others {
+		if !seen[neighbor.LocalInterface+"|"+neighbor.RemoteInterface] {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors
+}
+
+var (
+	huaweiLLDPPortRegexp = regexp.MustCompile(`^(\S+)\s+has\s+\d+\s+neighbor`)
+	h3cLLDPPortRegexp    = regexp.MustCompile(`neighbor-information of port \d+\[(\S+)\]`)
+	cdpPlatformRegexp    = regexp.MustCompile(`^Platform:\s*(.*?),\s*Capabilities:\s*(.*)$`)
+	cdpInterfaceRegexp   = regexp.MustCompile(`^Interface:\s*([^,]+),\s*Port ID \(outgoing port\):\s*(.+)$`)
+	cdpSerialRegexp      = regexp.MustCompile(`\([^)]*\)$`)
+)
+
+// cisco LLDP能力的缩写
+var lldpCapabilityCodes = map[string]string{
+	"b": "bridge", "r": "router", "t": "telephone", "w": "wlan-access-point",
+	"p": "repeater", "s": "station", "c": "docsis-cable-device", "o": "other",
+}
+
+/**
+ * 解析邻居的能力，如"bridge router"、"Bridge,Router"、"B,R"、"Router Switch IGMP"
+ * @param text 能力的文本
+ * @return 小写的能力
+ * @author shenbowei
+ */
+func parseCapabilities(text string) []string {
+	capabilities := make([]string, 0)
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(c rune) bool { return c == ',' || c == ' ' }) {
+		if full, ok := lldpCapabilityCodes[field]; ok {
+			field = full
+		}
+		capabilities = append(capabilities, field)
+	}
+	return capabilities
+}
+
+/**
+ * 补全解析出的邻居：规范化本端接口和机框ID，根据系统描述识别邻居品牌并规范化对端接口
+ * @param brand 本端设备品牌, neighbor 邻居, description 邻居的系统描述
+ * @return 补全后的邻居
+ * @author shenbowei
+ */
+func completeNeighbor(brand string, neighbor Neighbor, description string) Neighbor {
+	neighbor.LocalInterface = NormalizeInterfaceName(brand, neighbor.LocalInterface)
+	if mac := NormalizeMAC(neighbor.RemoteChassisID); mac != "" {
+		neighbor.RemoteChassisID = mac
+	}
+	neighbor.RemoteBrand = brandFromVersion(neighbor.Platform + "\n" + description)
+	if NormalizeMAC(neighbor.RemoteInterface) == "" {
+		neighbor.RemoteInterface = NormalizeInterfaceName(neighbor.RemoteBrand, neighbor.RemoteInterface)
+	}
+	return neighbor
+}
+
+/**
+ * 解析LLDP邻居。华为以"GigabitEthernet0/0/1 has 1 neighbor(s):"、h3c以"LLDP neighbor-information of port 1[GigabitEthernet1/0/1]:"
+ * 给出本端接口，之后的"Neighbor index"开始一个邻居；cisco的每个邻居以"Local Intf:"开始。其余为"key : value"的形式，
+ * 系统描述可能跨越多行
+ * @param brand 设备品牌, output display lldp neighbor/show lldp neighbors detail的输出
+ * @return LLDP邻居
+ * @author shenbowei
+ */
+func parseLLDPNeighbors(brand, output string) []Neighbor {
+	neighbors := make([]Neighbor, 0)
+	var neighbor *Neighbor
+	localInterface := ""
+	description := make([]string, 0)
+	inDescription := false
+	finish := func() {
+		if neighbor != nil {
+			neighbors = append(neighbors, completeNeighbor(brand, *neighbor, strings.Join(description, "\n")))
+		}
+		neighbor, description, inDescription = nil, description[:0], false
+	}
+	for _, line := range outputLines(output) {
+		trimmed := strings.TrimSpace(line)
+		if match := huaweiLLDPPortRegexp.FindStringSubmatch(trimmed); match != nil {
+			finish()
+			localInterface = match[1]
+			continue
+		}
+		if match := h3cLLDPPortRegexp.FindStringSubmatch(trimmed); match != nil {
+			finish()
+			localInterface = match[1]
+			continue
+		}
+		index := strings.Index(trimmed, ":")
+		if index < 0 {
+			//系统描述的续行
+			if neighbor != nil && inDescription {
+				description = append(description, trimmed)
+				if neighbor.Platform == "" {
+					neighbor.Platform = trimmed
+				}
+			}
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(trimmed[:index]))
+		value := strings.TrimSpace(trimmed[index+1:])
+		inDescription = false
+		switch {
+		case key == "local intf":
+			finish()
+			neighbor = &Neighbor{Protocol: NEIGHBOR_LLDP, LocalInterface: value}
+		case strings.HasSuffix(key, "neighbor index"):
+			finish()
+			neighbor = &Neighbor{Protocol: NEIGHBOR_LLDP, LocalInterface: localInterface}
+		case neighbor == nil:
+		case key == "chassis id":
+			neighbor.RemoteChassisID = value
+		case key == "port id":
+			neighbor.RemoteInterface = value
+		case key == "port description":
+			neighbor.RemotePortDescription = value
+		case key == "system name":
+			neighbor.RemoteName = value
+		case key == "system description":
+			neighbor.Platform = value
+			description = append(description, value)
+			inDescription = true
+		case key == "system capabilities enabled" || key == "enabled capabilities":
+			neighbor.Capabilities = parseCapabilities(value)
+		case strings.HasPrefix(key, "management address") || key == "ip" || key == "ipv4":
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil && neighbor.ManagementAddress == "" {
+				neighbor.ManagementAddress = value
+			}
+		}
+	}
+	finish()
+	return neighbors
+}
+
+/**
+ * 解析cisco的CDP邻居，每个邻居以"Device ID:"开始，优先使用"Management address(es)"中的地址作为管理地址
+ * @param output show cdp neighbors detail的输出
+ * @return CDP邻居
+ * @author shenbowei
+ */
+func parseCDPNeighbors(output string) []Neighbor {
+	neighbors := make([]Neighbor, 0)
+	var neighbor *Neighbor
+	description := make([]string, 0)
+	inDescription, inManagement := false, false
+	finish := func() {
+		if neighbor != nil {
+			neighbors = append(neighbors, completeNeighbor(CISCO, *neighbor, strings.Join(description, "\n")))
+		}
+		neighbor, description, inDescription, inManagement = nil, description[:0], false, false
+	}
+	for _, line := range outputLines(output) {
+		trimmed := strings.TrimSpace(line)
+		if match := cdpPlatformRegexp.FindStringSubmatch(trimmed); match != nil && neighbor != nil {
+			neighbor.Platform = strings.TrimSpace(match[1])
+			neighbor.Capabilities = parseCapabilities(match[2])
+			continue
+		}
+		if match := cdpInterfaceRegexp.FindStringSubmatch(trimmed); match != nil && neighbor != nil {
+			neighbor.LocalInterface = strings.TrimSpace(match[1])
+			neighbor.RemoteInterface = strings.TrimSpace(match[2])
+			continue
+		}
+		index := strings.Index(trimmed, ":")
+		if index < 0 {
+			//Version之后的软件描述
+			if neighbor != nil && inDescription {
+				description = append(description, trimmed)
+			}
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(trimmed[:index]))
+		value := strings.TrimSpace(trimmed[index+1:])
+		inDescription = false
+		switch {
+		case key == "device id":
+			finish()
+			//NX-OS的Device ID后带有序列号，如"N9K-1(FDO12345)"
+			neighbor = &Neighbor{Protocol: NEIGHBOR_CDP, RemoteName: cdpSerialRegexp.ReplaceAllString(value, "")}
+		case neighbor == nil:
+		case key == "system name":
+			neighbor.RemoteName = value
+		case key == "version":
+			inDescription = true
+		case strings.HasPrefix(key, "management address"):
+			inManagement = true
+		case key == "ip address" || key == "ipv4 address":
+			if ip := net.ParseIP(value); ip != nil && (neighbor.ManagementAddress == "" || inManagement) {
+				neighbor.ManagementAddress = value
+			}
+		}
+	}
+	finish()
+	return neighbors
+}
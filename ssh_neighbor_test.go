@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"reflect"
+	"testing"
+)
+
+const huaweiLLDPSample = `GigabitEthernet0/0/1 has 1 neighbor(s):
+
+Neighbor index :1
+Chassis type   :macAddress
+Chassis ID     :0011-2233-4400
+Port ID type   :interfaceName
+Port ID        :Gi1/0/49
+Port description    :uplink to core
+System name         :Access-SW1.example.com
+System description  :Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(2)E7, RELEASE SOFTWARE (fc3)
+Technical Support: http://www.cisco.com/techsupport
+Copyright (c) 1986-2017 by Cisco Systems, Inc.
+System capabilities supported   :bridge router
+System capabilities enabled     :bridge
+Management address type  :ipv4
+Management address       :10.0.0.1
+Expired time   :104s
+
+GigabitEthernet0/0/2 has 1 neighbor(s):
+
+Neighbor index :1
+Chassis type   :macAddress
+Chassis ID     :4c1f-cc00-0003
+Port ID type   :interfaceName
+Port ID        :GigabitEthernet0/0/24
+System name         :Dist-2
+System description  :S5720-28P-LI-AC
+Huawei Versatile Routing Platform Software
+VRP (R) software, Version 5.170 (S5720 V200R011C10SPC600)
+System capabilities enabled     :bridge router
+Management address       :10.0.0.3
+`
+
+const h3cLLDPSample = `LLDP neighbor-information of port 1[GigabitEthernet1/0/1]:
+LLDP agent nearest-bridge:
+ LLDP neighbor index : 1
+ Update time         : 0 days, 0 hours, 1 minutes, 1 seconds
+ Chassis type        : MAC address
+ Chassis ID          : 0cda-41b1-2345
+ Port ID type        : Interface name
+ Port ID             : GigabitEthernet1/0/2
+ Time to live        : 121
+ Port description    : GigabitEthernet1/0/2 Interface
+ System name         : H3C-Core
+ System description  : H3C Comware Platform Software, Software Version 7.1.070, Release 6318P01
+                       H3C S6520X-30QC-EI
+ System capabilities supported : Bridge,Router
+ System capabilities enabled   : Bridge,Router
+ Management address type           : IPv4
+ Management address                : 10.2.1.1
+ Management address interface type : IfIndex
+`
+
+const ciscoLLDPSample = `Capability codes:
+    (R) Router, (B) Bridge, (T) Telephone, (C) DOCSIS Cable Device
+    (W) WLAN Access Point, (P) Repeater, (S) Station, (O) Other
+------------------------------------------------
+Local Intf: Gi1/0/49
+Chassis id: 4c1f-cc00-0002
+Port id: GigabitEthernet0/0/1
+Port Description: GigabitEthernet0/0/1 Interface
+System Name: Core-HW
+
+System Description:
+S12700
+Huawei Versatile Routing Platform Software
+VRP (R) software, Version 8.180 (S12700 V200R013C00SPC500)
+
+Time remaining: 100 seconds
+System Capabilities: B,R
+Enabled Capabilities: B,R
+Management Addresses:
+    IP: 10.0.0.2
+Auto Negotiation - supported, enabled
+Vlan ID: - not advertised
+
+Total entries displayed: 1
+`
+
+const ciscoCDPSample = `-------------------------
+Device ID: SEP001122334455
+Entry address(es):
+  IP address: 10.0.0.50
+Platform: Cisco IP Phone 8845,  Capabilities: Host Phone Two-port Mac Relay
+Interface: GigabitEthernet1/0/5,  Port ID (outgoing port): Port 1
+Holdtime : 150 sec
+
+Version :
+sip8845.12-5-1SR1-4
+
+advertisement version: 2
+Duplex: full
+
+-------------------------
+Device ID: N9K-1(FDO21120U5D)
+Entry address(es):
+  IPv4 Address: 10.0.0.9
+Platform: N9K-C93180YC-EX, Capabilities: Router Switch IGMP Filtering
+Interface: GigabitEthernet1/0/48, Port ID (outgoing port): Ethernet1/1
+Holdtime : 170 sec
+
+Version :
+Cisco Nexus Operating System (NX-OS) Software, Version 9.3(5)
+
+advertisement version: 2
+Management address(es):
+  IPv4 Address: 192.168.1.9
+`
+
+func TestParseLLDPNeighbors(t *testing.T) {
+	neighbors := parseLLDPNeighbors(HUAWEI, huaweiLLDPSample)
+	if len(neighbors) != 2 {
+		t.Fatalf("huawei neighbors:%+v", neighbors)
+	}
+	expected := Neighbor{Protocol: NEIGHBOR_LLDP, LocalInterface: "GigabitEthernet0/0/1", RemoteName: "Access-SW1.example.com",
+		RemoteChassisID: "00:11:22:33:44:00", RemoteInterface: "GigabitEthernet1/0/49", RemotePortDescription: "uplink to core",
+		RemoteBrand: CISCO, Platform: "Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(2)E7, RELEASE SOFTWARE (fc3)",
+		ManagementAddress: "10.0.0.1", Capabilities: []string{"bridge"}}
+	if !reflect.DeepEqual(neighbors[0], expected) {
+		t.Errorf("huawei neighbor 0:%+v", neighbors[0])
+	}
+	if neighbors[1].RemoteName != "Dist-2" || neighbors[1].RemoteBrand != HUAWEI || neighbors[1].Platform != "S5720-28P-LI-AC" ||
+		neighbors[1].ManagementAddress != "10.0.0.3" || !reflect.DeepEqual(neighbors[1].Capabilities, []string{"bridge", "router"}) {
+		t.Errorf("huawei neighbor 1:%+v", neighbors[1])
+	}
+
+	neighbors = parseLLDPNeighbors(H3C, h3cLLDPSample)
+	if len(neighbors) != 1 {
+		t.Fatalf("h3c neighbors:%+v", neighbors)
+	}
+	if neighbors[0].LocalInterface != "GigabitEthernet1/0/1" || neighbors[0].RemoteName != "H3C-Core" ||
+		neighbors[0].RemoteInterface != "GigabitEthernet1/0/2" || neighbors[0].RemoteBrand != H3C ||
+		neighbors[0].RemoteChassisID != "0c:da:41:b1:23:45" || neighbors[0].ManagementAddress != "10.2.1.1" {
+		t.Errorf("h3c neighbor:%+v", neighbors[0])
+	}
+
+	neighbors = parseLLDPNeighbors(CISCO, ciscoLLDPSample)
+	if len(neighbors) != 1 {
+		t.Fatalf("cisco neighbors:%+v", neighbors)
+	}
+	if neighbors[0].LocalInterface != "GigabitEthernet1/0/49" || neighbors[0].RemoteName != "Core-HW" ||
+		neighbors[0].RemoteInterface != "GigabitEthernet0/0/1" || neighbors[0].RemoteBrand != HUAWEI || neighbors[0].Platform != "S12700" ||
+		neighbors[0].ManagementAddress != "10.0.0.2" || !reflect.DeepEqual(neighbors[0].Capabilities, []string{"bridge", "router"}) {
+		t.Errorf("cisco neighbor:%+v", neighbors[0])
+	}
+}
+
+func TestParseCDPNeighbors(t *testing.T) {
+	neighbors := parseCDPNeighbors(ciscoCDPSample)
+	if len(neighbors) != 2 {
+		t.Fatalf("cdp neighbors:%+v", neighbors)
+	}
+	if neighbors[0].Protocol != NEIGHBOR_CDP || neighbors[0].RemoteName != "SEP001122334455" || neighbors[0].LocalInterface != "GigabitEthernet1/0/5" ||
+		neighbors[0].RemoteInterface != "Port 1" || neighbors[0].Platform != "Cisco IP Phone 8845" || neighbors[0].ManagementAddress != "10.0.0.50" {
+		t.Errorf("cdp neighbor 0:%+v", neighbors[0])
+	}
+	if neighbors[1].RemoteName != "N9K-1" || neighbors[1].RemoteInterface != "Ethernet1/1" || neighbors[1].RemoteBrand != CISCO ||
+		neighbors[1].ManagementAddress != "192.168.1.9" || neighbors[1].Capabilities[0] != "router" {
+		t.Errorf("cdp neighbor 1:%+v", neighbors[1])
+	}
+}
+
+func TestMergeNeighbors(t *testing.T) {
+	lldp := []Neighbor{{Protocol: NEIGHBOR_LLDP, LocalInterface: "GigabitEthernet1/0/1", RemoteInterface: "GigabitEthernet0/1"}}
+	cdp := []Neighbor{
+		{Protocol: NEIGHBOR_CDP, LocalInterface: "GigabitEthernet1/0/1", RemoteInterface: "GigabitEthernet0/1"},
+		{Protocol: NEIGHBOR_CDP, LocalInterface: "GigabitEthernet1/0/2", RemoteInterface: "Port 1"},
+	}
+	merged := mergeNeighbors(lldp, cdp)
+	if len(merged) != 2 || merged[0].Protocol != NEIGHBOR_LLDP || merged[1].LocalInterface != "GigabitEthernet1/0/2" {
+		t.Errorf("unexpected merged neighbors:%+v", merged)
+	}
+}
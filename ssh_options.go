@@ -0,0 +1,278 @@
+package ssh
+
+import (
+	"golang.org/x/crypto/ssh"
+	"net"
+	"time"
+)
+
+// 默认的配置，与之前写死在代码中的值保持一致
+const (
+	DefaultIdleTimeout    = 10 * time.Minute
+	DefaultCleanInterval  = 30 * time.Second
+	DefaultDialTimeout    = 20 * time.Second
+	DefaultCommandTimeout = 2 * time.Second
+	DefaultPromptTimeout  = time.Second
+)
+
+var DefaultCiphers = []string{"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-gcm@openssh.com",
+	"arcfour256", "arcfour128", "aes128-cbc", "aes256-cbc", "3des-cbc", "des-cbc",
+}
+
+/**
+ * 日志接口，可以通过WithLogger替换SessionManager及其session的日志输出
+ * @author shenbowei
+ */
+type Logger interface {
+	Debug(format string, a ...interface{})
+	Error(format string, a ...interface{})
+}
+
+/**
+ * 默认的日志实现，使用包级别的LogDebug和LogError（受IsLogDebug控制）
+ * @author shenbowei
+ */
+type defaultLogger struct{}
+
+func (defaultLogger) Debug(format string, a ...interface{}) {
+	LogDebug(format, a...)
+}
+
+func (defaultLogger) Error(format string, a ...interface{}) {
+	LogError(format, a...)
+}
+
+/**
+ * SessionManager及其创建的session的配置
+ * @attr idleTimeout:session未使用的超时时间，cleanInterval:自动清理的间隔，dialTimeout:连接超时时间，
+ *       ciphers/keyExchanges/macs:ssh算法，hostKeyCallback:主机密钥校验，commandTimeout:读取指令输出的超时时间，
+ *       promptTimeout:等待提示符的超时时间，logger:日志，maxSessions:最多缓存的设备数量（0为不限制），
+ *       maxShellsPerDevice:每个设备在同一个ssh连接上最多同时打开的shell数量，execModes:按品牌设置的指令执行方式（""为所有品牌），
+ *       dialer:建立tcp连接的Dialer（默认直接连接），passwordChangePolicy:登录后遇到修改密码提示时的处理方式
+ * @author shenbowei
+ */
+type sessionConfig struct {
+	idleTimeout          time.Duration
+	cleanInterval        time.Duration
+	dialTimeout          time.Duration
+	ciphers              []string
+	keyExchanges         []string
+	macs                 []string
+	hostKeyCallback      ssh.HostKeyCallback
+	commandTimeout       time.Duration
+	promptTimeout        time.Duration
+	logger               Logger
+	maxSessions          int
+	maxShellsPerDevice   int
+	execModes            map[string]string
+	dialer               Dialer
+	passwordChangePolicy string
+}
+
+/**
+ * 创建默认配置
+ * @return 默认的sessionConfig
+ * @author shenbowei
+ */
+func newSessionConfig() *sessionConfig {
+	return &sessionConfig{
+		idleTimeout:   DefaultIdleTimeout,
+		cleanInterval: DefaultCleanInterval,
+		dialTimeout:   DefaultDialTimeout,
+		ciphers:       DefaultCiphers,
+		hostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+		commandTimeout:       DefaultCommandTimeout,
+		promptTimeout:        DefaultPromptTimeout,
+		logger:               defaultLogger{},
+		maxShellsPerDevice:   1,
+		execModes:            make(map[string]string),
+		dialer:               &net.Dialer{},
+		passwordChangePolicy: PASSWORD_CHANGE_SKIP,
+	}
+}
+
+/**
+ * 根据配置生成ssh连接的ClientConfig
+ * @param user ssh连接的用户名, password 密码
+ * @return *ssh.ClientConfig
+ * @author shenbowei
+ */
+func (this *sessionConfig) clientConfig(user, password string) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(password),
+		},
+		HostKeyCallback: this.hostKeyCallback,
+		Timeout:         this.dialTimeout,
+		Config: ssh.Config{
+			Ciphers:      this.ciphers,
+			KeyExchanges: this.keyExchanges,
+			MACs:         this.macs,
+		},
+	}
+}
+
+/**
+ * SessionManager的配置项，通过NewSessionManager(opts...)传入
+ * @author shenbowei
+ */
+type Option func(*sessionConfig)
+
+/**
+ * 设置session未使用的超时时间，超时后会被自动清理（默认10分钟）
+ * @author shenbowei
+ */
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(config *sessionConfig) {
+		config.idleTimeout = timeout
+	}
+}
+
+/**
+ * 设置自动清理超时session的间隔（默认30秒）
+ * @author shenbowei
+ */
+func WithCleanInterval(interval time.Duration) Option {
+	return func(config *sessionConfig) {
+		config.cleanInterval = interval
+	}
+}
+
+/**
+ * 设置ssh连接的超时时间（默认20秒）
+ * @author shenbowei
+ */
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(config *sessionConfig) {
+		config.dialTimeout = timeout
+	}
+}
+
+/**
+ * 设置ssh连接可用的加密算法（默认DefaultCiphers）
+ * @author shenbowei
+ */
+func WithCiphers(ciphers ...string) Option {
+	return func(config *sessionConfig) {
+		config.ciphers = ciphers
+	}
+}
+
+/**
+ * 设置ssh连接可用的密钥交换算法（默认使用x/crypto/ssh的默认值）
+ * @author shenbowei
+ */
+func WithKeyExchanges(keyExchanges ...string) Option {
+	return func(config *sessionConfig) {
+		config.keyExchanges = keyExchanges
+	}
+}
+
+/**
+ * 设置ssh连接可用的MAC算法（默认使用x/crypto/ssh的默认值）
+ * @author shenbowei
+ */
+func WithMACs(macs ...string) Option {
+	return func(config *sessionConfig) {
+		config.macs = macs
+	}
+}
+
+/**
+ * 设置主机密钥的校验方法（默认不校验）
+ * @author shenbowei
+ */
+func WithHostKeyCallback(callback ssh.HostKeyCallback) Option {
+	return func(config *sessionConfig) {
+		config.hostKeyCallback = callback
+	}
+}
+
+/**
+ * 设置读取指令输出时的超时时间（默认2秒），设备超过该时间没有输出即认为指令执行完成；
+ * exec方式下为每条指令的执行时间上限，超时后关闭通道并返回ErrExecTimeout
+ * @author shenbowei
+ */
+func WithCommandTimeout(timeout time.Duration) Option {
+	return func(config *sessionConfig) {
+		config.commandTimeout = timeout
+	}
+}
+
+/**
+ * 设置等待设备提示符（登录、检查session、禁用分页等）时的超时时间（默认1秒）
+ * @author shenbowei
+ */
+func WithPromptTimeout(timeout time.Duration) Option {
+	return func(config *sessionConfig) {
+		config.promptTimeout = timeout
+	}
+}
+
+/**
+ * 设置日志输出（默认使用LogDebug和LogError）
+ * @author shenbowei
+ */
+func WithLogger(logger Logger) Option {
+	return func(config *sessionConfig) {
+		config.logger = logger
+	}
+}
+
+/**
+ * 设置最多缓存的设备数量，超过后新设备的连接会返回ErrMaxSessions（默认0，不限制）
+ * @author shenbowei
+ */
+func WithMaxSessions(maxSessions int) Option {
+	return func(config *sessionConfig) {
+		config.maxSessions = maxSessions
+	}
+}
+
+/**
+ * 设置每个设备在同一个ssh连接上最多同时打开的shell数量（默认1，即同一设备的调用串行执行），
+ * 实际数量还会受到Device.MaxVTY的限制
+ * @author shenbowei
+ */
+func WithMaxShellsPerDevice(maxShells int) Option {
+	return func(config *sessionConfig) {
+		config.maxShellsPerDevice = maxShells
+	}
+}
+
+/**
+ * 设置指令的执行方式（SHELL_MODE或EXEC_MODE），brand为""时对所有品牌生效，Device.ExecMode优先于该设置
+ * @author shenbowei
+ */
+func WithExecMode(brand, mode string) Option {
+	return func(config *sessionConfig) {
+		config.execModes[brand] = mode
+	}
+}
+
+/**
+ * 设置建立tcp连接的Dialer（默认直接连接），可以使用NewSOCKS5Dialer、NewHTTPProxyDialer、NewLocalAddrDialer或自定义实现，
+ * Device.Dialer不为nil时优先使用Device.Dialer
+ * @author shenbowei
+ */
+func WithDialer(dialer Dialer) Option {
+	return func(config *sessionConfig) {
+		if dialer != nil {
+			config.dialer = dialer
+		}
+	}
+}
+
+/**
+ * 设置登录后遇到修改密码提示（如华为的"Change now? [Y/N]"）时的处理方式：PASSWORD_CHANGE_SKIP（默认）回答N跳过，
+ * PASSWORD_CHANGE_FAIL直接返回ErrPasswordChangeRequired。设备强制修改密码时都会返回ErrPasswordChangeRequired
+ * @author shenbowei
+ */
+func WithPasswordChangePolicy(policy string) Option {
+	return func(config *sessionConfig) {
+		config.passwordChangePolicy = policy
+	}
+}
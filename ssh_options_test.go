@@ -0,0 +1,127 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+ * 测试使用的日志实现，记录收到的日志
+ */
+type recordLogger struct {
+	locker sync.Mutex
+	debugs []string
+	errors []string
+}
+
+func (this *recordLogger) Debug(format string, a ...interface{}) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	this.debugs = append(this.debugs, fmt.Sprintf(format, a...))
+}
+
+func (this *recordLogger) Error(format string, a ...interface{}) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	this.errors = append(this.errors, fmt.Sprintf(format, a...))
+}
+
+func (this *recordLogger) contains(logs *[]string, text string) bool {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	for _, log := range *logs {
+		if strings.Contains(log, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNewSessionConfigOptions(t *testing.T) {
+	logger := &recordLogger{}
+	config := newSessionConfig()
+	for _, opt := range []Option{
+		WithIdleTimeout(time.Minute),
+		WithCleanInterval(time.Second),
+		WithDialTimeout(3 * time.Second),
+		WithCommandTimeout(4 * time.Second),
+		WithPromptTimeout(5 * time.Second),
+		WithMaxSessions(2),
+		WithMaxShellsPerDevice(3),
+		WithLogger(logger),
+		WithExecMode(CISCO, EXEC_MODE),
+	} {
+		opt(config)
+	}
+	if config.idleTimeout != time.Minute || config.cleanInterval != time.Second || config.dialTimeout != 3*time.Second ||
+		config.commandTimeout != 4*time.Second || config.promptTimeout != 5*time.Second {
+		t.Errorf("unexpected timeouts:%+v", config)
+	}
+	if config.maxSessions != 2 || config.maxShellsPerDevice != 3 || config.logger != logger || config.execModes[CISCO] != EXEC_MODE {
+		t.Errorf("unexpected config:%+v", config)
+	}
+}
+
+func TestWithMaxSessions(t *testing.T) {
+	fakeA := newFakeSwitch(t, "<HUAWEI-A>", nil)
+	fakeB := newFakeSwitch(t, "<HUAWEI-B>", nil)
+	manager := newTestSessionManager(WithMaxSessions(1))
+	defer manager.Shutdown(context.Background())
+
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fakeA.addr(), HUAWEI, "dis clock"); err != nil {
+		t.Fatalf("RunCommands<A> err:%s", err)
+	}
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fakeB.addr(), HUAWEI, "dis clock"); err != ErrMaxSessions {
+		t.Errorf("RunCommands<B> err=%v, expected ErrMaxSessions", err)
+	}
+	//已经缓存的设备不受限制
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fakeA.addr(), HUAWEI, "dis clock"); err != nil {
+		t.Errorf("RunCommands<A> err:%s", err)
+	}
+	//被拒绝的设备的连接已经关闭
+	if !fakeB.waitActiveConns(0, 2*time.Second) {
+		t.Errorf("rejected connection is not closed, active=%d", fakeB.getActiveConns())
+	}
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	fakeA := newFakeSwitch(t, "<HUAWEI-A>", nil)
+	fakeB := newFakeSwitch(t, "<HUAWEI-B>", nil)
+	manager := newTestSessionManager(WithMaxSessions(1), WithIdleTimeout(200*time.Millisecond), WithCleanInterval(50*time.Millisecond))
+	defer manager.Shutdown(context.Background())
+
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fakeA.addr(), HUAWEI, "dis clock"); err != nil {
+		t.Fatalf("RunCommands<A> err:%s", err)
+	}
+	if !fakeA.waitActiveConns(0, 3*time.Second) {
+		t.Fatalf("idle connection is not closed, active=%d", fakeA.getActiveConns())
+	}
+	//清理后释放了缓存的名额，之后可以连接其他设备
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fakeB.addr(), HUAWEI, "dis clock"); err != nil {
+		t.Errorf("RunCommands<B> after idle clean err:%s", err)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", map[string]string{"dis version": "Huawei Versatile Routing Platform Software"})
+	logger := &recordLogger{}
+	manager := newTestSessionManager(WithLogger(logger))
+	defer manager.Shutdown(context.Background())
+
+	if _, err := manager.GetSSHBrand("admin", "admin", fake.addr()); err != nil {
+		t.Fatalf("GetSSHBrand err:%s", err)
+	}
+	if !logger.contains(&logger.debugs, "The switch brand is <huawei>") {
+		t.Errorf("custom logger did not receive the debug output:%v", logger.debugs)
+	}
+	if _, err := manager.RunCommandsWithBrand("admin", "wrong", "127.0.0.1:1", HUAWEI, "dis clock"); err == nil {
+		t.Fatalf("RunCommands to a closed port succeeded")
+	}
+	if !logger.contains(&logger.errors, "127.0.0.1:1") {
+		t.Errorf("custom logger did not receive the error output:%v", logger.errors)
+	}
+}
@@ -0,0 +1,510 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"sync"
+	"time"
+)
+
+var errPoolClosed = errors.New("ssh: device pool is closed")
+
+/**
+ * 设备连接池的统计信息
+ * @attr MaxShells:最多可同时打开的shell数量，OpenShells:已打开的shell数量，IdleShells:空闲的shell数量，InUse:正在使用的shell数量，
+ *       Checkouts:获取shell的总次数，Waits:需要排队等待的次数，TotalWait:排队等待的总时间，MaxWait:最长的一次排队等待时间
+ * @author shenbowei
+ */
+type PoolStats struct {
+	MaxShells  int
+	OpenShells int
+	IdleShells int
+	InUse      int
+	Checkouts  int64
+	Waits      int64
+	TotalWait  time.Duration
+	MaxWait    time.Duration
+}
+
+/**
+ * 获取平均每次获取shell的等待时间
+ * @return 平均等待时间
+ * @author shenbowei
+ */
+func (this PoolStats) AverageWait() time.Duration {
+	if this.Checkouts == 0 {
+		return 0
+	}
+	return this.TotalWait / time.Duration(this.Checkouts)
+}
+
+/**
+ * 单个设备的连接池，在同一个ssh连接上最多打开maxShells个shell（SSHSession），按请求的先后顺序分配，
+ * 使用telnet时没有共享的连接，每个shell（TelnetSession）都是独立的tcp连接
+ * @attr device:设备的身份信息，transport:实际使用的传输方式，client:共享的ssh连接（telnet时为nil），banner:ssh连接认证前的banner，
+ *       outer:外层设备的连接池（从外层设备的命令行登录时，与SessionManager缓存中的外层设备共用），jumps:跳板机连接的缓存，jump:引用的跳板机连接（直接连接时为nil），dialer:连接设备使用的Dialer（为nil时使用配置的Dialer），detection:Device.Brand或者第一个shell识别出的设备品牌，slots:可用名额（获取shell前必须占用一个名额，
+ *       channel的等待队列保证先到先得），idle:空闲的shell，closing:关闭后通知排队的调用者退出，locker:保护以下的状态和统计信息，
+ *       inUse:占用名额的调用数量，openShells:已打开的shell数量，broken:连接已不可用，closed:已关闭，
+ *       execRejected:设备拒绝过exec请求，lastUse:最后一次归还名额的时间，stats:统计信息
+ * @author shenbowei
+ */
+type devicePool struct {
+	device       Device
+	config       *sessionConfig
+	transport    string
+	client       *ssh.Client
+	banner       string
+	outer        *devicePool
+	jumps        *jumpCache
+	jump         *jumpClient
+	dialer       Dialer
+	maxShells    int
+	detection    BrandDetection
+	slots        chan struct{}
+	idle         chan Session
+	closing      chan struct{}
+	locker       sync.Mutex
+	inUse        int
+	openShells   int
+	broken       bool
+	closed       bool
+	execRejected bool
+	lastUse      time.Time
+	stats        PoolStats
+}
+
+/**
+ * 创建设备的连接池：按设备的传输方式建立ssh连接（AUTO_TRANSPORT时ssh连接失败回退到telnet），
+ * 打开并初始化第一个shell（等待登录，识别设备类型，提权，执行禁止分页）。设置了跳板机时先获取共享的跳板机连接，再通过它连接设备，
+ * 设置了Device.Console时连接控制台服务器的线路后登录设备，
+ * 设置了Device.Via时从外层设备的连接池中获取外层设备的shell，再从外层设备的命令行登录
+ * @param device 设备的身份信息, outer 外层设备的连接池（设置了Device.Via时不为nil）, config 连接的配置, jumps 跳板机连接的缓存
+ * @return 连接池，执行的错误
+ * @author shenbowei
+ */
+func newDevicePool(device Device, outer *devicePool, config *sessionConfig, jumps *jumpCache) (*devicePool, error) {
+	maxShells := config.maxShellsPerDevice
+	if maxShells <= 0 {
+		maxShells = 1
+	}
+	if device.MaxVTY > 0 && device.MaxVTY < maxShells {
+		maxShells = device.MaxVTY
+	}
+	if outer != nil {
+		return startDevicePool(&devicePool{
+			device:    device,
+			config:    config,
+			transport: device.Transport,
+			outer:     outer,
+			maxShells: maxShells,
+			detection: deviceDetection(device),
+			slots:     make(chan struct{}, maxShells),
+			idle:      make(chan Session, maxShells),
+			closing:   make(chan struct{}),
+		})
+	}
+	var jump *jumpClient
+	dialer := device.Dialer
+	if len(device.JumpHosts) > 0 {
+		var err error
+		jump, err = jumps.acquire(device.JumpHosts, dialer)
+		if err != nil {
+			return nil, err
+		}
+		dialer = sshClientDialer{client: jump.client}
+	}
+	transport := TELNET_TRANSPORT
+	var client *ssh.Client
+	banner := ""
+	if device.Console != nil {
+		//控制台线路同时只能有一个登录
+		transport = consoleTransport
+		maxShells = 1
+	} else if device.Transport != TELNET_TRANSPORT {
+		var err error
+		client, banner, err = dialClientThrough(dialer, device.Address(), config.clientConfig(device.User, device.password()), config)
+		if err == nil {
+			transport = SSH_TRANSPORT
+		} else if device.Transport != AUTO_TRANSPORT {
+			if jump != nil {
+				jumps.release(jump)
+			}
+			return nil, err
+		} else {
+			config.logger.Debug("Dial ssh<%s> err:%s, fallback to telnet", device, err.Error())
+		}
+	}
+	return startDevicePool(&devicePool{
+		device:    device,
+		config:    config,
+		transport: transport,
+		client:    client,
+		banner:    banner,
+		jumps:     jumps,
+		jump:      jump,
+		dialer:    dialer,
+		maxShells: maxShells,
+		detection: deviceDetection(device),
+		slots:     make(chan struct{}, maxShells),
+		idle:      make(chan Session, maxShells),
+		closing:   make(chan struct{}),
+	})
+}
+
+/**
+ * 打开连接池的第一个shell，失败时关闭连接池的连接。需要按品牌确定执行方式且品牌未知时，先通过exec通道识别品牌，
+ * 使用EXEC_MODE的设备不打开shell，之后需要时再打开
+ * @param pool 已经建立连接的连接池
+ * @return 连接池，执行的错误
+ * @author shenbowei
+ */
+func startDevicePool(pool *devicePool) (*devicePool, error) {
+	pool.lastUse = time.Now()
+	if pool.client != nil && pool.detection.Brand == "" && len(pool.config.execModes) > 0 && pool.detectBrandByExec() &&
+		pool.config.getExecMode(pool.device, pool.getBrand()) == EXEC_MODE {
+		return pool, nil
+	}
+	session, err := pool.openShell()
+	if err != nil {
+		pool.closeClient()
+		return nil, err
+	}
+	if pool.outer != nil {
+		//经过外层设备时只验证能够登录，不保留占用外层设备shell的空闲会话
+		pool.discardShell(session)
+		return pool, nil
+	}
+	pool.idle <- session
+	return pool, nil
+}
+
+/**
+ * 从连接池获取一个shell，没有空闲的shell时打开新的shell，已达到上限时按先后顺序排队等待
+ * @param ctx 等待的上下文
+ * @return 获取到的shell，执行的错误（连接池已关闭时返回errPoolClosed）
+ * @author shenbowei
+ */
+func (this *devicePool) checkout(ctx context.Context) (Session, error) {
+	waitStart := time.Now()
+	waited := false
+	select {
+	case this.slots <- struct{}{}:
+	default:
+		waited = true
+		select {
+		case this.slots <- struct{}{}:
+		case <-this.closing:
+			return nil, errPoolClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	this.locker.Lock()
+	if this.closed {
+		this.locker.Unlock()
+		<-this.slots
+		return nil, errPoolClosed
+	}
+	this.inUse++
+	this.recordCheckout(waited, time.Now().Sub(waitStart))
+	this.locker.Unlock()
+
+	session, err := this.takeShell()
+	if err != nil {
+		this.releaseSlot()
+		return nil, err
+	}
+	return session, nil
+}
+
+/**
+ * 记录一次获取shell的统计信息，调用前需要持有locker
+ * @param waited 是否排队等待过, waitTime 等待的时间
+ * @author shenbowei
+ */
+func (this *devicePool) recordCheckout(waited bool, waitTime time.Duration) {
+	this.stats.Checkouts++
+	if !waited {
+		return
+	}
+	this.stats.Waits++
+	this.stats.TotalWait += waitTime
+	if waitTime > this.stats.MaxWait {
+		this.stats.MaxWait = waitTime
+	}
+}
+
+/**
+ * 取出一个可用的空闲shell，没有时打开新的shell，调用前需要已经占用名额
+ * @return 可用的shell，执行的错误
+ * @author shenbowei
+ */
+func (this *devicePool) takeShell() (Session, error) {
+	for {
+		select {
+		case session := <-this.idle:
+			if session.CheckSelf() {
+				session.UpdateLastUseTime()
+				return session, nil
+			}
+			this.config.logger.Debug("Check session<%s> failed", this.device)
+			this.discardShell(session)
+			continue
+		default:
+		}
+		return this.openShell()
+	}
+}
+
+/**
+ * 在共享的ssh连接上打开并初始化一个新的shell（telnet时建立新的连接并登录，经过外层设备时从外层设备的命令行登录，
+ * 通过控制台时连接线路并登录），
+ * 连接已不可用时将连接池标记为broken
+ * @return 新的shell，执行的错误
+ * @author shenbowei
+ */
+func (this *devicePool) openShell() (Session, error) {
+	var session Session
+	if this.outer != nil {
+		hopSession, err := this.openHopShell()
+		if err != nil {
+			return nil, err
+		}
+		session = hopSession
+	} else if this.transport == consoleTransport {
+		consoleSession, err := newConsoleSession(this.dialer, *this.device.Console, this.device.User, this.device.password(), this.config)
+		if err != nil {
+			return nil, err
+		}
+		session = consoleSession
+	} else if this.transport == TELNET_TRANSPORT {
+		telnetSession, err := newTelnetSession(this.dialer, this.device.User, this.device.password(), this.device.telnetAddress(), this.config)
+		if err != nil {
+			return nil, err
+		}
+		session = telnetSession
+	} else {
+		sshSession, err := newSSHSessionOnClient(this.client, this.config, false)
+		if err != nil {
+			this.locker.Lock()
+			this.broken = true
+			this.locker.Unlock()
+			return nil, err
+		}
+		sshSession.banner = this.banner
+		//部分设备在shell中再次要求登录或者修改密码
+		if err := sshSession.login(this.device.User, this.device.password(), this.config.promptTimeout); err != nil {
+			this.config.logger.Error("Shell login<%s> err:%s", this.device, err.Error())
+			sshSession.Close()
+			return nil, err
+		}
+		session = sshSession
+	}
+	if err := this.initShell(session); err != nil {
+		this.config.logger.Error("initSession<%s> err:%s", this.device, err.Error())
+		session.Close()
+		return nil, err
+	}
+	session.shell().pool = this
+	this.locker.Lock()
+	this.openShells++
+	this.locker.Unlock()
+	return session, nil
+}
+
+/**
+ * 初始化shell（识别设备类型，提权，执行禁止分页），设备类型只在第一个shell识别，之后的shell直接使用
+ * @param session 需要执行初始化操作的shell
+ * @return 执行的错误（提权失败）
+ * @author shenbowei
+ */
+func (this *devicePool) initShell(session Session) error {
+	this.locker.Lock()
+	detection := this.detection
+	this.locker.Unlock()
+	brand := detection.Brand
+	if brand != HUAWEI && brand != H3C && brand != CISCO {
+		//如果传入的设备型号不匹配则自己获取
+		detection = session.DetectBrand()
+		brand = detection.Brand
+		this.locker.Lock()
+		this.detection = detection
+		this.locker.Unlock()
+	} else {
+		session.shell().setDetection(detection)
+	}
+	if err := enableSession(session, brand, this.device.enablePassword()); err != nil {
+		return err
+	}
+	switch brand {
+	case HUAWEI:
+		session.WriteChannel(HuaweiNoPage)
+		break
+	case H3C:
+		session.WriteChannel(H3cNoPage)
+		break
+	case CISCO:
+		session.WriteChannel(CiscoNoPage)
+		break
+	default:
+		return nil
+	}
+	session.ReadChannelExpect(this.config.promptTimeout, "#", ">", "]")
+	return nil
+}
+
+/**
+ * 关闭一个不可用的shell，并更新已打开的shell数量
+ * @param session 需要关闭的shell
+ * @author shenbowei
+ */
+func (this *devicePool) discardShell(session Session) {
+	session.Close()
+	this.locker.Lock()
+	this.openShells--
+	this.locker.Unlock()
+}
+
+/**
+ * 将shell归还给连接池，已关闭的shell会被丢弃。经过外层设备的shell不保留为空闲，
+ * 退回外层设备并将外层设备的shell归还给外层连接池，下次获取时重新登录
+ * @param session 需要归还的shell
+ * @author shenbowei
+ */
+func (this *devicePool) release(session Session) {
+	if this.outer != nil || session.IsClosed() {
+		this.discardShell(session)
+	} else {
+		session.UpdateLastUseTime()
+		this.idle <- session
+	}
+	this.releaseSlot()
+}
+
+/**
+ * 释放占用的名额，排队的第一个调用者会获得该名额
+ * @author shenbowei
+ */
+func (this *devicePool) releaseSlot() {
+	this.locker.Lock()
+	this.inUse--
+	this.lastUse = time.Now()
+	this.locker.Unlock()
+	<-this.slots
+}
+
+/**
+ * 关闭空闲超过idleTimeout的shell，所有shell都关闭、没有调用在使用且空闲超过idleTimeout时关闭连接池
+ * @param idleTimeout 空闲超时时间
+ * @return true:连接池已经关闭，需要从缓存中移除
+ * @author shenbowei
+ */
+func (this *devicePool) cleanIdle(idleTimeout time.Duration) bool {
+	for i := len(this.idle); i > 0; i-- {
+		//像调用者一样先占用名额，避免检查期间有调用者额外打开shell导致超过上限
+		select {
+		case this.slots <- struct{}{}:
+		default:
+			return false
+		}
+		select {
+		case session := <-this.idle:
+			if time.Now().Sub(session.GetLastUseTime()) > idleTimeout {
+				this.config.logger.Debug("RunAutoClean close session<%s, unuse time=%s>", this.device, time.Now().Sub(session.GetLastUseTime()).String())
+				this.discardShell(session)
+			} else {
+				this.idle <- session
+			}
+		default:
+		}
+		<-this.slots
+	}
+	this.locker.Lock()
+	//没有shell的连接池（只使用exec通道或经过外层设备）按最后一次使用的时间判断
+	if this.closed || this.inUse > 0 || this.openShells > 0 || time.Now().Sub(this.lastUse) <= idleTimeout {
+		this.locker.Unlock()
+		return false
+	}
+	this.closed = true
+	close(this.closing)
+	this.locker.Unlock()
+	this.closeClient()
+	return true
+}
+
+/**
+ * 判断连接池的ssh连接是否已不可用
+ * @return true:不可用
+ * @author shenbowei
+ */
+func (this *devicePool) isBroken() bool {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.broken
+}
+
+/**
+ * 获取设备的品牌（第一个shell识别出的品牌）
+ * @return 品牌
+ * @author shenbowei
+ */
+func (this *devicePool) getBrand() string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.detection.Brand
+}
+
+/**
+ * 获取连接池的统计信息
+ * @return PoolStats
+ * @author shenbowei
+ */
+func (this *devicePool) getStats() PoolStats {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	stats := this.stats
+	stats.MaxShells = this.maxShells
+	stats.OpenShells = this.openShells
+	stats.IdleShells = len(this.idle)
+	stats.InUse = this.inUse
+	return stats
+}
+
+/**
+ * 关闭连接池：通知排队的调用者退出，等待正在使用的shell归还后关闭所有shell和ssh连接
+ * @author shenbowei
+ */
+func (this *devicePool) close() {
+	this.locker.Lock()
+	if this.closed {
+		this.locker.Unlock()
+		return
+	}
+	this.closed = true
+	close(this.closing)
+	this.locker.Unlock()
+	//占满所有名额，即等待所有正在使用的shell归还
+	for i := 0; i < this.maxShells; i++ {
+		this.slots <- struct{}{}
+	}
+	for len(this.idle) > 0 {
+		this.discardShell(<-this.idle)
+	}
+	this.closeClient()
+}
+
+/**
+ * 关闭共享的ssh连接（telnet时没有共享的连接），并释放引用的跳板机连接。外层设备的连接池属于SessionManager的缓存，不在这里关闭
+ * @author shenbowei
+ */
+func (this *devicePool) closeClient() {
+	if this.client != nil {
+		if err := this.client.Close(); err != nil {
+			this.config.logger.Debug("Close client<%s> err:%s", this.device, err.Error())
+		}
+	}
+	if this.jump != nil {
+		this.jumps.release(this.jump)
+	}
+}
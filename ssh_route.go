@@ -0,0 +1,491 @@
+package ssh
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 路由的协议
+const (
+	ROUTE_CONNECTED = "connected"
+	ROUTE_LOCAL     = "local" //设备自身接口地址的主机路由
+	ROUTE_STATIC    = "static"
+	ROUTE_OSPF      = "ospf"
+	ROUTE_BGP       = "bgp"
+	ROUTE_ISIS      = "isis"
+	ROUTE_RIP       = "rip"
+	ROUTE_EIGRP     = "eigrp"
+)
+
+const defaultTraceMaxHops = 16
+
+var (
+	ErrNoRoute      = errors.New("ssh: no route to the destination")
+	ErrRoutingLoop  = errors.New("ssh: routing loop detected")
+	ErrTooManyHops  = errors.New("ssh: too many hops to the destination")
+	ErrInvalidRoute = errors.New("ssh: invalid destination address")
+)
+
+/**
+ * 路由的下一跳
+ * @attr Address:下一跳地址（直连和本地路由为""），Interface:规范化后的出接口（递归路由可能为""）
+ * @author shenbowei
+ */
+type NextHop struct {
+	Address   string
+	Interface string
+}
+
+/**
+ * 路由表项
+ * @attr VRF:所属VPN实例/VRF（公网为""），Prefix:规范化的前缀（如"10.1.0.0/16"），Protocol:协议（ROUTE_CONNECTED、ROUTE_OSPF等，无法识别时为设备输出的小写形式），
+ *       Preference:华为/h3c的优先级或cisco的管理距离，Metric:开销，NextHops:下一跳（等价路由有多个）
+ * @author shenbowei
+ */
+type Route struct {
+	VRF        string
+	Prefix     string
+	Protocol   string
+	Preference int
+	Metric     int64
+	NextHops   []NextHop
+}
+
+// 获取路由表时各品牌执行的指令，查看VPN实例/VRF时在指令后追加实例名
+var (
+	HuaweiRouteCmd    = "display ip routing-table"
+	HuaweiVPNRouteCmd = "display ip routing-table vpn-instance "
+	CiscoRouteCmd     = "show ip route"
+	CiscoVRFRouteCmd  = "show ip route vrf "
+)
+
+/**
+ * 获取设备的IPv4路由表
+ * @param device 设备的身份信息, vrf VPN实例/VRF的名称（为""时获取公网路由表）
+ * @return 路由表项，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetRouteTable(device Device, vrf string) ([]Route, error) {
+	huaweiCmd, ciscoCmd := HuaweiRouteCmd, CiscoRouteCmd
+	if vrf != "" {
+		huaweiCmd, ciscoCmd = HuaweiVPNRouteCmd+vrf, CiscoVRFRouteCmd+vrf
+	}
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {huaweiCmd},
+		H3C:    {huaweiCmd},
+		CISCO:  {ciscoCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if brand == CISCO {
+		return parseCiscoRoutes(vrf, outputs[ciscoCmd]), nil
+	}
+	return parseHuaweiRoutes(brand, vrf, outputs[huaweiCmd]), nil
+}
+
+// 华为/h3c路由协议名称（小写）对应的协议
+var huaweiRouteProtocols = map[string]string{
+	"direct": ROUTE_CONNECTED, "static": ROUTE_STATIC,
+	"ospf": ROUTE_OSPF, "o_intra": ROUTE_OSPF, "o_inter": ROUTE_OSPF, "o_ase": ROUTE_OSPF, "o_nssa": ROUTE_OSPF,
+	"o_ase1": ROUTE_OSPF, "o_ase2": ROUTE_OSPF, "o_nssa1": ROUTE_OSPF, "o_nssa2": ROUTE_OSPF,
+	"bgp": ROUTE_BGP, "ibgp": ROUTE_BGP, "ebgp": ROUTE_BGP,
+	"isis": ROUTE_ISIS, "is_l1": ROUTE_ISIS, "is_l2": ROUTE_ISIS, "is-is": ROUTE_ISIS,
+	"rip": ROUTE_RIP,
+}
+
+// cisco路由代码（第一个字符）对应的协议
+var ciscoRouteProtocols = map[string]string{
+	"C": ROUTE_CONNECTED, "L": ROUTE_LOCAL, "S": ROUTE_STATIC, "O": ROUTE_OSPF, "B": ROUTE_BGP,
+	"i": ROUTE_ISIS, "R": ROUTE_RIP, "D": ROUTE_EIGRP,
+}
+
+// NX-OS路由协议名称（去掉进程号）对应的协议
+var nxosRouteProtocols = map[string]string{
+	"direct": ROUTE_CONNECTED, "local": ROUTE_LOCAL, "static": ROUTE_STATIC, "ospf": ROUTE_OSPF,
+	"bgp": ROUTE_BGP, "isis": ROUTE_ISIS, "rip": ROUTE_RIP, "eigrp": ROUTE_EIGRP,
+}
+
+/**
+ * 规范化前缀
+ * @param prefix 形如"10.1.1.0/24"的前缀
+ * @return 规范化的前缀，不是合法前缀时返回""
+ * @author shenbowei
+ */
+func normalizePrefix(prefix string) string {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return ""
+	}
+	return network.String()
+}
+
+/**
+ * 补全路由：直连和本地路由的下一跳地址为设备自身的地址，去掉地址只保留出接口。
+ * 华为/h3c没有单独的本地路由协议，下一跳为127.0.0.1的直连路由视为本地路由
+ * @param route 路由
+ * @return 补全后的路由
+ * @author shenbowei
+ */
+func completeRoute(route Route) Route {
+	if route.Protocol == ROUTE_CONNECTED && len(route.NextHops) > 0 && strings.HasPrefix(route.NextHops[0].Address, "127.") {
+		route.Protocol = ROUTE_LOCAL
+	}
+	if route.Protocol == ROUTE_CONNECTED || route.Protocol == ROUTE_LOCAL {
+		for i := range route.NextHops {
+			route.NextHops[i].Address = ""
+		}
+	}
+	return route
+}
+
+/**
+ * 解析华为/h3c的路由表。每行为"前缀 协议 优先级 开销 [标志] 下一跳 出接口"，华为的等价路由在下一行省略前缀，
+ * h3c的等价路由在下一行只有"下一跳 出接口"
+ * @param brand 设备品牌, vrf VPN实例, output display ip routing-table的输出
+ * @return 路由表项
+ * @author shenbowei
+ */
+func parseHuaweiRoutes(brand, vrf, output string) []Route {
+	routes := make([]Route, 0)
+	lastPrefix := ""
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && len(routes) > 0 && net.ParseIP(fields[0]) != nil && net.ParseIP(fields[0]).To4() != nil {
+			//h3c的等价路由
+			last := &routes[len(routes)-1]
+			last.NextHops = append(last.NextHops, NextHop{Address: fields[0], Interface: NormalizeInterfaceName(brand, fields[len(fields)-1])})
+			continue
+		}
+		prefix := ""
+		if len(fields) > 0 {
+			prefix = normalizePrefix(fields[0])
+		}
+		if prefix != "" {
+			fields = fields[1:]
+		} else if lastPrefix != "" {
+			//华为的等价路由或同一前缀的其他协议路由
+			prefix = lastPrefix
+		}
+		if prefix == "" || len(fields) < 4 || !isDigits(fields[1]) || !isDigits(fields[2]) {
+			continue
+		}
+		protocol := strings.ToLower(fields[0])
+		if full, ok := huaweiRouteProtocols[protocol]; ok {
+			protocol = full
+		}
+		preference, _ := strconv.Atoi(fields[1])
+		metric, _ := strconv.ParseInt(fields[2], 10, 64)
+		nextHop := NextHop{}
+		for _, field := range fields[3:] {
+			if ip := net.ParseIP(field); ip != nil && nextHop.Address == "" {
+				nextHop.Address = field
+			}
+		}
+		if last := fields[len(fields)-1]; net.ParseIP(last) == nil {
+			nextHop.Interface = NormalizeInterfaceName(brand, last)
+		}
+		if len(routes) > 0 && prefix == lastPrefix {
+			last := &routes[len(routes)-1]
+			if last.Protocol == protocol && last.Preference == preference && last.Metric == metric {
+				last.NextHops = append(last.NextHops, nextHop)
+				continue
+			}
+		}
+		routes = append(routes, Route{VRF: vrf, Prefix: prefix, Protocol: protocol, Preference: preference,
+			Metric: metric, NextHops: []NextHop{nextHop}})
+		lastPrefix = prefix
+	}
+	for i := range routes {
+		routes[i] = completeRoute(routes[i])
+	}
+	return routes
+}
+
+var (
+	ciscoRouteRegexp     = regexp.MustCompile(`^([A-Za-z][*+%]?(?:\s?[A-Z][A-Z0-9]?)?[*+]?)\s+(\d+\.\d+\.\d+\.\d+)(/\d+)?(.*)$`)
+	ciscoSubnettedRegexp = regexp.MustCompile(`^\s+\d+\.\d+\.\d+\.\d+/(\d+) is subnetted`)
+	ciscoViaRegexp       = regexp.MustCompile(`\[(\d+)/(\d+)\]\s+via\s+(\d+\.\d+\.\d+\.\d+)`)
+	ciscoDistanceRegexp  = regexp.MustCompile(`\[(\d+)/(\d+)\]`)
+	ciscoAgeRegexp       = regexp.MustCompile(`^(?:\d+:\d{2}:\d{2}|(?:\d+[ywdhms])+)$`)
+	nxosRouteRegexp      = regexp.MustCompile(`^(\d+\.\d+\.\d+\.\d+/\d+), ubest/mbest`)
+	nxosViaRegexp        = regexp.MustCompile(`^\*via (\d+\.\d+\.\d+\.\d+)(?:%\S+)?,(?: ([^,\[]+),)? \[(\d+)/(\d+)\], [^,]+, ([^,]+)`)
+)
+
+/**
+ * 获取有类网络的掩码长度
+ * @param address 网络地址
+ * @return A类为8，B类为16，C类为24
+ * @author shenbowei
+ */
+func classfulLength(address string) int {
+	ip := net.ParseIP(address).To4()
+	switch {
+	case ip == nil || ip[0] < 128:
+		return 8
+	case ip[0] < 192:
+		return 16
+	}
+	return 24
+}
+
+/**
+ * 解析cisco路由的"[管理距离/开销] via 下一跳, 时间, 出接口"或"is directly connected, 出接口"部分，添加到路由中
+ * @param route 路由, text 路由中前缀之后的部分
+ * @author shenbowei
+ */
+func addCiscoNextHop(route *Route, text string) {
+	nextHop := NextHop{}
+	if match := ciscoViaRegexp.FindStringSubmatch(text); match != nil {
+		nextHop.Address = match[3]
+	}
+	if match := ciscoDistanceRegexp.FindStringSubmatch(text); match != nil {
+		route.Preference, _ = strconv.Atoi(match[1])
+		route.Metric, _ = strconv.ParseInt(match[2], 10, 64)
+	}
+	parts := strings.Split(text, ",")
+	if last := strings.TrimSpace(parts[len(parts)-1]); len(parts) > 1 && !ciscoAgeRegexp.MatchString(last) && isInterfaceField(last) {
+		nextHop.Interface = NormalizeInterfaceName(CISCO, last)
+	}
+	if nextHop.Address != "" || nextHop.Interface != "" {
+		route.NextHops = append(route.NextHops, nextHop)
+	}
+}
+
+/**
+ * 解析cisco的路由表，支持IOS/IOS-XE（"O IA 10.4.0.0/16 [110/20] via 10.3.1.2, 2d01h, Vlan10"，
+ * 等价路由和过长的行在下一行以"[110/20] via"继续）和NX-OS（"10.6.0.0/16, ubest/mbest: 2/0"之后为"*via"行）
+ * @param vrf VRF, output show ip route的输出
+ * @return 路由表项
+ * @author shenbowei
+ */
+func parseCiscoRoutes(vrf, output string) []Route {
+	routes := make([]Route, 0)
+	subnettedLength := 0
+	for _, line := range outputLines(output) {
+		trimmed := strings.TrimSpace(line)
+		if match := nxosRouteRegexp.FindStringSubmatch(trimmed); match != nil {
+			routes = append(routes, Route{VRF: vrf, Prefix: normalizePrefix(match[1]), NextHops: make([]NextHop, 0)})
+			continue
+		}
+		if match := nxosViaRegexp.FindStringSubmatch(trimmed); match != nil && len(routes) > 0 {
+			last := &routes[len(routes)-1]
+			protocol := strings.ToLower(strings.SplitN(strings.TrimSpace(match[5]), "-", 2)[0])
+			if full, ok := nxosRouteProtocols[protocol]; ok {
+				protocol = full
+			}
+			last.Protocol = protocol
+			last.Preference, _ = strconv.Atoi(match[3])
+			last.Metric, _ = strconv.ParseInt(match[4], 10, 64)
+			nextHop := NextHop{Address: match[1]}
+			if name := strings.TrimSpace(match[2]); name != "" {
+				nextHop.Interface = NormalizeInterfaceName(CISCO, name)
+			}
+			last.NextHops = append(last.NextHops, nextHop)
+			continue
+		}
+		if match := ciscoSubnettedRegexp.FindStringSubmatch(line); match != nil {
+			subnettedLength, _ = strconv.Atoi(match[1])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") && len(routes) > 0 {
+			addCiscoNextHop(&routes[len(routes)-1], trimmed)
+			continue
+		}
+		match := ciscoRouteRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		length := match[3]
+		if length == "" && subnettedLength > 0 {
+			//有类网络的子网不显示掩码，使用"is subnetted"行中的掩码
+			length = "/" + strconv.Itoa(subnettedLength)
+		} else if length == "" {
+			length = "/" + strconv.Itoa(classfulLength(match[2]))
+		}
+		code := match[1][:1]
+		protocol, ok := ciscoRouteProtocols[code]
+		if !ok {
+			protocol = strings.ToLower(code)
+		}
+		route := Route{VRF: vrf, Prefix: normalizePrefix(match[2] + length), Protocol: protocol, NextHops: make([]NextHop, 0)}
+		addCiscoNextHop(&route, match[4])
+		routes = append(routes, route)
+	}
+	for i := range routes {
+		routes[i] = completeRoute(routes[i])
+	}
+	return routes
+}
+
+/**
+ * 按最长前缀匹配查找目的地址的路由，前缀长度相同时选择优先级（管理距离）和开销最小的路由
+ * @param routes 路由表, destination 目的IPv4地址
+ * @return 匹配的路由，是否找到
+ * @author shenbowei
+ */
+func LookupRoute(routes []Route, destination string) (Route, bool) {
+	ip := net.ParseIP(destination)
+	best, bestLength, found := Route{}, -1, false
+	if ip == nil {
+		return best, false
+	}
+	for _, route := range routes {
+		_, network, err := net.ParseCIDR(route.Prefix)
+		if err != nil || !network.Contains(ip) {
+			continue
+		}
+		length, _ := network.Mask.Size()
+		if length > bestLength || (length == bestLength &&
+			(route.Preference < best.Preference || (route.Preference == best.Preference && route.Metric < best.Metric))) {
+			best, bestLength, found = route, length, true
+		}
+	}
+	return best, found
+}
+
+/**
+ * 在路由表中递归解析下一跳，直到找到下一跳的出接口（如BGP路由的下一跳需要经过IGP路由到达）
+ * @param routes 路由表, nextHop 路由的下一跳
+ * @return 直连的下一跳
+ * @author shenbowei
+ */
+func resolveNextHop(routes []Route, nextHop NextHop) NextHop {
+	for i := 0; i < defaultTraceMaxHops && nextHop.Interface == "" && nextHop.Address != ""; i++ {
+		route, ok := LookupRoute(routes, nextHop.Address)
+		if !ok || len(route.NextHops) == 0 {
+			break
+		}
+		if route.Protocol == ROUTE_CONNECTED || route.Protocol == ROUTE_LOCAL {
+			nextHop.Interface = route.NextHops[0].Interface
+			break
+		}
+		nextHop = route.NextHops[0]
+	}
+	return nextHop
+}
+
+/**
+ * 判断路由表中是否有地址对应的本地路由，即地址是否为设备自身的接口地址
+ * @param routes 路由表, address IPv4地址
+ * @return true:地址属于该设备
+ * @author shenbowei
+ */
+func ownsAddress(routes []Route, address string) bool {
+	for _, route := range routes {
+		if route.Protocol == ROUTE_LOCAL && route.Prefix == address+"/32" {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 逐跳追踪中的一跳
+ * @attr Device:该跳的设备，Route:最长前缀匹配的路由，NextHop:选择的下一跳（等价路由时为第一个，已递归解析出接口），
+ *       Delivered:目的地址是否在该设备直连（或为该设备自身的地址）
+ * @author shenbowei
+ */
+type TraceHop struct {
+	Device    Device
+	Route     Route
+	NextHop   NextHop
+	Delivered bool
+}
+
+/**
+ * 在一组设备中逐跳追踪目的地址的路由：在当前设备上按最长前缀匹配查找路由，再到拥有下一跳地址的设备上继续查找
+ * @attr Manager:执行指令的SessionManager（为nil时使用DefaultSessionManager），VRF:VPN实例/VRF（为""时使用公网路由表），
+ *       MaxHops:最多追踪的跳数（为0时为16）
+ * @author shenbowei
+ */
+type RouteTracer struct {
+	Manager *SessionManager
+	VRF     string
+	MaxHops int
+}
+
+/**
+ * 一台设备的路由表
+ * @author shenbowei
+ */
+type deviceRoutes struct {
+	routes []Route
+	err    error
+}
+
+/**
+ * 逐跳追踪目的地址：并发获取各设备的路由表，从第一台设备开始查找路由，下一跳地址属于devices中的另一台设备时继续追踪，
+ * 直到目的地址直连、下一跳不属于任何设备或者出现错误
+ * @param devices 路径上可能经过的设备（第一台为起点）, destination 目的IPv4地址
+ * @return 经过的各跳，追踪的错误（没有路由时返回ErrNoRoute，出现环路时返回ErrRoutingLoop，已经返回的各跳仍然有效）
+ * @author shenbowei
+ */
+func (this *RouteTracer) Trace(devices []Device, destination string) ([]TraceHop, error) {
+	if ip := net.ParseIP(destination); ip == nil || ip.To4() == nil {
+		return nil, ErrInvalidRoute
+	}
+	manager := this.Manager
+	if manager == nil {
+		manager = DefaultSessionManager
+	}
+	maxHops := this.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultTraceMaxHops
+	}
+	tables := make([]deviceRoutes, len(devices))
+	var waitGroup sync.WaitGroup
+	for i := range devices {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			tables[i].routes, tables[i].err = manager.GetRouteTable(devices[i], this.VRF)
+		}(i)
+	}
+	waitGroup.Wait()
+
+	hops := make([]TraceHop, 0)
+	visited := make(map[int]bool)
+	for current := 0; current >= 0 && current < len(devices); {
+		if len(hops) >= maxHops {
+			return hops, ErrTooManyHops
+		}
+		if visited[current] {
+			return hops, ErrRoutingLoop
+		}
+		visited[current] = true
+		if tables[current].err != nil {
+			return hops, tables[current].err
+		}
+		route, ok := LookupRoute(tables[current].routes, destination)
+		if !ok {
+			return hops, ErrNoRoute
+		}
+		hop := TraceHop{Device: devices[current], Route: route}
+		if route.Protocol == ROUTE_CONNECTED || route.Protocol == ROUTE_LOCAL {
+			hop.Delivered = true
+			if len(route.NextHops) > 0 {
+				hop.NextHop = route.NextHops[0]
+			}
+			return append(hops, hop), nil
+		}
+		if len(route.NextHops) == 0 {
+			return append(hops, hop), ErrNoRoute
+		}
+		hop.NextHop = resolveNextHop(tables[current].routes, route.NextHops[0])
+		hops = append(hops, hop)
+		next := -1
+		for i := range devices {
+			if tables[i].err == nil && ownsAddress(tables[i].routes, hop.NextHop.Address) {
+				next = i
+				break
+			}
+		}
+		current = next
+	}
+	return hops, nil
+}
@@ -0,0 +1,204 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const huaweiRouteSample = `Route Flags: R - relay, D - download to fib
+------------------------------------------------------------------------------
+Routing Tables: Public
+         Destinations : 8        Routes : 9
+
+Destination/Mask    Proto   Pre  Cost      Flags NextHop         Interface
+
+        0.0.0.0/0   Static  60   0          RD   10.3.1.1        Vlanif10
+       10.3.1.0/24  Direct  0    0           D   10.3.1.2        Vlanif10
+       10.3.1.2/32  Direct  0    0           D   127.0.0.1       Vlanif10
+       10.9.1.0/24  Direct  0    0           D   10.9.1.1        Vlanif90
+       10.9.1.1/32  Direct  0    0           D   127.0.0.1       Vlanif90
+      10.20.0.0/16  OSPF    10   2           D   10.9.1.2        Vlanif90
+                    OSPF    10   2           D   10.9.1.3        Vlanif91
+     172.16.0.0/16  IBGP    255  0          RD   10.20.0.1       Vlanif90
+      127.0.0.0/8   Direct  0    0           D   127.0.0.1       InLoopBack0
+`
+
+const h3cRouteSample = `Destinations : 13        Routes : 14
+
+Destination/Mask   Proto   Pre Cost        NextHop         Interface
+0.0.0.0/0          Static  60  0           10.2.1.254      Vlan10
+10.2.1.0/24        Direct  0   0           10.2.1.1        Vlan10
+10.2.1.1/32        Direct  0   0           127.0.0.1       InLoop0
+10.3.0.0/16        O_INTRA 10  2           10.2.1.2        Vlan10
+                                           10.2.1.3        Vlan20
+`
+
+const ciscoRouteSample = `Codes: L - local, C - connected, S - static, R - RIP, M - mobile, B - BGP
+       D - EIGRP, EX - EIGRP external, O - OSPF, IA - OSPF inter area
+       i - IS-IS, su - IS-IS summary, L1 - IS-IS level-1, L2 - IS-IS level-2
+
+Gateway of last resort is 10.3.1.254 to network 0.0.0.0
+
+S*    0.0.0.0/0 [1/0] via 10.3.1.254
+      10.0.0.0/8 is variably subnetted, 5 subnets, 3 masks
+C        10.3.1.0/24 is directly connected, Vlan10
+L        10.3.1.1/32 is directly connected, Vlan10
+O IA     10.9.0.0/16 [110/20] via 10.3.1.2, 2d01h, Vlan10
+                     [110/20] via 10.3.1.3, 2d01h, Vlan10
+B        172.16.0.0/16 [200/0] via 10.9.1.1, 1w2d
+      192.168.10.0/24 is subnetted, 1 subnets
+O E2     192.168.10.0 [110/20] via 10.3.1.2, 00:10:11, Vlan10
+D EX  192.168.20.0/24
+           [170/2816] via 10.3.1.5, 00:01:02, Vlan10
+`
+
+const nxosRouteSample = `IP Route Table for VRF "default"
+'*' denotes best ucast next-hop
+'**' denotes best mcast next-hop
+'[x/y]' denotes [preference/metric]
+
+0.0.0.0/0, ubest/mbest: 1/0
+    *via 10.5.1.254, [1/0], 3w0d, static
+10.5.1.0/24, ubest/mbest: 1/0, attached
+    *via 10.5.1.1, Vlan10, [0/0], 3w0d, direct
+10.5.1.1/32, ubest/mbest: 1/0, attached
+    *via 10.5.1.1, Vlan10, [0/0], 3w0d, local
+10.6.0.0/16, ubest/mbest: 2/0
+    *via 10.5.1.2, Eth1/1, [110/41], 3w0d, ospf-1, intra
+    *via 10.5.1.3, Eth1/2, [110/41], 3w0d, ospf-1, intra
+`
+
+func TestParseHuaweiRoutes(t *testing.T) {
+	routes := parseHuaweiRoutes(HUAWEI, "", huaweiRouteSample)
+	if len(routes) != 8 {
+		t.Fatalf("huawei routes:%+v", routes)
+	}
+	if !reflect.DeepEqual(routes[0], Route{Prefix: "0.0.0.0/0", Protocol: ROUTE_STATIC, Preference: 60,
+		NextHops: []NextHop{{Address: "10.3.1.1", Interface: "Vlanif10"}}}) {
+		t.Errorf("huawei route 0:%+v", routes[0])
+	}
+	if routes[1].Protocol != ROUTE_CONNECTED || !reflect.DeepEqual(routes[1].NextHops, []NextHop{{Interface: "Vlanif10"}}) {
+		t.Errorf("huawei route 1:%+v", routes[1])
+	}
+	if routes[2].Protocol != ROUTE_LOCAL || routes[2].Prefix != "10.3.1.2/32" {
+		t.Errorf("huawei route 2:%+v", routes[2])
+	}
+	if !reflect.DeepEqual(routes[5], Route{Prefix: "10.20.0.0/16", Protocol: ROUTE_OSPF, Preference: 10, Metric: 2,
+		NextHops: []NextHop{{Address: "10.9.1.2", Interface: "Vlanif90"}, {Address: "10.9.1.3", Interface: "Vlanif91"}}}) {
+		t.Errorf("huawei route 5:%+v", routes[5])
+	}
+	if routes[6].Protocol != ROUTE_BGP || routes[6].Preference != 255 {
+		t.Errorf("huawei route 6:%+v", routes[6])
+	}
+
+	routes = parseHuaweiRoutes(H3C, "vpn1", h3cRouteSample)
+	if len(routes) != 4 {
+		t.Fatalf("h3c routes:%+v", routes)
+	}
+	if !reflect.DeepEqual(routes[3], Route{VRF: "vpn1", Prefix: "10.3.0.0/16", Protocol: ROUTE_OSPF, Preference: 10, Metric: 2,
+		NextHops: []NextHop{{Address: "10.2.1.2", Interface: "Vlan-interface10"}, {Address: "10.2.1.3", Interface: "Vlan-interface20"}}}) {
+		t.Errorf("h3c route 3:%+v", routes[3])
+	}
+	if routes[2].Protocol != ROUTE_LOCAL {
+		t.Errorf("h3c route 2:%+v", routes[2])
+	}
+}
+
+func TestParseCiscoRoutes(t *testing.T) {
+	routes := parseCiscoRoutes("", ciscoRouteSample)
+	if len(routes) != 7 {
+		t.Fatalf("cisco routes:%+v", routes)
+	}
+	if !reflect.DeepEqual(routes[0], Route{Prefix: "0.0.0.0/0", Protocol: ROUTE_STATIC, Preference: 1,
+		NextHops: []NextHop{{Address: "10.3.1.254"}}}) {
+		t.Errorf("cisco route 0:%+v", routes[0])
+	}
+	if routes[1].Protocol != ROUTE_CONNECTED || !reflect.DeepEqual(routes[1].NextHops, []NextHop{{Interface: "Vlan10"}}) {
+		t.Errorf("cisco route 1:%+v", routes[1])
+	}
+	if routes[2].Protocol != ROUTE_LOCAL || routes[2].Prefix != "10.3.1.1/32" {
+		t.Errorf("cisco route 2:%+v", routes[2])
+	}
+	if !reflect.DeepEqual(routes[3], Route{Prefix: "10.9.0.0/16", Protocol: ROUTE_OSPF, Preference: 110, Metric: 20,
+		NextHops: []NextHop{{Address: "10.3.1.2", Interface: "Vlan10"}, {Address: "10.3.1.3", Interface: "Vlan10"}}}) {
+		t.Errorf("cisco route 3:%+v", routes[3])
+	}
+	if routes[4].Protocol != ROUTE_BGP || !reflect.DeepEqual(routes[4].NextHops, []NextHop{{Address: "10.9.1.1"}}) {
+		t.Errorf("cisco route 4:%+v", routes[4])
+	}
+	if routes[5].Prefix != "192.168.10.0/24" {
+		t.Errorf("cisco route 5:%+v", routes[5])
+	}
+	if !reflect.DeepEqual(routes[6], Route{Prefix: "192.168.20.0/24", Protocol: ROUTE_EIGRP, Preference: 170, Metric: 2816,
+		NextHops: []NextHop{{Address: "10.3.1.5", Interface: "Vlan10"}}}) {
+		t.Errorf("cisco route 6:%+v", routes[6])
+	}
+
+	routes = parseCiscoRoutes("", nxosRouteSample)
+	if len(routes) != 4 {
+		t.Fatalf("nxos routes:%+v", routes)
+	}
+	if routes[1].Protocol != ROUTE_CONNECTED || routes[2].Protocol != ROUTE_LOCAL || routes[0].Protocol != ROUTE_STATIC {
+		t.Errorf("nxos routes:%+v", routes)
+	}
+	if !reflect.DeepEqual(routes[3], Route{Prefix: "10.6.0.0/16", Protocol: ROUTE_OSPF, Preference: 110, Metric: 41,
+		NextHops: []NextHop{{Address: "10.5.1.2", Interface: "Ethernet1/1"}, {Address: "10.5.1.3", Interface: "Ethernet1/2"}}}) {
+		t.Errorf("nxos route 3:%+v", routes[3])
+	}
+}
+
+func TestLookupRoute(t *testing.T) {
+	routes := parseCiscoRoutes("", ciscoRouteSample)
+	cases := map[string]string{
+		"10.9.1.50":    "10.9.0.0/16",
+		"10.3.1.1":     "10.3.1.1/32",
+		"10.3.1.9":     "10.3.1.0/24",
+		"8.8.8.8":      "0.0.0.0/0",
+		"192.168.10.7": "192.168.10.0/24",
+	}
+	for destination, prefix := range cases {
+		if route, ok := LookupRoute(routes, destination); !ok || route.Prefix != prefix {
+			t.Errorf("LookupRoute(%s) = %+v, expected %s", destination, route, prefix)
+		}
+	}
+	//前缀长度相同时选择优先级最小的路由
+	routes = append(routes, Route{Prefix: "10.9.0.0/16", Protocol: ROUTE_STATIC, Preference: 1, NextHops: []NextHop{{Address: "10.3.1.9"}}})
+	if route, _ := LookupRoute(routes, "10.9.1.50"); route.Protocol != ROUTE_STATIC {
+		t.Errorf("unexpected route:%+v", route)
+	}
+	//BGP路由的下一跳递归到OSPF路由的出接口
+	nextHop := resolveNextHop(parseCiscoRoutes("", ciscoRouteSample), NextHop{Address: "10.9.1.1"})
+	if nextHop != (NextHop{Address: "10.3.1.2", Interface: "Vlan10"}) {
+		t.Errorf("unexpected resolved next hop:%+v", nextHop)
+	}
+}
+
+func TestRouteTracerTrace(t *testing.T) {
+	r1 := newFakeSwitch(t, "R1#", map[string]string{CiscoRouteCmd: ciscoRouteSample})
+	r2 := newFakeSwitch(t, "<R2>", map[string]string{HuaweiRouteCmd: huaweiRouteSample})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	devices := []Device{NewDevice("admin", "admin", r1.addr(), CISCO), NewDevice("admin", "admin", r2.addr(), HUAWEI)}
+	tracer := &RouteTracer{Manager: manager}
+
+	//R1经OSPF路由到R2（10.3.1.2），R2上目的地址直连
+	hops, err := tracer.Trace(devices, "10.9.1.50")
+	if err != nil {
+		t.Fatalf("Trace err:%s", err)
+	}
+	if len(hops) != 2 || hops[0].Device.Key() != devices[0].Key() || hops[0].NextHop != (NextHop{Address: "10.3.1.2", Interface: "Vlan10"}) ||
+		hops[1].Device.Key() != devices[1].Key() || !hops[1].Delivered || hops[1].NextHop.Interface != "Vlanif90" {
+		t.Errorf("unexpected hops:%+v", hops)
+	}
+
+	//默认路由的下一跳不属于任何设备，追踪结束
+	hops, err = tracer.Trace(devices, "8.8.8.8")
+	if err != nil || len(hops) != 1 || hops[0].Delivered || hops[0].NextHop.Address != "10.3.1.254" {
+		t.Errorf("unexpected hops:%+v err:%v", hops, err)
+	}
+
+	if _, err := tracer.Trace(devices, "not-an-ip"); err != ErrInvalidRoute {
+		t.Errorf("expected ErrInvalidRoute, got %v", err)
+	}
+}
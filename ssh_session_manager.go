@@ -1,6 +1,8 @@
 package ssh
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -11,56 +13,281 @@ var (
 	CiscoNoPage  = "terminal length 0"
 )
 
-var sessionManager = NewSessionManager()
+var (
+	ErrMaxSessions     = errors.New("ssh: the number of cached devices has reached the limit")
+	ErrManagerShutdown = errors.New("ssh: session manager is shut down")
+)
+
+// 包级别的RunCommands等方法使用的默认SessionManager
+var DefaultSessionManager = NewSessionManager()
 
 /**
- * session（SSHSession）的管理类，会统一缓存打开的session，自动处理未使用超过10分钟的session
- * @attr sessionCache:缓存所有打开的map（10分钟内使用过的），sessionLocker设备锁，globalLocker全局锁
+ * session（SSHSession/TelnetSession）的管理类，为每个设备缓存一个连接池（一个ssh连接上的多个shell），自动关闭未使用超过idleTimeout（默认10分钟）的shell
+ * @attr config:配置，poolCache:缓存所有设备的连接池，sessionLocker设备锁，sessionCacheLocker缓存锁，
+ *       sessionLockerMapLocker设备锁map的锁，dialCalls:正在进行的连接（同一设备只会有一个），dialCallsLocker:dialCalls的锁，
+ *       jumps:跳板机连接的缓存（所有经过同一跳板机的设备共享一个连接），
+ *       isShutdown:是否已经关闭（受sessionCacheLocker保护），stopClean:通知自动清理协程退出，poolWaitGroup:等待正在关闭的连接池
  * @author shenbowei
  */
 type SessionManager struct {
-	sessionCache           map[string]*SSHSession
+	config                 *sessionConfig
+	poolCache              map[string]*devicePool
 	sessionLocker          map[string]*sync.Mutex
 	sessionCacheLocker     *sync.RWMutex
 	sessionLockerMapLocker *sync.RWMutex
+	dialCalls              map[string]*dialCall
+	dialCallsLocker        sync.Mutex
+	jumps                  *jumpCache
+	isShutdown             bool
+	stopClean              chan struct{}
+	stopCleanOnce          sync.Once
+	cleanWaitGroup         sync.WaitGroup
+	poolWaitGroup          sync.WaitGroup
+}
+
+/**
+ * 正在进行的一次连接，并发获取同一设备session的调用者会等待同一次连接的结果
+ * @attr done:连接完成后关闭，err:连接的错误
+ * @author shenbowei
+ */
+type dialCall struct {
+	done chan struct{}
+	err  error
 }
 
 /**
  * 创建一个SessionManager，相当于SessionManager的构造函数
+ * @param opts 配置项（WithIdleTimeout、WithDialTimeout等），不传则使用默认配置
  * @return SessionManager实例
  * @author shenbowei
  */
-func NewSessionManager() *SessionManager {
+func NewSessionManager(opts ...Option) *SessionManager {
 	sessionManager := new(SessionManager)
-	sessionManager.sessionCache = make(map[string]*SSHSession, 0)
+	sessionManager.config = newSessionConfig()
+	for _, opt := range opts {
+		opt(sessionManager.config)
+	}
+	sessionManager.poolCache = make(map[string]*devicePool, 0)
 	sessionManager.sessionLocker = make(map[string]*sync.Mutex, 0)
 	sessionManager.sessionCacheLocker = new(sync.RWMutex)
 	sessionManager.sessionLockerMapLocker = new(sync.RWMutex)
-	//启动自动清理的线程，清理10分钟未使用的session缓存
+	sessionManager.dialCalls = make(map[string]*dialCall)
+	sessionManager.jumps = newJumpCache(sessionManager.config)
+	sessionManager.stopClean = make(chan struct{})
+	//启动自动清理的线程，清理idleTimeout未使用的session
 	sessionManager.RunAutoClean()
 	return sessionManager
 }
 
-func (this *SessionManager) SetSessionCache(sessionKey string, session *SSHSession) {
-	this.sessionCacheLocker.Lock()
-	defer this.sessionCacheLocker.Unlock()
-	this.sessionCache[sessionKey] = session
+/**
+ * 完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），执行指令的流程，返回执行结果
+ * @param user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口, cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) RunCommands(user, password, ipPort string, cmds ...string) (string, error) {
+	return this.RunDeviceCommands(NewDevice(user, password, ipPort, ""), cmds...)
+}
+
+/**
+ * 完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），执行指令的流程，返回执行结果
+ * @param user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口, brand 交换机品牌（可为空）， cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) RunCommandsWithBrand(user, password, ipPort, brand string, cmds ...string) (string, error) {
+	return this.RunDeviceCommands(NewDevice(user, password, ipPort, brand), cmds...)
+}
+
+/**
+ * 完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），提升权限，执行指令的流程，返回执行结果
+ * @param user ssh连接的用户名, password 密码, enablePassword 提权密码（可为空）, ipPort 交换机的ip和端口, brand 交换机品牌（可为空）， cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) RunCommandsWithEnable(user, password, enablePassword, ipPort, brand string, cmds ...string) (string, error) {
+	device := NewDevice(user, password, ipPort, brand)
+	device.Credential.EnablePassword = enablePassword
+	return this.RunDeviceCommands(device, cmds...)
+}
+
+/**
+ * 完成获取交换机的型号
+ * @param user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口
+ * @return 设备品牌（huawei，h3c，cisco，""）和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetSSHBrand(user, password, ipPort string) (string, error) {
+	return this.GetDeviceBrand(NewDevice(user, password, ipPort, ""))
+}
+
+/**
+ * 完成获取会话（若不存在，则会创建连接和会话，并存放入缓存），按需提升权限，执行指令的流程，返回执行结果
+ * @param device 设备的身份信息, cmds 执行的指令(可以多个)
+ * @return 执行的输出结果和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) RunDeviceCommands(device Device, cmds ...string) (string, error) {
+	mode, err := this.resolveExecMode(device)
+	if err != nil {
+		return "", err
+	}
+	if mode == EXEC_MODE {
+		results, err := this.RunDeviceExec(device, cmds...)
+		if err != nil {
+			return "", err
+		}
+		return joinExecResults(results), nil
+	}
+	sshSession, err := this.CheckoutSession(context.Background(), device)
+	if err != nil {
+		this.config.logger.Error("GetSession<%s> error:%s", device, err)
+		return "", err
+	}
+	defer this.ReleaseSession(sshSession)
+	sshSession.WriteChannel(cmds...)
+	result := sshSession.ReadChannelTiming(this.config.commandTimeout)
+	filteredResult := filterResult(result, cmds[0])
+	return filteredResult, nil
+}
+
+/**
+ * 完成获取交换机的型号
+ * @param device 设备的身份信息（Brand不为空时直接返回）
+ * @return 设备品牌（huawei，h3c，cisco，""）和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetDeviceBrand(device Device) (string, error) {
+	sshSession, err := this.CheckoutSession(context.Background(), device)
+	if err != nil {
+		this.config.logger.Error("GetSession<%s> error:%s", device, err)
+		return "", err
+	}
+	defer this.ReleaseSession(sshSession)
+	return sshSession.GetSSHBrand(), nil
+}
+
+/**
+ * 识别设备的品牌并返回识别的依据和可信度，优先根据ssh服务端版本号、banner和提示符识别，可信度不足时才执行版本指令
+ * @param device 设备的身份信息（Brand不为空时直接返回，Source为BRAND_SOURCE_DEVICE）
+ * @return 识别结果和执行错误
+ * @author shenbowei
+ */
+func (this *SessionManager) DetectDeviceBrand(device Device) (BrandDetection, error) {
+	sshSession, err := this.CheckoutSession(context.Background(), device)
+	if err != nil {
+		this.config.logger.Error("GetSession<%s> error:%s", device, err)
+		return BrandDetection{}, err
+	}
+	defer this.ReleaseSession(sshSession)
+	return sshSession.DetectBrand(), nil
+}
+
+/**
+ * 从设备的连接池中获取一个可用的session（shell），并按需提升权限。连接池不存在或连接不可用时重新连接，
+ * 设备的shell都在使用时按先后顺序排队等待。使用完后必须调用ReleaseSession归还
+ * @param  ctx 排队等待的上下文, device 设备的身份信息
+ * @return Session（SSHSession或TelnetSession），执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) CheckoutSession(ctx context.Context, device Device) (Session, error) {
+	var lastErr error
+	//连接池在获取期间被关闭或者连接不可用时，重新获取一次连接池
+	for i := 0; i < 2; i++ {
+		pool, err := this.getPool(device)
+		if err != nil {
+			return nil, err
+		}
+		session, err := pool.checkout(ctx)
+		if err == nil {
+			if err := enableSession(session, session.GetSSHBrand(), device.enablePassword()); err != nil {
+				this.config.logger.Error("SSH session pool enableSession err:%s", err.Error())
+				pool.release(session)
+				return nil, err
+			}
+			return session, nil
+		}
+		if err != errPoolClosed && !pool.isBroken() {
+			return nil, err
+		}
+		this.config.logger.Debug("Device pool<%s> is unavailable:%s", device, err)
+		this.removePool(device.Key(), pool)
+		lastErr = err
+	}
+	return nil, lastErr
 }
 
-func (this *SessionManager) GetSessionCache(sessionKey string) *SSHSession {
+/**
+ * 将CheckoutSession获取的session归还给所属的连接池
+ * @param  session 需要归还的session
+ * @author shenbowei
+ */
+func (this *SessionManager) ReleaseSession(session Session) {
+	if session == nil || session.shell().pool == nil {
+		return
+	}
+	session.shell().pool.release(session)
+}
+
+/**
+ * 获取设备连接池的统计信息
+ * @param  device 设备的身份信息
+ * @return 统计信息，连接池是否存在
+ * @author shenbowei
+ */
+func (this *SessionManager) GetPoolStats(device Device) (PoolStats, bool) {
+	pool := this.getPoolCache(device.Key())
+	if pool == nil {
+		return PoolStats{}, false
+	}
+	return pool.getStats(), true
+}
+
+/**
+ * 判断SessionManager是否已经关闭
+ * @return true:已关闭
+ * @author shenbowei
+ */
+func (this *SessionManager) IsShutdown() bool {
 	this.sessionCacheLocker.RLock()
 	defer this.sessionCacheLocker.RUnlock()
-	cache, ok := this.sessionCache[sessionKey]
-	if ok {
-		return cache
-	} else {
-		return nil
+	return this.isShutdown
+}
+
+/**
+ * 从缓存中获取设备的连接池
+ * @param  sessionKey:设备的索引键值
+ * @return 连接池，不存在时返回nil
+ * @author shenbowei
+ */
+func (this *SessionManager) getPoolCache(sessionKey string) *devicePool {
+	this.sessionCacheLocker.RLock()
+	defer this.sessionCacheLocker.RUnlock()
+	return this.poolCache[sessionKey]
+}
+
+/**
+ * 将连接池添加到缓存，SessionManager已经关闭或者缓存的设备数量达到上限时不会添加
+ * @param  sessionKey:设备的索引键值, pool:需要缓存的连接池
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) addPoolCache(sessionKey string, pool *devicePool) error {
+	this.sessionCacheLocker.Lock()
+	defer this.sessionCacheLocker.Unlock()
+	if this.isShutdown {
+		return ErrManagerShutdown
 	}
+	if _, ok := this.poolCache[sessionKey]; !ok && this.config.maxSessions > 0 && len(this.poolCache) >= this.config.maxSessions {
+		return ErrMaxSessions
+	}
+	this.poolCache[sessionKey] = pool
+	return nil
 }
 
 /**
- * 给指定的session上锁
- * @param  sessionKey:session的索引键值
+ * 给指定的设备上锁，session由连接池分配后不再需要加锁，调用者需要对同一设备的多步操作互斥时可以使用
+ * @param  sessionKey:设备的索引键值（Device.Key()）
  * @author shenbowei
  */
 func (this *SessionManager) LockSession(sessionKey string) {
@@ -68,18 +295,21 @@ func (this *SessionManager) LockSession(sessionKey string) {
 	mutex, ok := this.sessionLocker[sessionKey]
 	this.sessionLockerMapLocker.RUnlock()
 	if !ok {
-		//如果获取不到锁，需要创建锁，主要更新锁存的时候需要上全局锁
-		mutex = new(sync.Mutex)
+		//如果获取不到锁，需要创建锁，更新锁存的时候需要上全局锁，并再次检查，避免并发时创建出不同的锁
 		this.sessionLockerMapLocker.Lock()
-		this.sessionLocker[sessionKey] = mutex
+		mutex, ok = this.sessionLocker[sessionKey]
+		if !ok {
+			mutex = new(sync.Mutex)
+			this.sessionLocker[sessionKey] = mutex
+		}
 		this.sessionLockerMapLocker.Unlock()
 	}
 	mutex.Lock()
 }
 
 /**
- * 给指定的session解锁
- * @param  sessionKey:session的索引键值
+ * 给指定的设备解锁
+ * @param  sessionKey:设备的索引键值（Device.Key()）
  * @author shenbowei
  */
 func (this *SessionManager) UnlockSession(sessionKey string) {
@@ -89,119 +319,234 @@ func (this *SessionManager) UnlockSession(sessionKey string) {
 }
 
 /**
- * 更新session缓存中的session，连接设备，打开会话，初始化会话（等待登录，识别设备类型，执行禁止分页），添加到缓存
- * @param  user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口
- * @return 执行的错误
+ * 获取设备的连接池，不存在或者连接不可用时重新连接（并发的调用只会连接一次）
+ * @param  device 设备的身份信息
+ * @return 连接池，执行的错误
  * @author shenbowei
  */
-func (this *SessionManager) updateSession(user, password, ipPort, brand string) error {
-	sessionKey := user + "_" + password + "_" + ipPort
-	mySession, err := NewSSHSession(user, password, ipPort)
-	if err != nil {
-		LogError("NewSSHSession err:%s", err.Error())
-		return err
+func (this *SessionManager) getPool(device Device) (*devicePool, error) {
+	if this.IsShutdown() {
+		return nil, ErrManagerShutdown
 	}
-	//初始化session，包括等待登录输出和禁用分页
-	this.initSession(mySession, brand)
-	//更新session的缓存
-	this.SetSessionCache(sessionKey, mySession)
-	return nil
+	sessionKey := device.Key()
+	pool := this.getPoolCache(sessionKey)
+	if pool != nil && !pool.isBroken() {
+		return pool, nil
+	}
+	if err := this.connectSession(device, pool); err != nil {
+		this.config.logger.Error("SSH session pool updateSession err:%s", err.Error())
+		return nil, err
+	}
+	if pool = this.getPoolCache(sessionKey); pool == nil {
+		return nil, errPoolClosed
+	}
+	return pool, nil
 }
 
 /**
- * 初始化会话（等待登录，识别设备类型，执行禁止分页）
- * @param  session:需要执行初始化操作的SSHSession
+ * 对同一设备的连接去重：没有正在进行的连接时调用updateSession，否则等待正在进行的连接完成并共享其结果
+ * @param  device 设备的身份信息, brokenPool 调用者检查到的不可用连接池（为nil表示缓存中不存在）
+ * @return 执行的错误
  * @author shenbowei
  */
-func (this *SessionManager) initSession(session *SSHSession, brand string) {
-	if brand != HUAWEI && brand != H3C && brand != CISCO {
-		//如果传入的设备型号不匹配则自己获取
-		brand = session.GetSSHBrand()
+func (this *SessionManager) connectSession(device Device, brokenPool *devicePool) error {
+	sessionKey := device.Key()
+	this.dialCallsLocker.Lock()
+	if call, ok := this.dialCalls[sessionKey]; ok {
+		this.dialCallsLocker.Unlock()
+		this.config.logger.Debug("Wait for the connecting session<%s>", device)
+		<-call.done
+		return call.err
 	}
-	switch brand {
-	case HUAWEI:
-		session.WriteChannel(HuaweiNoPage)
-		break
-	case H3C:
-		session.WriteChannel(H3cNoPage)
-		break
-	case CISCO:
-		session.WriteChannel(CiscoNoPage)
-		break
-	default:
-		return
+	//在等待锁期间其他调用可能已经完成了连接，缓存中的连接池已被替换则无需再次连接
+	if cached := this.getPoolCache(sessionKey); cached != nil && cached != brokenPool {
+		this.dialCallsLocker.Unlock()
+		return nil
 	}
-	session.ReadChannelExpect(time.Second, "#", ">", "]")
+	call := &dialCall{done: make(chan struct{})}
+	this.dialCalls[sessionKey] = call
+	this.dialCallsLocker.Unlock()
+
+	call.err = this.updateSession(device, brokenPool)
+	this.dialCallsLocker.Lock()
+	delete(this.dialCalls, sessionKey)
+	this.dialCallsLocker.Unlock()
+	close(call.done)
+	return call.err
 }
 
 /**
- * 从缓存中获取session。如果不存在或者不可用，则重新创建
- * @param  user ssh连接的用户名, password 密码, ipPort 交换机的ip和端口
- * @return SSHSession
+ * 连接设备，创建连接池（打开并初始化第一个shell），添加到缓存并关闭被替换的不可用连接池
+ * @param  device 设备的身份信息, brokenPool 被替换的不可用连接池（可为nil）
+ * @return 执行的错误
  * @author shenbowei
  */
-func (this *SessionManager) GetSession(user, password, ipPort, brand string) (*SSHSession, error) {
-	sessionKey := user + "_" + password + "_" + ipPort
-	session := this.GetSessionCache(sessionKey)
-	if session != nil {
-		//返回前要验证是否可用，不可用要重新创建并更新缓存
-		if session.CheckSelf() {
-			LogDebug("-----GetSession from cache-----")
-			session.UpdateLastUseTime()
-			return session, nil
+func (this *SessionManager) updateSession(device Device, brokenPool *devicePool) error {
+	sessionKey := device.Key()
+	var outer *devicePool
+	if device.Via != nil {
+		//外层设备使用缓存中的连接池，与直接访问外层设备共用登录
+		var err error
+		if outer, err = this.getPool(*device.Via); err != nil {
+			return err
 		}
-		LogDebug("Check session failed")
 	}
-	//如果不存在或者验证失败，需要重新连接，并更新缓存
-	if err := this.updateSession(user, password, ipPort, brand); err != nil {
-		LogError("SSH session pool updateSession err:%s", err.Error())
-		return nil, err
-	} else {
-		return this.GetSessionCache(sessionKey), nil
+	pool, err := newDevicePool(device, outer, this.config, this.jumps)
+	if err != nil {
+		this.config.logger.Error("Connect device<%s> err:%s", device, err.Error())
+		return err
+	}
+	if err := this.addPoolCache(sessionKey, pool); err != nil {
+		pool.close()
+		return err
+	}
+	if brokenPool != nil {
+		this.closePool(brokenPool)
+	}
+	return nil
+}
+
+/**
+ * 按需提升会话的权限：cisco登录后处于用户模式（">"）时自动enable，华为/h3c在提供了提权密码时执行super
+ * @param  session:需要提权的session, brand 交换机品牌, enablePassword 提权密码（可为空）
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func enableSession(session Session, brand, enablePassword string) error {
+	if session.IsEnabled() {
+		return nil
 	}
+	switch brand {
+	case HUAWEI, H3C:
+		if enablePassword == "" {
+			return nil
+		}
+	case CISCO:
+		if session.GetPrivilegeMode() != USER_MODE {
+			//登录后即处于特权模式，记录为已提权，之后不再检查提示符
+			session.shell().enabled = true
+			return nil
+		}
+	default:
+		return nil
+	}
+	return session.Enable(brand, enablePassword)
 }
 
 /**
- * 开始自动清理session缓存中未使用超过10分钟的session
+ * 开始自动清理连接池中未使用超过idleTimeout的shell，连接池的shell全部关闭后移除连接池，Shutdown后停止
  * @author shenbowei
  */
 func (this *SessionManager) RunAutoClean() {
+	this.cleanWaitGroup.Add(1)
 	go func() {
+		defer this.cleanWaitGroup.Done()
+		ticker := time.NewTicker(this.config.cleanInterval)
+		defer ticker.Stop()
 		for {
-			timeoutSessionIndex := this.getTimeoutSessionIndex()
-			this.sessionCacheLocker.Lock()
-			for _, sessionKey := range timeoutSessionIndex {
-				this.LockSession(sessionKey)
-				delete(this.sessionCache, sessionKey)
-				this.UnlockSession(sessionKey)
+			select {
+			case <-this.stopClean:
+				return
+			case <-ticker.C:
+				this.cleanIdleSessions()
 			}
-			this.sessionCacheLocker.Unlock()
-			time.Sleep(30 * time.Second)
 		}
 	}()
 }
 
 /**
- * 获取所有超时（10分钟未使用）session在cache的sessionKey
- * @return []string 所有超时的sessionKey数组
+ * 清理所有连接池中超时（idleTimeout未使用）的shell，并移除已经清空的连接池
  * @author shenbowei
  */
-func (this *SessionManager) getTimeoutSessionIndex() []string {
-	timeoutSessionIndex := make([]string, 0)
-	this.sessionCacheLocker.RLock()
+func (this *SessionManager) cleanIdleSessions() {
 	defer func() {
-		this.sessionCacheLocker.RUnlock()
 		if err := recover(); err != nil {
-			LogError("SSHSessionManager getTimeoutSessionIndex err:%s", err)
+			this.config.logger.Error("SSHSessionManager cleanIdleSessions err:%s", err)
 		}
 	}()
-	for sessionKey, SSHSession := range this.sessionCache {
-		timeDuratime := time.Now().Sub(SSHSession.GetLastUseTime())
-		if timeDuratime.Minutes() > 10 {
-			LogDebug("RunAutoClean close session<%s, unuse time=%s>", sessionKey, timeDuratime.String())
-			SSHSession.Close()
-			timeoutSessionIndex = append(timeoutSessionIndex, sessionKey)
+	this.sessionCacheLocker.RLock()
+	pools := make(map[string]*devicePool, len(this.poolCache))
+	for sessionKey, pool := range this.poolCache {
+		pools[sessionKey] = pool
+	}
+	this.sessionCacheLocker.RUnlock()
+	for sessionKey, pool := range pools {
+		if pool.cleanIdle(this.config.idleTimeout) {
+			this.removePool(sessionKey, pool)
 		}
 	}
-	return timeoutSessionIndex
+}
+
+/**
+ * 从缓存中移除连接池（缓存中的连接池已被替换时不移除），并在后台关闭
+ * @param  sessionKey:设备的索引键值, pool:需要移除的连接池
+ * @author shenbowei
+ */
+func (this *SessionManager) removePool(sessionKey string, pool *devicePool) {
+	this.sessionCacheLocker.Lock()
+	if this.poolCache[sessionKey] == pool {
+		delete(this.poolCache, sessionKey)
+	}
+	this.sessionCacheLocker.Unlock()
+	this.closePool(pool)
+}
+
+/**
+ * 在后台关闭连接池（会等待正在使用的shell归还），Shutdown会等待所有连接池关闭完成。
+ * Shutdown之后关闭的连接池不再计入poolWaitGroup，避免与Shutdown中的Wait并发调用Add
+ * @param  pool:需要关闭的连接池
+ * @author shenbowei
+ */
+func (this *SessionManager) closePool(pool *devicePool) {
+	this.sessionCacheLocker.Lock()
+	tracked := !this.isShutdown
+	if tracked {
+		this.poolWaitGroup.Add(1)
+	}
+	this.sessionCacheLocker.Unlock()
+	go func() {
+		if tracked {
+			defer this.poolWaitGroup.Done()
+		}
+		pool.close()
+	}()
+}
+
+/**
+ * 关闭SessionManager：停止自动清理，等待正在使用的shell归还后关闭所有连接池（包括ssh连接和读写协程）。
+ * 关闭后再获取session会返回ErrManagerShutdown
+ * @param  ctx:等待关闭完成的上下文，超时或取消时返回ctx.Err()，剩余的连接池会在后台继续关闭
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) Shutdown(ctx context.Context) error {
+	this.sessionCacheLocker.Lock()
+	this.isShutdown = true
+	pools := this.poolCache
+	this.poolCache = make(map[string]*devicePool, 0)
+	//与isShutdown在同一次加锁中计数，之后closePool不会再调用Add
+	this.poolWaitGroup.Add(len(pools))
+	this.sessionCacheLocker.Unlock()
+	this.stopCleanOnce.Do(func() {
+		close(this.stopClean)
+	})
+	for _, pool := range pools {
+		go func(pool *devicePool) {
+			defer this.poolWaitGroup.Done()
+			pool.close()
+		}(pool)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		this.cleanWaitGroup.Wait()
+		this.poolWaitGroup.Wait()
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
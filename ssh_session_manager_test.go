@@ -0,0 +1,1070 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+ * 测试使用的模拟交换机，提供ssh登录和带回显的shell，按指令返回预设的输出
+ */
+type fakeSwitch struct {
+	t               *testing.T
+	listener        net.Listener
+	config          *ssh.ServerConfig
+	prompt          string
+	outputs         map[string]string
+	execEnabled     bool
+	execDelay       time.Duration
+	locker          sync.Mutex
+	dials           int
+	forwards        int
+	hopPrompt       string
+	hopPassword     string
+	hopOutputs      map[string]string
+	hopCommands     []string
+	hopBanner       string
+	enablePrompt    string
+	enablePassword  string
+	preShell        []string
+	preShellAnswers []string
+	banner          string
+	commands        []string
+	activeConns     int
+	conns           []net.Conn
+	waitGroup       sync.WaitGroup
+}
+
+func newFakeSwitch(t *testing.T, prompt string, outputs map[string]string) *fakeSwitch {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey err:%s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey err:%s", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	fake := &fakeSwitch{t: t, listener: listener, prompt: prompt, outputs: outputs}
+	fake.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+		BannerCallback: func(conn ssh.ConnMetadata) string {
+			fake.locker.Lock()
+			defer fake.locker.Unlock()
+			return fake.banner
+		},
+	}
+	fake.config.AddHostKey(signer)
+	fake.waitGroup.Add(1)
+	go fake.serve()
+	t.Cleanup(fake.close)
+	return fake
+}
+
+func (this *fakeSwitch) addr() string {
+	return this.listener.Addr().String()
+}
+
+func (this *fakeSwitch) close() {
+	this.listener.Close()
+	this.locker.Lock()
+	for _, conn := range this.conns {
+		conn.Close()
+	}
+	this.locker.Unlock()
+	this.waitGroup.Wait()
+}
+
+func (this *fakeSwitch) getDials() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.dials
+}
+
+func (this *fakeSwitch) getActiveConns() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.activeConns
+}
+
+func (this *fakeSwitch) waitActiveConns(expected int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if this.getActiveConns() == expected {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return this.getActiveConns() == expected
+}
+
+func (this *fakeSwitch) serve() {
+	defer this.waitGroup.Done()
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		this.locker.Lock()
+		this.dials++
+		this.activeConns++
+		this.conns = append(this.conns, conn)
+		this.locker.Unlock()
+		this.waitGroup.Add(1)
+		go this.handleConn(conn)
+	}
+}
+
+func (this *fakeSwitch) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		this.locker.Lock()
+		this.activeConns--
+		this.locker.Unlock()
+		this.waitGroup.Done()
+	}()
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, this.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			this.forward(newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go this.handleSession(channel, channelRequests)
+	}
+}
+
+// 作为跳板机转发direct-tcpip通道
+func (this *fakeSwitch) forward(newChannel ssh.NewChannel) {
+	var target struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	this.locker.Lock()
+	this.forwards++
+	this.locker.Unlock()
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	go func() {
+		io.Copy(channel, conn)
+		channel.Close()
+	}()
+}
+
+func (this *fakeSwitch) getForwards() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.forwards
+}
+
+func (this *fakeSwitch) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for request := range requests {
+		switch request.Type {
+		case "pty-req":
+			request.Reply(true, nil)
+		case "shell":
+			request.Reply(true, nil)
+			go this.runShell(channel)
+		case "exec":
+			if !this.execEnabled {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			//payload为ssh字符串：4字节长度+指令
+			cmd := string(request.Payload[4:])
+			exitStatus := uint32(0)
+			output, ok := this.outputs[cmd]
+			if !ok {
+				output = "% Invalid input detected"
+				exitStatus = 1
+			}
+			this.locker.Lock()
+			delay := this.execDelay
+			this.locker.Unlock()
+			time.Sleep(delay)
+			channel.Write([]byte(output + "\n"))
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{exitStatus}))
+			return
+		default:
+			request.Reply(false, nil)
+		}
+	}
+}
+
+func (this *fakeSwitch) runShell(channel ssh.Channel) {
+	reader := bufio.NewReader(channel)
+	//登录后在shell中的交互提示（再次登录、修改密码等），每个提示读取一行应答
+	for _, prompt := range this.preShell {
+		channel.Write([]byte(prompt))
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		this.locker.Lock()
+		this.preShellAnswers = append(this.preShellAnswers, strings.TrimSpace(answer))
+		this.locker.Unlock()
+	}
+	channel.Write([]byte("Info: The max number of VTY users is 5.\r\n" + this.prompt))
+	prompt := this.prompt
+	outputs := this.outputs
+	//模拟在命令行中stelnet到内层设备：确认主机密钥 -> 用户名 -> 密码
+	hopState := ""
+	line := make([]byte, 0)
+	buf := make([]byte, 1024)
+	for {
+		n, err := reader.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			if b != '\n' && b != '\r' {
+				line = append(line, b)
+				continue
+			}
+			cmd := strings.TrimSpace(string(line))
+			line = line[:0]
+			switch {
+			case hopState == "confirm":
+				hopState = "username"
+				channel.Write([]byte(cmd + "\r\nPlease input the username:"))
+				continue
+			case hopState == "username":
+				hopState = "password"
+				channel.Write([]byte(cmd + "\r\nEnter password:"))
+				continue
+			case hopState == "password":
+				hopState = ""
+				if cmd != this.hopPassword {
+					channel.Write([]byte("\r\nError: Failed to log in.\r\n" + prompt))
+					continue
+				}
+				prompt = this.hopPrompt
+				outputs = this.hopOutputs
+				channel.Write([]byte("\r\n" + this.hopBanner + "Info: The max number of VTY users is 5.\r\n" + prompt))
+				continue
+			case hopState == "enable":
+				hopState = ""
+				if cmd != this.enablePassword {
+					channel.Write([]byte("\r\n% Access denied\r\n" + prompt))
+					continue
+				}
+				prompt = this.enablePrompt
+				channel.Write([]byte("\r\n" + prompt))
+				continue
+			case this.enablePrompt != "" && prompt == this.prompt && (cmd == "enable" || cmd == "super"):
+				//模拟cisco的enable和华为/h3c的super：密码提示 -> 提权后的提示符
+				this.locker.Lock()
+				this.commands = append(this.commands, cmd)
+				this.locker.Unlock()
+				hopState = "enable"
+				channel.Write([]byte(cmd + "\r\nPassword:"))
+				continue
+			case this.hopPrompt != "" && prompt == this.prompt && strings.HasPrefix(cmd, "stelnet "):
+				this.locker.Lock()
+				this.hopCommands = append(this.hopCommands, cmd)
+				this.locker.Unlock()
+				hopState = "confirm"
+				channel.Write([]byte(cmd + "\r\nThe server is not authenticated. Continue to access it? [Y/N]:"))
+				continue
+			case prompt == this.hopPrompt && cmd == "quit":
+				prompt = this.prompt
+				outputs = this.outputs
+				channel.Write([]byte(cmd + "\r\n" + prompt))
+				continue
+			}
+			output := cmd + "\r\n"
+			if cmd != "" {
+				this.locker.Lock()
+				this.commands = append(this.commands, cmd)
+				this.locker.Unlock()
+				output += outputs[cmd] + "\r\n"
+			}
+			channel.Write([]byte(output + prompt))
+		}
+	}
+}
+
+func (this *fakeSwitch) getPreShellAnswers() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string(nil), this.preShellAnswers...)
+}
+
+func (this *fakeSwitch) getCommands() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string(nil), this.commands...)
+}
+
+func (this *fakeSwitch) getHopCommands() []string {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]string(nil), this.hopCommands...)
+}
+
+/**
+ * 测试使用的日志实现，丢弃所有输出；不修改包级别的IsLogDebug，其他测试遗留的协程可能正在读取它
+ */
+type quietLogger struct{}
+
+func (quietLogger) Debug(format string, a ...interface{}) {}
+
+func (quietLogger) Error(format string, a ...interface{}) {}
+
+func newTestSessionManager(opts ...Option) *SessionManager {
+	opts = append([]Option{
+		WithLogger(quietLogger{}),
+		WithPromptTimeout(100 * time.Millisecond),
+		WithCommandTimeout(200 * time.Millisecond),
+		WithDialTimeout(2 * time.Second),
+	}, opts...)
+	return NewSessionManager(opts...)
+}
+
+func TestSessionManagerShutdown(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	manager := newTestSessionManager()
+
+	result, err := manager.RunCommandsWithBrand("admin", "admin", fake.addr(), HUAWEI, "dis clock")
+	if err != nil {
+		t.Fatalf("RunCommands err:%s", err)
+	}
+	if !strings.Contains(result, "2026-10-18 10:00:00") {
+		t.Fatalf("unexpected result:%q", result)
+	}
+	session, err := manager.CheckoutSession(context.Background(), NewDevice("admin", "admin", fake.addr(), HUAWEI))
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	manager.ReleaseSession(session)
+	if dials := fake.getDials(); dials != 1 {
+		t.Fatalf("dials=%d, expected the cached session to be reused", dials)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown err:%s", err)
+	}
+	if !session.IsClosed() {
+		t.Errorf("session is not closed after Shutdown")
+	}
+	if !fake.waitActiveConns(0, 2*time.Second) {
+		t.Errorf("connection is not closed after Shutdown, active=%d", fake.getActiveConns())
+	}
+	if _, err := manager.RunCommands("admin", "admin", fake.addr(), "dis clock"); err != ErrManagerShutdown {
+		t.Errorf("RunCommands after Shutdown err=%v, expected ErrManagerShutdown", err)
+	}
+}
+
+func TestSessionManagerShutdownWhileClosingPools(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", nil)
+	manager := newTestSessionManager()
+	device := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+	other := NewDevice("guest", "guest", fake.addr(), HUAWEI)
+	session, err := manager.CheckoutSession(context.Background(), device)
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	if _, err := manager.RunDeviceCommands(other, "dis clock"); err != nil {
+		t.Fatalf("RunDeviceCommands err:%s", err)
+	}
+	pool := manager.getPoolCache(other.Key())
+
+	//Shutdown等待使用中的shell归还
+	finished := make(chan error, 1)
+	go func() {
+		finished <- manager.Shutdown(context.Background())
+	}()
+	time.Sleep(200 * time.Millisecond)
+	manager.ReleaseSession(session)
+	//使用中的连接池关闭后，Shutdown中的Wait返回之前再关闭连接池
+	fake.waitActiveConns(0, 2*time.Second)
+	manager.removePool(other.Key(), pool)
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("Shutdown err:%s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Shutdown is blocked")
+	}
+	if !fake.waitActiveConns(0, 2*time.Second) {
+		t.Errorf("connections are not closed after Shutdown, active=%d", fake.getActiveConns())
+	}
+}
+
+func TestSessionManagerAutoClean(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", nil)
+	manager := newTestSessionManager(WithIdleTimeout(200*time.Millisecond), WithCleanInterval(50*time.Millisecond))
+	defer manager.Shutdown(context.Background())
+
+	if _, err := manager.RunCommandsWithBrand("admin", "admin", fake.addr(), HUAWEI, "dis clock"); err != nil {
+		t.Fatalf("RunCommands err:%s", err)
+	}
+	if fake.getActiveConns() != 1 {
+		t.Fatalf("active connections=%d, expected 1", fake.getActiveConns())
+	}
+	if !fake.waitActiveConns(0, 3*time.Second) {
+		t.Errorf("idle connection is not closed, active=%d", fake.getActiveConns())
+	}
+	if _, ok := manager.GetPoolStats(NewDevice("admin", "admin", fake.addr(), "")); ok {
+		t.Errorf("idle device pool is still cached")
+	}
+}
+
+func TestSessionManagerSingleFlight(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", nil)
+	manager := newTestSessionManager(WithMaxShellsPerDevice(8))
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			session, err := manager.CheckoutSession(context.Background(), device)
+			if err != nil {
+				t.Errorf("CheckoutSession<%d> err:%s", i, err)
+				return
+			}
+			manager.ReleaseSession(session)
+		}(i)
+	}
+	waitGroup.Wait()
+	if dials := fake.getDials(); dials != 1 {
+		t.Errorf("dials=%d, expected 1", dials)
+	}
+}
+
+func TestSessionManagerParallelShells(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	manager := newTestSessionManager(WithMaxShellsPerDevice(4))
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+	device.MaxVTY = 2
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			result, err := manager.RunDeviceCommands(device, "dis clock")
+			if err != nil {
+				t.Errorf("RunDeviceCommands<%d> err:%s", i, err)
+				return
+			}
+			if !strings.Contains(result, "2026-10-18 10:00:00") {
+				t.Errorf("RunDeviceCommands<%d> unexpected result:%q", i, result)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+	stats, ok := manager.GetPoolStats(device)
+	if !ok {
+		t.Fatalf("device pool is not cached")
+	}
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected all shells to share one connection", fake.getDials())
+	}
+	if stats.MaxShells != 2 || stats.OpenShells != 2 || stats.InUse != 0 {
+		t.Errorf("unexpected pool stats:%+v", stats)
+	}
+	if stats.Checkouts != 6 || stats.Waits == 0 || stats.MaxWait <= 0 {
+		t.Errorf("unexpected wait stats:%+v", stats)
+	}
+}
+
+func TestSessionManagerLockSession(t *testing.T) {
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	var waitGroup sync.WaitGroup
+	//每个sessionKey使用独立的计数器，只受各自的session锁保护
+	counters := map[string]*int{"a": new(int), "b": new(int), "c": new(int)}
+	for i := 0; i < 50; i++ {
+		for _, sessionKey := range []string{"a", "b", "c"} {
+			waitGroup.Add(1)
+			go func(sessionKey string) {
+				defer waitGroup.Done()
+				manager.LockSession(sessionKey)
+				*counters[sessionKey]++
+				manager.UnlockSession(sessionKey)
+			}(sessionKey)
+		}
+	}
+	waitGroup.Wait()
+	for _, sessionKey := range []string{"a", "b", "c"} {
+		if *counters[sessionKey] != 50 {
+			t.Errorf("counter<%s>=%d, expected 50", sessionKey, *counters[sessionKey])
+		}
+	}
+}
+
+func TestSessionManagerExecMode(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+	fake.execEnabled = true
+	manager := newTestSessionManager(WithExecMode(CISCO, EXEC_MODE))
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+
+	results, err := manager.RunDeviceExec(device, "show clock", "show foo")
+	if err != nil {
+		t.Fatalf("RunDeviceExec err:%s", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results=%+v, expected 2 results", results)
+	}
+	if results[0].ExitStatus != 0 || strings.TrimSpace(results[0].Output) != "10:00:00.000 UTC Sun Oct 18 2026" {
+		t.Errorf("unexpected result:%+v", results[0])
+	}
+	if results[1].ExitStatus != 1 {
+		t.Errorf("unexpected exit status:%+v", results[1])
+	}
+}
+
+func TestSessionManagerExecModeDetectedBrand(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{
+		"show clock":   "10:00:00.000 UTC Sun Oct 18 2026",
+		"show version": "Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(7)E2",
+	})
+	fake.execEnabled = true
+	manager := newTestSessionManager(WithExecMode(CISCO, EXEC_MODE))
+	defer manager.Shutdown(context.Background())
+
+	//品牌为空时按识别出的品牌查找执行方式，通过exec通道识别品牌，不打开shell
+	device := NewDevice("admin", "admin", fake.addr(), "")
+	results, err := manager.RunDeviceExec(device, "show clock")
+	if err != nil {
+		t.Fatalf("RunDeviceExec err:%s", err)
+	}
+	if len(results) != 1 || results[0].ExitStatus != 0 {
+		t.Errorf("results=%+v, expected an exec result", results)
+	}
+	if stats, _ := manager.GetPoolStats(device); stats.OpenShells != 0 {
+		t.Errorf("unexpected pool stats:%+v, expected no shell", stats)
+	}
+	if brand, err := manager.GetSSHBrand("admin", "admin", fake.addr()); err != nil || brand != CISCO {
+		t.Errorf("GetSSHBrand brand=%q err:%v", brand, err)
+	}
+}
+
+func TestSessionManagerExecModeDetectBrandInShell(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{
+		"show clock":   "10:00:00.000 UTC Sun Oct 18 2026",
+		"show version": "Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Version 15.2(7)E2",
+	})
+	manager := newTestSessionManager(WithExecMode(CISCO, EXEC_MODE))
+	defer manager.Shutdown(context.Background())
+
+	//设备拒绝exec请求时通过shell识别品牌并执行指令
+	results, err := manager.RunDeviceExec(NewDevice("admin", "admin", fake.addr(), ""), "show clock")
+	if err != nil {
+		t.Fatalf("RunDeviceExec err:%s", err)
+	}
+	if len(results) != 1 || results[0].ExitStatus != -1 || !strings.Contains(results[0].Output, "10:00:00.000") {
+		t.Errorf("results=%+v, expected a shell result", results)
+	}
+}
+
+func TestSessionManagerExecTimeout(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+	fake.execEnabled = true
+	manager := newTestSessionManager(WithExecMode(CISCO, EXEC_MODE))
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+	setDelay := func(delay time.Duration) {
+		fake.locker.Lock()
+		fake.execDelay = delay
+		fake.locker.Unlock()
+	}
+
+	if _, err := manager.RunDeviceExec(device, "show clock"); err != nil {
+		t.Fatalf("RunDeviceExec err:%s", err)
+	}
+	setDelay(2 * time.Second)
+	start := time.Now()
+	if _, err := manager.RunDeviceExec(device, "show clock"); err != ErrExecTimeout {
+		t.Fatalf("RunDeviceExec err:%v, expected ErrExecTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RunDeviceExec took %s, expected to stop at the command timeout", elapsed)
+	}
+	//超时不影响连接，之后的指令继续使用同一个连接
+	setDelay(0)
+	if _, err := manager.RunDeviceExec(device, "show clock"); err != nil {
+		t.Errorf("RunDeviceExec err:%s", err)
+	}
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected 1", fake.getDials())
+	}
+}
+
+func TestSessionManagerExecFallback(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+	device.ExecMode = EXEC_MODE
+
+	for i := 0; i < 2; i++ {
+		result, err := manager.RunDeviceCommands(device, "show clock")
+		if err != nil {
+			t.Fatalf("RunDeviceCommands err:%s", err)
+		}
+		if !strings.Contains(result, "10:00:00.000 UTC Sun Oct 18 2026") {
+			t.Errorf("unexpected result:%q", result)
+		}
+	}
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected 1", fake.getDials())
+	}
+}
+
+func TestSessionManagerJumpHosts(t *testing.T) {
+	bastion := newFakeSwitch(t, "bastion$", nil)
+	innerBastion := newFakeSwitch(t, "inner$", nil)
+	fakeA := newFakeSwitch(t, "<HUAWEI-A>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	fakeB := newFakeSwitch(t, "<HUAWEI-B>", map[string]string{"dis clock": "2026-10-18 11:00:00"})
+	manager := newTestSessionManager()
+	jumpHost := func(fake *fakeSwitch) JumpHost {
+		host, port, _ := net.SplitHostPort(fake.addr())
+		portNumber, _ := strconv.Atoi(port)
+		return JumpHost{Host: host, Port: portNumber, User: "jump", Credential: &Credential{Password: "jump"}}
+	}
+	deviceA := NewDevice("admin", "admin", fakeA.addr(), HUAWEI)
+	deviceA.JumpHosts = []JumpHost{jumpHost(bastion)}
+	deviceB := NewDevice("admin", "admin", fakeB.addr(), HUAWEI)
+	deviceB.JumpHosts = []JumpHost{jumpHost(bastion), jumpHost(innerBastion)}
+
+	for _, test := range []struct {
+		device   Device
+		expected string
+	}{{deviceA, "10:00:00"}, {deviceB, "11:00:00"}, {deviceA, "10:00:00"}} {
+		result, err := manager.RunDeviceCommands(test.device, "dis clock")
+		if err != nil {
+			t.Fatalf("RunDeviceCommands<%s> err:%s", test.device.Key(), err)
+		}
+		if !strings.Contains(result, test.expected) {
+			t.Errorf("RunDeviceCommands<%s> unexpected result:%q", test.device.Key(), result)
+		}
+	}
+	if bastion.getDials() != 1 || innerBastion.getDials() != 1 {
+		t.Errorf("bastion dials=%d, inner bastion dials=%d, expected the jump connections to be shared",
+			bastion.getDials(), innerBastion.getDials())
+	}
+	if bastion.getForwards() != 2 || innerBastion.getForwards() != 1 {
+		t.Errorf("bastion forwards=%d, inner bastion forwards=%d", bastion.getForwards(), innerBastion.getForwards())
+	}
+	if deviceA.Key() == NewDevice("admin", "admin", fakeA.addr(), HUAWEI).Key() {
+		t.Errorf("device key should include the jump hosts:%s", deviceA.Key())
+	}
+
+	if err := manager.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown err:%s", err)
+	}
+	if !bastion.waitActiveConns(0, 2*time.Second) || !innerBastion.waitActiveConns(0, 2*time.Second) {
+		t.Errorf("jump connections are not closed after Shutdown")
+	}
+}
+
+func TestJumpCacheAcquire(t *testing.T) {
+	bastion := newFakeSwitch(t, "bastion$", nil)
+	//接受连接但不进行ssh握手的跳板机
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	config := newSessionConfig()
+	config.logger = quietLogger{}
+	config.dialTimeout = 2 * time.Second
+	jumps := newJumpCache(config)
+	jumpHost := func(address string) []JumpHost {
+		host, port, _ := net.SplitHostPort(address)
+		portNumber, _ := strconv.Atoi(port)
+		return []JumpHost{{Host: host, Port: portNumber, User: "jump", Credential: &Credential{Password: "jump"}}}
+	}
+
+	//同一条链路并发获取只连接一次
+	var waitGroup sync.WaitGroup
+	clients := make(chan *jumpClient, 8)
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			jump, err := jumps.acquire(jumpHost(bastion.addr()), nil)
+			if err != nil {
+				t.Errorf("acquire err:%s", err)
+				return
+			}
+			clients <- jump
+		}()
+	}
+	waitGroup.Wait()
+	close(clients)
+	if bastion.getDials() != 1 {
+		t.Errorf("bastion dials=%d, expected 1", bastion.getDials())
+	}
+
+	//连接其他跳板机期间不阻塞已缓存的连接
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := jumps.acquire(jumpHost(listener.Addr().String()), nil)
+		slowDone <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	jump, err := jumps.acquire(jumpHost(bastion.addr()), nil)
+	if err != nil {
+		t.Fatalf("acquire err:%s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("acquire took %s while another jump host is connecting", elapsed)
+	}
+	jumps.release(jump)
+	if err := <-slowDone; err == nil {
+		t.Errorf("acquire a jump host without ssh handshake succeeded")
+	}
+	for jump := range clients {
+		jumps.release(jump)
+	}
+	if !bastion.waitActiveConns(0, 2*time.Second) {
+		t.Errorf("jump connection is not closed after all references are released")
+	}
+}
+
+/**
+ * 统计模拟交换机收到某条指令的次数
+ */
+func countCommand(fake *fakeSwitch, cmd string) int {
+	count := 0
+	for _, command := range fake.getCommands() {
+		if command == cmd {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSessionManagerEnable(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch>", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+	fake.enablePrompt = "Switch#"
+	fake.enablePassword = "secret"
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+	device.Credential.EnablePassword = "secret"
+
+	session, err := manager.CheckoutSession(context.Background(), device)
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	if !session.IsEnabled() || session.GetPrivilegeMode() != PRIVILEGED_MODE {
+		t.Errorf("session enabled=%v mode=%s, expected privileged", session.IsEnabled(), session.GetPrivilegeMode())
+	}
+	manager.ReleaseSession(session)
+	if _, err := manager.RunDeviceCommands(device, "show clock"); err != nil {
+		t.Fatalf("RunDeviceCommands err:%s", err)
+	}
+	if count := countCommand(fake, "enable"); count != 1 {
+		t.Errorf("enable count=%d, expected 1", count)
+	}
+}
+
+func TestSessionManagerEnableWrongPassword(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch>", nil)
+	fake.enablePrompt = "Switch#"
+	fake.enablePassword = "secret"
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), CISCO)
+	device.Credential.EnablePassword = "wrong"
+
+	if _, err := manager.RunDeviceCommands(device, "show clock"); err == nil || !strings.Contains(err.Error(), "enable failed") {
+		t.Errorf("RunDeviceCommands err=%v, expected enable failed", err)
+	}
+}
+
+func TestSessionManagerEnablePrivilegedLogin(t *testing.T) {
+	fake := newFakeSwitch(t, "Switch#", nil)
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	session, err := manager.CheckoutSession(context.Background(), NewDevice("admin", "admin", fake.addr(), CISCO))
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	defer manager.ReleaseSession(session)
+	//登录后即处于特权模式时不执行enable，但会话记录为已提权
+	if !session.IsEnabled() {
+		t.Errorf("session is not marked as enabled")
+	}
+	if count := countCommand(fake, "enable"); count != 0 {
+		t.Errorf("enable count=%d, expected 0", count)
+	}
+}
+
+func TestSessionManagerSuperCachedSession(t *testing.T) {
+	fake := newFakeSwitch(t, "<HUAWEI>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	fake.enablePrompt = "<HUAWEI>"
+	fake.enablePassword = "super"
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+
+	if _, err := manager.RunDeviceCommands(device, "dis clock"); err != nil {
+		t.Fatalf("RunDeviceCommands err:%s", err)
+	}
+	if count := countCommand(fake, "super"); count != 0 {
+		t.Fatalf("super count=%d without the enable password, expected 0", count)
+	}
+	//之后提供了提权密码，缓存中的会话在取出时执行super，且只执行一次
+	device.Credential = &Credential{Password: "admin", EnablePassword: "super"}
+	for i := 0; i < 2; i++ {
+		if _, err := manager.RunDeviceCommands(device, "dis clock"); err != nil {
+			t.Fatalf("RunDeviceCommands err:%s", err)
+		}
+	}
+	if count := countCommand(fake, "super"); count != 1 {
+		t.Errorf("super count=%d, expected 1", count)
+	}
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected 1", fake.getDials())
+	}
+}
+
+func TestSessionManagerHop(t *testing.T) {
+	fake := newFakeSwitch(t, "<AGG>", map[string]string{"dis clock": "outer clock"})
+	fake.hopPrompt = "<ACCESS>"
+	fake.hopPassword = "inner"
+	fake.hopOutputs = map[string]string{"dis clock": "2026-10-18 10:00:00"}
+	//登录后的banner中包含"error"、"failed"等字样不影响登录结果
+	fake.hopBanner = "Warning: Unauthorized access is prohibited. Failed attempts and errors are logged.\r\n"
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	outer := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+	device := Device{Host: "192.168.1.2", User: "inner", Credential: &Credential{Password: "inner"}, Brand: HUAWEI, Via: &outer}
+
+	if result, err := manager.RunDeviceCommands(outer, "dis clock"); err != nil || !strings.Contains(result, "outer clock") {
+		t.Fatalf("RunDeviceCommands<outer> result:%q err:%v", result, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := manager.RunDeviceCommands(device, "dis clock")
+		if err != nil {
+			t.Fatalf("RunDeviceCommands err:%s", err)
+		}
+		if !strings.Contains(result, "2026-10-18 10:00:00") {
+			t.Errorf("unexpected result:%q", result)
+		}
+	}
+	//连接时验证登录一次，之后每次获取都重新登录（归还时退回外层设备）
+	commands := fake.getHopCommands()
+	if len(commands) != 3 {
+		t.Errorf("unexpected hop commands:%v", commands)
+	}
+	for _, command := range commands {
+		if command != "stelnet 192.168.1.2" {
+			t.Errorf("unexpected hop command:%q", command)
+		}
+	}
+	//内层设备复用缓存中外层设备的连接，不再次登录外层设备
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected 1", fake.getDials())
+	}
+
+	//关闭内层会话后回到外层设备
+	session, err := manager.CheckoutSession(context.Background(), device)
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	hopSession := session.(*HopSession)
+	hopSession.Close()
+	if hopSession.outer.IsClosed() {
+		t.Fatalf("outer session is closed after the hop exits")
+	}
+	if prompt := hopSession.outer.GetPrompt(); prompt != "<AGG>" {
+		t.Errorf("outer prompt=%q after the hop exits, expected <AGG>", prompt)
+	}
+	manager.ReleaseSession(session)
+
+	device.Credential = &Credential{Password: "wrong"}
+	if _, err := manager.RunDeviceCommands(device, "dis clock"); err == nil || !strings.Contains(err.Error(), ErrHopFailed.Error()) {
+		t.Errorf("RunDeviceCommands with wrong password err=%v, expected ErrHopFailed", err)
+	}
+}
+
+func TestSessionManagerHopReleasesOuterShell(t *testing.T) {
+	fake := newFakeSwitch(t, "<AGG>", map[string]string{"dis clock": "outer clock"})
+	fake.hopPrompt = "<ACCESS>"
+	fake.hopPassword = "inner"
+	fake.hopOutputs = map[string]string{"dis clock": "2026-10-18 10:00:00"}
+	manager := newTestSessionManager(WithMaxShellsPerDevice(1))
+	defer manager.Shutdown(context.Background())
+	outer := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+	device := Device{Host: "192.168.1.2", User: "inner", Credential: &Credential{Password: "inner"}, Brand: HUAWEI, Via: &outer}
+
+	//外层设备只有一个shell，执行完内层设备的指令后外层设备的shell已经归还
+	for i := 0; i < 2; i++ {
+		if result, err := manager.RunDeviceCommands(device, "dis clock"); err != nil || !strings.Contains(result, "2026-10-18 10:00:00") {
+			t.Fatalf("RunDeviceCommands<inner> result:%q err:%v", result, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		session, err := manager.CheckoutSession(ctx, outer)
+		cancel()
+		if err != nil {
+			t.Fatalf("CheckoutSession<outer> err:%s", err)
+		}
+		manager.ReleaseSession(session)
+		if result, err := manager.RunDeviceCommands(outer, "dis clock"); err != nil || !strings.Contains(result, "outer clock") {
+			t.Fatalf("RunDeviceCommands<outer> result:%q err:%v", result, err)
+		}
+	}
+	if stats, _ := manager.GetPoolStats(outer); stats.InUse != 0 || stats.IdleShells != 1 {
+		t.Errorf("unexpected outer pool stats:%+v", stats)
+	}
+}
+
+func TestHopCommand(t *testing.T) {
+	device := Device{Host: "10.0.0.2", User: "admin"}
+	tests := []struct {
+		brand     string
+		transport string
+		port      int
+		expected  string
+	}{
+		{HUAWEI, "", 0, "stelnet 10.0.0.2"},
+		{HUAWEI, "", 2222, "stelnet 10.0.0.2 2222"},
+		{H3C, "", 0, "ssh2 10.0.0.2"},
+		{CISCO, "", 2222, "ssh -l admin -p 2222 10.0.0.2"},
+		{CISCO, TELNET_TRANSPORT, 0, "telnet 10.0.0.2"},
+	}
+	for _, test := range tests {
+		device.Transport = test.transport
+		device.Port = test.port
+		if cmd := hopCommand(test.brand, device); cmd != test.expected {
+			t.Errorf("hopCommand(%s, %s, %d)=%q, expected %q", test.brand, test.transport, test.port, cmd, test.expected)
+		}
+	}
+}
+
+func TestSessionManagerShellLoginPrompts(t *testing.T) {
+	tests := []struct {
+		name     string
+		preShell []string
+		opts     []Option
+		err      error
+		answers  []string
+	}{
+		{"login local", []string{"\r\nUser Access Verification\r\n\r\nUsername:", "Password:"}, nil, nil, []string{"admin", "admin"}},
+		{"skip password change", []string{"The password needs to be changed. Change now? [Y/N]:"}, nil, nil, []string{"N"}},
+		{"refuse password change", []string{"The password needs to be changed. Change now? [Y/N]:"},
+			[]Option{WithPasswordChangePolicy(PASSWORD_CHANGE_FAIL)}, ErrPasswordChangeRequired, nil},
+		{"forced password change", []string{"Your password has expired. Change now? [Y/N]:", "Please enter old password:"},
+			nil, ErrPasswordChangeRequired, []string{"N"}},
+		{"wrong password in shell", []string{"Username:", "Password:", "% Login invalid\r\n\r\nUsername:"}, nil, ErrLoginFailed, []string{"admin", "admin"}},
+	}
+	for _, test := range tests {
+		fake := newFakeSwitch(t, "Switch#", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+		fake.preShell = test.preShell
+		manager := newTestSessionManager(test.opts...)
+		result, err := manager.RunDeviceCommands(NewDevice("admin", "admin", fake.addr(), CISCO), "show clock")
+		if err != test.err {
+			t.Errorf("<%s> RunDeviceCommands err=%v, expected %v", test.name, err, test.err)
+		}
+		if test.err == nil && !strings.Contains(result, "10:00:00.000 UTC Sun Oct 18 2026") {
+			t.Errorf("<%s> unexpected result:%q", test.name, result)
+		}
+		if answers := fake.getPreShellAnswers(); strings.Join(answers, ",") != strings.Join(test.answers, ",") {
+			t.Errorf("<%s> answers=%v, expected %v", test.name, answers, test.answers)
+		}
+		manager.Shutdown(context.Background())
+	}
+}
+
+func TestSessionManagerBannerAndMOTD(t *testing.T) {
+	fake := newFakeSwitch(t, "<Switch>", nil)
+	fake.locker.Lock()
+	fake.banner = "Huawei Integrated Access Software\r\nAuthorized users only!\r\n"
+	fake.locker.Unlock()
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	session, err := manager.CheckoutSession(context.Background(), NewDevice("admin", "admin", fake.addr(), ""))
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	defer manager.ReleaseSession(session)
+	if !strings.Contains(session.GetBanner(), "Authorized users only!") {
+		t.Errorf("unexpected banner:%q", session.GetBanner())
+	}
+	if session.GetMOTD() != "Info: The max number of VTY users is 5." {
+		t.Errorf("unexpected MOTD:%q", session.GetMOTD())
+	}
+	//模拟交换机没有版本指令的输出，只能从banner中识别品牌
+	if brand := session.GetSSHBrand(); brand != HUAWEI {
+		t.Errorf("brand=%q, expected %q", brand, HUAWEI)
+	}
+}
@@ -0,0 +1,510 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 设备会话的统一接口，SSHSession和TelnetSession都实现了该接口，SessionManager的连接池通过它管理不同传输方式的会话
+ * @author shenbowei
+ */
+type Session interface {
+	WriteChannel(cmds ...string)
+	ReadChannelExpect(timeout time.Duration, expects ...string) string
+	ReadChannelTiming(timeout time.Duration) string
+	ClearChannel()
+	CheckSelf() bool
+	GetSSHBrand() string
+	DetectBrand() BrandDetection
+	GetBanner() string
+	GetMOTD() string
+	GetPrompt() string
+	GetPrivilegeMode() string
+	IsEnabled() bool
+	Enable(brand, enablePassword string) error
+	GetLastUseTime() time.Time
+	UpdateLastUseTime()
+	IsClosed() bool
+	Close()
+	shell() *shellSession
+}
+
+/**
+ * 交互式shell的公共部分，包含输入输出管道及其读写协程，基于管道实现读写指令、识别品牌、提权等操作，由具体的传输方式（ssh、telnet）嵌入
+ * @attr   in:绑定了设备输入的管道，out:绑定了设备输出的管道，lineEnding:指令的换行符，done:关闭时通知读写协程退出，
+ *         muxWaitGroup:等待读写协程退出，pool:所属的设备连接池，banner:登录前的banner，motd:登录后第一个提示符之前的输出，
+ *         serverVersion:ssh服务端的版本号，prompt:登录完成时的提示符，detection:品牌识别的结果，lastUseTime:最后的使用时间（会被自动清理协程读取，由timeLocker保护）
+ * @author shenbowei
+ */
+type shellSession struct {
+	config        *sessionConfig
+	logger        Logger
+	pool          *devicePool
+	in            chan string
+	out           chan string
+	lineEnding    string
+	done          chan struct{}
+	closeOnce     sync.Once
+	muxWaitGroup  sync.WaitGroup
+	brand         string
+	banner        string
+	motd          string
+	serverVersion string
+	prompt        string
+	detection     BrandDetection
+	enabled       bool
+	lastUseTime   time.Time
+	timeLocker    sync.RWMutex
+}
+
+/**
+ * 初始化shellSession的配置和管道
+ * @param config session的配置, lineEnding 指令的换行符
+ * @author shenbowei
+ */
+func (this *shellSession) initShellSession(config *sessionConfig, lineEnding string) {
+	this.config = config
+	this.logger = config.logger
+	this.lineEnding = lineEnding
+	this.in = make(chan string, 1024)
+	this.out = make(chan string, 1024)
+	this.done = make(chan struct{})
+}
+
+func (this *shellSession) shell() *shellSession {
+	return this
+}
+
+/**
+ * 启动读写协程，分别将输入管道的指令写入设备，将设备的输出写入输出管道
+ * @param w 设备的输入, r 设备的输出
+ * @author shenbowei
+ */
+func (this *shellSession) startMux(w io.Writer, r io.Reader) {
+	//读写协程都会在done关闭（Close）后退出，Close会等待它们退出后再返回
+	this.muxWaitGroup.Add(2)
+	go func() {
+		defer func() {
+			this.muxWaitGroup.Done()
+			if err := recover(); err != nil {
+				this.logger.Error("Goroutine muxShell write err:%s", err)
+			}
+		}()
+		for {
+			select {
+			case cmd := <-this.in:
+				_, err := w.Write([]byte(cmd + this.lineEnding))
+				if err != nil {
+					this.logger.Debug("Writer write err:%s", err.Error())
+					return
+				}
+			case <-this.done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer func() {
+			this.muxWaitGroup.Done()
+			if err := recover(); err != nil {
+				this.logger.Error("Goroutine muxShell read err:%s", err)
+			}
+		}()
+		var (
+			buf [65 * 1024]byte
+			t   int
+		)
+		for {
+			n, err := r.Read(buf[t:])
+			if err != nil {
+				this.logger.Debug("Reader read err:%s", err.Error())
+				return
+			}
+			t += n
+			select {
+			case this.out <- string(buf[:t]):
+			case <-this.done:
+				return
+			}
+			t = 0
+		}
+	}()
+}
+
+/**
+ * 等待读写协程退出，最多等待dialTimeout
+ * @author shenbowei
+ */
+func (this *shellSession) waitMux() {
+	muxDone := make(chan struct{})
+	go func() {
+		this.muxWaitGroup.Wait()
+		close(muxDone)
+	}()
+	select {
+	case <-muxDone:
+	case <-time.After(this.config.dialTimeout):
+		this.logger.Error("Session Close: wait for mux goroutines timeout")
+	}
+}
+
+/**
+ * 获取最后的使用时间
+ * @return time.Time
+ * @author shenbowei
+ */
+func (this *shellSession) GetLastUseTime() time.Time {
+	this.timeLocker.RLock()
+	defer this.timeLocker.RUnlock()
+	return this.lastUseTime
+}
+
+/**
+ * 更新最后的使用时间
+ * @author shenbowei
+ */
+func (this *shellSession) UpdateLastUseTime() {
+	this.timeLocker.Lock()
+	defer this.timeLocker.Unlock()
+	this.lastUseTime = time.Now()
+}
+
+/**
+ * 检查当前session是否可用
+ * @return true:可用，false:不可用
+ * @author shenbowei
+ */
+func (this *shellSession) CheckSelf() bool {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("SSHSession CheckSelf err:%s", err)
+		}
+	}()
+	if this.IsClosed() {
+		return false
+	}
+
+	this.WriteChannel("\n")
+	result := this.ReadChannelExpect(2*this.config.promptTimeout, "#", ">", "]")
+	if strings.Contains(result, "#") ||
+		strings.Contains(result, ">") ||
+		strings.Contains(result, "]") {
+		return true
+	}
+	return false
+}
+
+/**
+ * 获取当前SSH到的交换机的品牌，识别过程见DetectBrand
+ * @return string （huawei,h3c,cisco）
+ * @author shenbowei
+ */
+func (this *shellSession) GetSSHBrand() string {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("SSHSession GetSSHBrand err:%s", err)
+		}
+	}()
+	return this.DetectBrand().Brand
+}
+
+/**
+ * 获取登录前的banner：ssh为认证前服务端发送的banner（SSH_MSG_USERAUTH_BANNER），telnet为用户名提示之前的输出
+ * @return banner，没有时返回""
+ * @author shenbowei
+ */
+func (this *shellSession) GetBanner() string {
+	return this.banner
+}
+
+/**
+ * 获取登录后的MOTD，即登录完成后、第一个提示符之前设备输出的内容
+ * @return MOTD，没有时返回""
+ * @author shenbowei
+ */
+func (this *shellSession) GetMOTD() string {
+	return this.motd
+}
+
+/**
+ * 获取当前会话的提示符（输出的最后一个非空行），如"<HUAWEI>"、"Switch#"
+ * @return 提示符，获取不到返回""
+ * @author shenbowei
+ */
+func (this *shellSession) GetPrompt() string {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("SSHSession GetPrompt err:%s", err)
+		}
+	}()
+	this.ClearChannel()
+	this.WriteChannel("")
+	result := this.ReadChannelExpect(this.config.promptTimeout, "#", ">", "]")
+	return lastPromptLine(result)
+}
+
+/**
+ * 根据提示符获取当前会话的权限模式
+ * @return USER_MODE, PRIVILEGED_MODE, CONFIG_MODE 或 UNKNOWN_MODE
+ * @author shenbowei
+ */
+func (this *shellSession) GetPrivilegeMode() string {
+	return parsePrivilegeMode(this.GetPrompt())
+}
+
+/**
+ * 判断当前会话是否已经执行过提权（或登录后即处于特权模式）
+ * @return true:已提权，false:未提权
+ * @author shenbowei
+ */
+func (this *shellSession) IsEnabled() bool {
+	return this.enabled
+}
+
+/**
+ * 提升会话的权限，cisco执行enable，华为/h3c执行super，并自动应答密码提示
+ * @param brand 交换机品牌, enablePassword 提权密码（可为空）
+ * @return 执行的错误
+ * @author shenbowei
+ */
+func (this *shellSession) Enable(brand, enablePassword string) error {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("SSHSession Enable err:%s", err)
+		}
+	}()
+	enableCmd := "enable"
+	if brand == HUAWEI || brand == H3C {
+		enableCmd = "super"
+	} else if parsePrivilegeMode(this.GetPrompt()) != USER_MODE {
+		//cisco设备已经处于特权或配置模式，无需再enable
+		this.enabled = true
+		return nil
+	}
+	this.ClearChannel()
+	this.WriteChannel(enableCmd)
+	result := this.ReadChannelExpect(this.config.promptTimeout, "assword", "#", ">", "]")
+	if strings.Contains(strings.ToLower(result), "password") {
+		this.writeSecret(enablePassword)
+		result += this.ReadChannelExpect(this.config.promptTimeout, "#", ">", "]")
+	}
+	if isEnableFailed(result) {
+		this.logger.Error("SSHSession %s failed:%s", enableCmd, result)
+		return errors.New("enable failed: " + lastPromptLine(result))
+	}
+	if brand != HUAWEI && brand != H3C && parsePrivilegeMode(lastPromptLine(result)) == USER_MODE {
+		return errors.New("enable failed: still in user mode")
+	}
+	this.enabled = true
+	return nil
+}
+
+/**
+ * 判断session是否已经关闭
+ * @return true:已关闭
+ * @author shenbowei
+ */
+func (this *shellSession) IsClosed() bool {
+	select {
+	case <-this.done:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * 向管道写入执行指令
+ * @param cmds... 执行的命令（可多条）
+ * @author shenbowei
+ */
+func (this *shellSession) WriteChannel(cmds ...string) {
+	this.logger.Debug("WriteChannel <cmds=%v>", cmds)
+	for _, cmd := range cmds {
+		select {
+		case this.in <- cmd:
+		case <-this.done:
+			return
+		}
+	}
+}
+
+/**
+ * 向管道写入密码等敏感内容，不会打印到日志中
+ * @param secret 需要写入的敏感内容
+ * @author shenbowei
+ */
+func (this *shellSession) writeSecret(secret string) {
+	this.logger.Debug("WriteChannel <secret=******>")
+	select {
+	case this.in <- secret:
+	case <-this.done:
+	}
+}
+
+/**
+ * 从输出管道中读取设备返回的执行结果，若输出流间隔超过timeout或者包含expects中的字符便会返回
+ * @param timeout 从设备读取不到数据时的超时等待时间（超过超时等待时间即认为设备的响应内容已经被完全读取）, expects...:期望得到的字符（可多个），得到便返回
+ * @return 从输出管道读出的返回结果
+ * @author shenbowei
+ */
+func (this *shellSession) ReadChannelExpect(timeout time.Duration, expects ...string) string {
+	this.logger.Debug("ReadChannelExpect <wait timeout = %d>", timeout/time.Millisecond)
+	output := ""
+	isDelayed := false
+	for i := 0; i < 300; i++ { //最多从设备读取300次，避免方法无法返回
+		time.Sleep(time.Millisecond * 100) //每次睡眠0.1秒，使out管道中的数据能积累一段时间，避免过早触发default等待退出
+		newData := this.readChannelData()
+		this.logger.Debug("ReadChannelExpect: read chanel buffer: %s", newData)
+		if newData != "" {
+			output += newData
+			isDelayed = false
+			continue
+		}
+		for _, expect := range expects {
+			if strings.Contains(output, expect) {
+				return output
+			}
+		}
+		//如果之前已经等待过一次，则直接退出，否则就等待一次超时再重新读取内容
+		if !isDelayed {
+			this.logger.Debug("ReadChannelExpect: delay for timeout")
+			time.Sleep(timeout)
+			isDelayed = true
+		} else {
+			return output
+		}
+	}
+	return output
+}
+
+/**
+ * 从输出管道中读取设备返回的执行结果，若输出流间隔超过timeout便会返回
+ * @param timeout 从设备读取不到数据时的超时等待时间（超过超时等待时间即认为设备的响应内容已经被完全读取）
+ * @return 从输出管道读出的返回结果
+ * @author shenbowei
+ */
+func (this *shellSession) ReadChannelTiming(timeout time.Duration) string {
+	this.logger.Debug("ReadChannelTiming <wait timeout = %d>", timeout/time.Millisecond)
+	output := ""
+	isDelayed := false
+
+	for i := 0; i < 300; i++ { //最多从设备读取300次，避免方法无法返回
+		time.Sleep(time.Millisecond * 100) //每次睡眠0.1秒，使out管道中的数据能积累一段时间，避免过早触发default等待退出
+		newData := this.readChannelData()
+		this.logger.Debug("ReadChannelTiming: read chanel buffer: %s", newData)
+		if newData != "" {
+			output += newData
+			isDelayed = false
+			continue
+		}
+		//如果之前已经等待过一次，则直接退出，否则就等待一次超时再重新读取内容
+		if !isDelayed {
+			this.logger.Debug("ReadChannelTiming: delay for timeout.")
+			time.Sleep(timeout)
+			isDelayed = true
+		} else {
+			return output
+		}
+	}
+	return output
+}
+
+/**
+ * 清除管道缓存的内容，避免管道中上次未读取的残余内容影响下次的结果
+ */
+func (this *shellSession) ClearChannel() {
+	//time.Sleep(time.Millisecond * 100)
+	this.readChannelData()
+}
+
+/**
+ * 清除管道缓存的内容，避免管道中上次未读取的残余内容影响下次的结果
+ */
+func (this *shellSession) readChannelData() string {
+	output := ""
+	for {
+		time.Sleep(time.Millisecond * 100)
+		select {
+		case channelData := <-this.out:
+			output += channelData
+		default:
+			return output
+		}
+	}
+}
+
+/**
+ * 获取输出中最后一个提示行之前的内容，用于截取登录前的banner和登录后的MOTD
+ * @param output 设备的输出
+ * @return 去除首尾空白后的内容
+ * @author shenbowei
+ */
+func textBeforePrompt(output string) string {
+	output = strings.TrimRight(output, " \t\r\n")
+	index := strings.LastIndexAny(output, "\r\n")
+	if index < 0 {
+		return ""
+	}
+	return strings.TrimSpace(output[:index])
+}
+
+/**
+ * 获取输出中的最后一个非空行，即设备的提示符
+ * @param output 设备的输出
+ * @return 去除首尾空白后的提示符
+ * @author shenbowei
+ */
+func lastPromptLine(output string) string {
+	lines := strings.Split(strings.Replace(output, "\r", "\n", -1), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+/**
+ * 根据提示符判断设备的权限模式
+ * @param prompt 设备的提示符
+ * @return USER_MODE, PRIVILEGED_MODE, CONFIG_MODE 或 UNKNOWN_MODE
+ * @author shenbowei
+ */
+func parsePrivilegeMode(prompt string) string {
+	switch {
+	case strings.HasPrefix(prompt, "<") && strings.HasSuffix(prompt, ">"):
+		return USER_MODE
+	case strings.HasPrefix(prompt, "[") && strings.HasSuffix(prompt, "]"):
+		return CONFIG_MODE
+	case strings.HasSuffix(prompt, ")#") && strings.Contains(prompt, "(config"):
+		return CONFIG_MODE
+	case strings.HasSuffix(prompt, "#"):
+		return PRIVILEGED_MODE
+	case strings.HasSuffix(prompt, ">"):
+		return USER_MODE
+	}
+	return UNKNOWN_MODE
+}
+
+/**
+ * 判断enable/super的输出是否表示提权失败
+ * @param result enable/super指令的输出
+ * @return true:提权失败
+ * @author shenbowei
+ */
+func isEnableFailed(result string) bool {
+	lowerResult := strings.ToLower(result)
+	for _, failure := range []string{"% bad", "% access denied", "% no password set", "error:", "failed", "authentication fail"} {
+		if strings.Contains(lowerResult, failure) {
+			return true
+		}
+	}
+	return false
+}
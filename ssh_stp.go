@@ -0,0 +1,281 @@
+package ssh
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 生成树端口的角色和状态，cisco的blocking状态规范化为STP_DISCARDING，其他状态为设备输出的小写形式（如learning、listening）
+const (
+	STP_ROLE_ROOT       = "root"
+	STP_ROLE_DESIGNATED = "designated"
+	STP_ROLE_ALTERNATE  = "alternate"
+	STP_ROLE_BACKUP     = "backup"
+	STP_ROLE_MASTER     = "master"
+	STP_ROLE_DISABLED   = "disabled"
+	STP_FORWARDING      = "forwarding"
+	STP_DISCARDING      = "discarding"
+)
+
+/**
+ * 生成树端口
+ * @attr Interface:规范化后的接口名，Role:端口角色（STP_ROLE_ROOT等），State:端口状态（STP_FORWARDING、STP_DISCARDING等）
+ * @author shenbowei
+ */
+type STPPort struct {
+	Interface string
+	Role      string
+	State     string
+}
+
+/**
+ * 生成树实例（MSTP的CIST为实例0，cisco PVST的实例号为VLAN号）
+ * @attr ID:实例号，BridgePriority/BridgeAddress:本桥的优先级和规范化MAC地址，RootPriority/RootAddress:根桥的优先级和规范化MAC地址，
+ *       IsRoot:本桥是否为根桥，RootPort:根端口（本桥为根桥时为""），TopologyChanges:拓扑变化次数，TCReceived:收到的TC/TCN报文数（cisco为0），
+ *       LastTopologyChange:距最近一次拓扑变化的时长，LastTopologyChangePort:最近一次拓扑变化的接口（设备没有输出时为""），Ports:端口
+ * @author shenbowei
+ */
+type STPInstance struct {
+	ID                     int
+	BridgePriority         int
+	BridgeAddress          string
+	RootPriority           int
+	RootAddress            string
+	IsRoot                 bool
+	RootPort               string
+	TopologyChanges        int
+	TCReceived             int
+	LastTopologyChange     time.Duration
+	LastTopologyChangePort string
+	Ports                  []STPPort
+}
+
+/**
+ * 设备的生成树信息
+ * @attr Mode:生成树模式（stp、rstp、mstp，cisco为pvst、rapid-pvst、mstp），Instances:按实例号排序的实例
+ * @author shenbowei
+ */
+type SpanningTree struct {
+	Mode      string
+	Instances []STPInstance
+}
+
+// 获取生成树信息时各品牌执行的指令
+var (
+	HuaweiSTPCmd      = "display stp"
+	HuaweiSTPBriefCmd = "display stp brief"
+	CiscoSTPCmd       = "show spanning-tree detail"
+)
+
+/**
+ * 获取设备的生成树信息
+ * @param device 设备的身份信息
+ * @return 生成树信息，执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetSpanningTree(device Device) (SpanningTree, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiSTPCmd, HuaweiSTPBriefCmd},
+		H3C:    {HuaweiSTPCmd, HuaweiSTPBriefCmd},
+		CISCO:  {CiscoSTPCmd},
+	})
+	if err != nil {
+		return SpanningTree{}, err
+	}
+	if brand == CISCO {
+		return parseCiscoSpanningTree(outputs[CiscoSTPCmd]), nil
+	}
+	return parseHuaweiSpanningTree(brand, outputs[HuaweiSTPCmd], outputs[HuaweiSTPBriefCmd]), nil
+}
+
+// 华为/h3c brief输出中端口角色的缩写
+var stpRoleAbbreviations = map[string]string{
+	"root": STP_ROLE_ROOT, "desi": STP_ROLE_DESIGNATED, "alte": STP_ROLE_ALTERNATE,
+	"back": STP_ROLE_BACKUP, "mast": STP_ROLE_MASTER, "disa": STP_ROLE_DISABLED,
+}
+
+var (
+	bridgeIDRegexp         = regexp.MustCompile(`^(\d+)\.([0-9A-Fa-f]{4}[-.][0-9A-Fa-f]{4}[-.][0-9A-Fa-f]{4})`)
+	huaweiSTPSectionRegexp = regexp.MustCompile(`\[(?:CIST|(?:MSTI|VLAN)\s*(\d+)) Global Info\](?:\[Mode (\w+)\])?`)
+	stpDaysRegexp          = regexp.MustCompile(`(\d+)\s*days?`)
+)
+
+/**
+ * 解析"32768.4c1f-cc11-2233"形式的桥ID，之后的根路径开销等内容被忽略
+ * @param text 桥ID的文本
+ * @return 优先级，规范化后的MAC地址，是否解析成功
+ * @author shenbowei
+ */
+func parseBridgeID(text string) (int, string, bool) {
+	match := bridgeIDRegexp.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return 0, "", false
+	}
+	priority, _ := strconv.Atoi(match[1])
+	return priority, NormalizeMAC(match[2]), true
+}
+
+/**
+ * 获取指定实例号的实例，不存在时按实例号的顺序插入
+ * @param id 实例号
+ * @return 实例
+ * @author shenbowei
+ */
+func (this *SpanningTree) instance(id int) *STPInstance {
+	index := sort.Search(len(this.Instances), func(i int) bool { return this.Instances[i].ID >= id })
+	if index == len(this.Instances) || this.Instances[index].ID != id {
+		this.Instances = append(this.Instances, STPInstance{})
+		copy(this.Instances[index+1:], this.Instances[index:])
+		this.Instances[index] = STPInstance{ID: id, Ports: make([]STPPort, 0)}
+	}
+	return &this.Instances[index]
+}
+
+/**
+ * 根据端口角色和桥ID补全各实例的根端口和是否为根桥
+ * @author shenbowei
+ */
+func (this *SpanningTree) complete() {
+	for i := range this.Instances {
+		instance := &this.Instances[i]
+		if instance.RootAddress != "" && instance.RootAddress == instance.BridgeAddress && instance.RootPriority == instance.BridgePriority {
+			instance.IsRoot = true
+		}
+		for _, port := range instance.Ports {
+			if port.Role == STP_ROLE_ROOT {
+				instance.RootPort = port.Interface
+			}
+		}
+	}
+}
+
+/**
+ * 解析华为/h3c的生成树信息。display stp中"[CIST Global Info][Mode MSTP]"、"[MSTI 1 Global Info]"段落为各实例的全局信息，
+ * 其中CIST的根桥为"CIST Root/ERPC"（h3c为"Root ID/ERPC"），MSTI的根桥为"MSTI RegRoot/IRPC"（h3c为"RegRoot ID/IRPC"，"Master Bridge"为总桥而不是本桥），
+ * "----[Port1(GigabitEthernet0/0/1)][FORWARDING]----"之后的端口详细信息被忽略；端口角色和状态从display stp brief中获取
+ * @param brand 设备品牌, output display stp的输出, brief display stp brief的输出
+ * @return 生成树信息
+ * @author shenbowei
+ */
+func parseHuaweiSpanningTree(brand, output, brief string) SpanningTree {
+	stp := SpanningTree{Instances: make([]STPInstance, 0)}
+	var current *STPInstance
+	msti := false
+	for _, line := range outputLines(output) {
+		if match := huaweiSTPSectionRegexp.FindStringSubmatch(line); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			current, msti = stp.instance(id), match[1] != ""
+			if match[2] != "" && stp.Mode == "" {
+				stp.Mode = strings.ToLower(match[2])
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "----[Port") {
+			current = nil
+		}
+		index := strings.Index(line, ":")
+		if current == nil || index < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:index])), lineValue(line)
+		switch {
+		case strings.Contains(key, "root") && !strings.Contains(key, "rootport") && strings.Contains(key, "regroot") == msti:
+			if priority, address, ok := parseBridgeID(value); ok {
+				current.RootPriority, current.RootAddress = priority, address
+			}
+		case strings.Contains(key, "bridge") && !strings.Contains(key, "master"):
+			if priority, address, ok := parseBridgeID(value); ok {
+				current.BridgePriority, current.BridgeAddress = priority, address
+			}
+		case key == "number of tc":
+			current.TopologyChanges, _ = strconv.Atoi(value)
+		case key == "tc or tcn received":
+			current.TCReceived, _ = strconv.Atoi(value)
+		case key == "time since last tc":
+			current.LastTopologyChange = parseShortDuration(stpDaysRegexp.ReplaceAllString(value, "${1}d"))
+		case key == "last tc occurred":
+			current.LastTopologyChangePort = NormalizeInterfaceName(brand, value)
+		}
+	}
+
+	for _, line := range outputLines(brief) {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !isDigits(fields[0]) {
+			continue
+		}
+		role, ok := stpRoleAbbreviations[strings.ToLower(fields[2])]
+		if !ok {
+			continue
+		}
+		id, _ := strconv.Atoi(fields[0])
+		instance := stp.instance(id)
+		instance.Ports = append(instance.Ports, STPPort{Interface: NormalizeInterfaceName(brand, fields[1]), Role: role,
+			State: strings.ToLower(fields[3])})
+	}
+	stp.complete()
+	return stp
+}
+
+// cisco的生成树协议对应的模式
+var ciscoSTPModes = map[string]string{"ieee": "pvst", "rstp": "rapid-pvst", "mstp": "mstp"}
+
+var (
+	ciscoSTPInstanceRegexp   = regexp.MustCompile(`^\s*(?:VLAN|MST)0*(\d+) is executing the (\S+) compatible Spanning Tree protocol`)
+	ciscoSTPBridgeRegexp     = regexp.MustCompile(`Bridge Identifier has priority (\d+), sysid (\d+), address (\S+)`)
+	ciscoSTPRootRegexp       = regexp.MustCompile(`Current root has priority (\d+), address (\S+)`)
+	ciscoSTPChangeRegexp     = regexp.MustCompile(`Number of topology changes (\d+) last change occurred (\S+) ago`)
+	ciscoSTPChangeFromRegexp = regexp.MustCompile(`^\s*from (\S+)`)
+	ciscoSTPPortRegexp       = regexp.MustCompile(`^\s*Port \d+ \((\S+)\) of \S+ is (\S+) (\S+)`)
+)
+
+/**
+ * 解析cisco的生成树信息（show spanning-tree detail），每个实例以"VLAN0001 is executing the rstp compatible Spanning Tree protocol"开始，
+ * 桥的优先级包含sysid（与根桥的优先级一致），端口为"Port 1 (GigabitEthernet1/0/1) of VLAN0001 is designated forwarding"
+ * @param output 指令的输出
+ * @return 生成树信息
+ * @author shenbowei
+ */
+func parseCiscoSpanningTree(output string) SpanningTree {
+	stp := SpanningTree{Instances: make([]STPInstance, 0)}
+	var current *STPInstance
+	for _, line := range outputLines(output) {
+		if match := ciscoSTPInstanceRegexp.FindStringSubmatch(line); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			current = stp.instance(id)
+			if stp.Mode == "" {
+				stp.Mode = ciscoSTPModes[match[2]]
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if match := ciscoSTPBridgeRegexp.FindStringSubmatch(line); match != nil {
+			priority, _ := strconv.Atoi(match[1])
+			sysid, _ := strconv.Atoi(match[2])
+			current.BridgePriority, current.BridgeAddress = priority+sysid, NormalizeMAC(match[3])
+		} else if match := ciscoSTPRootRegexp.FindStringSubmatch(line); match != nil {
+			current.RootPriority, _ = strconv.Atoi(match[1])
+			current.RootAddress = NormalizeMAC(match[2])
+		} else if strings.Contains(line, "We are the root of the spanning tree") {
+			current.RootPriority, current.RootAddress, current.IsRoot = current.BridgePriority, current.BridgeAddress, true
+		} else if match := ciscoSTPChangeRegexp.FindStringSubmatch(line); match != nil {
+			current.TopologyChanges, _ = strconv.Atoi(match[1])
+			current.LastTopologyChange = parseShortDuration(match[2])
+		} else if match := ciscoSTPChangeFromRegexp.FindStringSubmatch(line); match != nil {
+			current.LastTopologyChangePort = NormalizeInterfaceName(CISCO, match[1])
+		} else if match := ciscoSTPPortRegexp.FindStringSubmatch(line); match != nil {
+			state := strings.ToLower(match[3])
+			if state == "blocking" {
+				state = STP_DISCARDING
+			}
+			current.Ports = append(current.Ports, STPPort{Interface: NormalizeInterfaceName(CISCO, match[1]),
+				Role: strings.ToLower(match[2]), State: state})
+		}
+	}
+	stp.complete()
+	return stp
+}
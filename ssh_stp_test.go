@@ -0,0 +1,161 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const huaweiSTPSample = `-------[CIST Global Info][Mode MSTP]-------
+CIST Bridge         :32768.4c1f-cc11-2233
+Config Times        :Hello 2s MaxAge 20s FwDly 15s MaxHop 20
+Active Times        :Hello 2s MaxAge 20s FwDly 15s MaxHop 20
+CIST Root/ERPC      :4096.0011-2233-4400 / 20000
+CIST RegRoot/IRPC   :32768.4c1f-cc11-2233 / 0
+CIST RootPortId     :128.24
+BPDU-Protection     :Disabled
+TC or TCN received  :15
+TC count per hello  :0
+STP Converge Mode   :Normal
+Time since last TC  :1 days 2h:10m:5s
+Number of TC        :27
+Last TC occurred    :GE0/0/24
+----[Port1(GigabitEthernet0/0/1)][FORWARDING]----
+ Port Protocol       :Enabled
+ Port Role           :Designated Port
+ Designated Bridge/Port   :32768.4c1f-cc11-2233 / 128.1
+-------[MSTI 1 Global Info]-------
+MSTI Bridge ID      :4096.4c1f-cc11-2233
+MSTI RegRoot/IRPC   :4096.4c1f-cc11-2233 / 0
+MSTI RootPortId     :0.0
+MSTI Root Type      :Primary root
+Master Bridge       :4096.0011-2233-4400
+TC received         :3
+`
+
+const huaweiSTPBriefSample = ` MSTID  Port                        Role  STP State     Protection
+   0    GigabitEthernet0/0/1        DESI  FORWARDING      NONE
+   0    GigabitEthernet0/0/24       ROOT  FORWARDING      NONE
+   0    Eth-Trunk1                  ALTE  DISCARDING      NONE
+   1    GigabitEthernet0/0/24       MAST  FORWARDING      NONE
+`
+
+const h3cSTPSample = `-------[CIST Global Info][Mode RSTP]-------
+ Bridge ID           : 32768.0cda-41b1-2345
+ Bridge times        : Hello 2s MaxAge 20s FwdDelay 15s MaxHops 20
+ Root ID/ERPC        : 32768.0cda-41b1-2345, 0
+ RegRoot ID/IRPC     : 32768.0cda-41b1-2345, 0
+ RootPort ID         : 0.0
+ BPDU-Protection     : Disabled
+ TC or TCN received  : 12
+ Time since last TC  : 0 days 1h:2m:3s
+`
+
+const h3cSTPBriefSample = ` MST ID   Port                                Role  STP State   Protection
+ 0        GigabitEthernet1/0/1                DESI  FORWARDING  NONE
+ 0        XGE1/0/49                           DESI  FORWARDING  NONE
+`
+
+const ciscoSTPSample = `
+ VLAN0001 is executing the rstp compatible Spanning Tree protocol
+  Bridge Identifier has priority 32768, sysid 1, address 0011.2233.4455
+  Configured hello time 2, max age 20, forward delay 15, transmit hold-count 6
+  Current root has priority 4097, address 0011.2233.4400
+  Root port is 49 (GigabitEthernet1/0/49), cost of root path is 4
+  Topology change flag not set, detected flag not set
+  Number of topology changes 5 last change occurred 1d02h ago
+          from GigabitEthernet1/0/49
+  Times:  hold 1, topology change 35, notification 2
+          hello 2, max age 20, forward delay 15
+
+ Port 1 (GigabitEthernet1/0/1) of VLAN0001 is designated forwarding
+   Port path cost 4, Port priority 128, Port Identifier 128.1.
+   Designated root has priority 4097, address 0011.2233.4400
+
+ Port 49 (GigabitEthernet1/0/49) of VLAN0001 is root forwarding
+   Port path cost 4, Port priority 128, Port Identifier 128.49.
+
+ Port 56 (Port-channel1) of VLAN0001 is alternate blocking
+   Port path cost 3, Port priority 128, Port Identifier 128.56.
+
+ VLAN0010 is executing the rstp compatible Spanning Tree protocol
+  Bridge Identifier has priority 4096, sysid 10, address 0011.2233.4455
+  Configured hello time 2, max age 20, forward delay 15, transmit hold-count 6
+  We are the root of the spanning tree
+  Topology change flag not set, detected flag not set
+  Number of topology changes 2 last change occurred 00:10:05 ago
+          from GigabitEthernet1/0/6
+
+ Port 6 (GigabitEthernet1/0/6) of VLAN0010 is designated forwarding
+   Port path cost 4, Port priority 128, Port Identifier 128.6.
+`
+
+func TestParseHuaweiSpanningTree(t *testing.T) {
+	stp := parseHuaweiSpanningTree(HUAWEI, huaweiSTPSample, huaweiSTPBriefSample)
+	if stp.Mode != "mstp" || len(stp.Instances) != 2 {
+		t.Fatalf("huawei stp:%+v", stp)
+	}
+	if !reflect.DeepEqual(stp.Instances[0], STPInstance{ID: 0, BridgePriority: 32768, BridgeAddress: "4c:1f:cc:11:22:33",
+		RootPriority: 4096, RootAddress: "00:11:22:33:44:00", RootPort: "GigabitEthernet0/0/24", TopologyChanges: 27, TCReceived: 15,
+		LastTopologyChange: 26*time.Hour + 10*time.Minute + 5*time.Second, LastTopologyChangePort: "GigabitEthernet0/0/24",
+		Ports: []STPPort{
+			{Interface: "GigabitEthernet0/0/1", Role: STP_ROLE_DESIGNATED, State: STP_FORWARDING},
+			{Interface: "GigabitEthernet0/0/24", Role: STP_ROLE_ROOT, State: STP_FORWARDING},
+			{Interface: "Eth-Trunk1", Role: STP_ROLE_ALTERNATE, State: STP_DISCARDING},
+		}}) {
+		t.Errorf("huawei instance 0:%+v", stp.Instances[0])
+	}
+	//MSTI的根桥为域根
+	instance := stp.Instances[1]
+	if instance.ID != 1 || !instance.IsRoot || instance.RootPriority != 4096 || instance.RootPort != "" ||
+		!reflect.DeepEqual(instance.Ports, []STPPort{{Interface: "GigabitEthernet0/0/24", Role: STP_ROLE_MASTER, State: STP_FORWARDING}}) {
+		t.Errorf("huawei instance 1:%+v", instance)
+	}
+
+	stp = parseHuaweiSpanningTree(H3C, h3cSTPSample, h3cSTPBriefSample)
+	if stp.Mode != "rstp" || len(stp.Instances) != 1 {
+		t.Fatalf("h3c stp:%+v", stp)
+	}
+	instance = stp.Instances[0]
+	if !instance.IsRoot || instance.BridgeAddress != "0c:da:41:b1:23:45" || instance.TCReceived != 12 ||
+		instance.LastTopologyChange != time.Hour+2*time.Minute+3*time.Second || instance.Ports[1].Interface != "Ten-GigabitEthernet1/0/49" {
+		t.Errorf("h3c instance 0:%+v", instance)
+	}
+}
+
+func TestParseCiscoSpanningTree(t *testing.T) {
+	stp := parseCiscoSpanningTree(ciscoSTPSample)
+	if stp.Mode != "rapid-pvst" || len(stp.Instances) != 2 {
+		t.Fatalf("cisco stp:%+v", stp)
+	}
+	if !reflect.DeepEqual(stp.Instances[0], STPInstance{ID: 1, BridgePriority: 32769, BridgeAddress: "00:11:22:33:44:55",
+		RootPriority: 4097, RootAddress: "00:11:22:33:44:00", RootPort: "GigabitEthernet1/0/49", TopologyChanges: 5,
+		LastTopologyChange: 26 * time.Hour, LastTopologyChangePort: "GigabitEthernet1/0/49",
+		Ports: []STPPort{
+			{Interface: "GigabitEthernet1/0/1", Role: STP_ROLE_DESIGNATED, State: STP_FORWARDING},
+			{Interface: "GigabitEthernet1/0/49", Role: STP_ROLE_ROOT, State: STP_FORWARDING},
+			{Interface: "Port-channel1", Role: STP_ROLE_ALTERNATE, State: STP_DISCARDING},
+		}}) {
+		t.Errorf("cisco instance 1:%+v", stp.Instances[0])
+	}
+	instance := stp.Instances[1]
+	if instance.ID != 10 || !instance.IsRoot || instance.RootPriority != 4106 || instance.RootPort != "" ||
+		instance.LastTopologyChange != 10*time.Minute+5*time.Second || len(instance.Ports) != 1 {
+		t.Errorf("cisco instance 10:%+v", instance)
+	}
+}
+
+func TestSessionManagerGetSpanningTree(t *testing.T) {
+	fake := newFakeSwitch(t, "<HW1>", map[string]string{
+		HuaweiSTPCmd:      huaweiSTPSample,
+		HuaweiSTPBriefCmd: huaweiSTPBriefSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	stp, err := manager.GetSpanningTree(NewDevice("admin", "admin", fake.addr(), HUAWEI))
+	if err != nil || stp.Mode != "mstp" || len(stp.Instances) != 2 || stp.Instances[0].RootPort != "GigabitEthernet0/0/24" {
+		t.Errorf("GetSpanningTree stp:%+v err:%v", stp, err)
+	}
+}
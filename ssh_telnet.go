@@ -0,0 +1,192 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+)
+
+// telnet协议的指令和选项（RFC 854/857/858）
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetOptEcho = 1
+	telnetOptSGA  = 3
+)
+
+/**
+ * 基于telnet的设备会话，与SSHSession实现相同的Session接口，用于只开启了telnet的老旧设备
+ * @attr conn:telnet的tcp连接
+ * @author shenbowei
+ */
+type TelnetSession struct {
+	shellSession
+	conn net.Conn
+}
+
+/**
+ * 创建一个TelnetSession，连接设备并完成登录（应答Username/Password提示）
+ * @param user 登录的用户名, password 密码, ipPort 交换机的ip和telnet端口
+ * @return 登录后的TelnetSession，执行的错误
+ * @author shenbowei
+ */
+func NewTelnetSession(user, password, ipPort string) (*TelnetSession, error) {
+	return newTelnetSession(nil, user, password, ipPort, newSessionConfig())
+}
+
+/**
+ * 使用指定的配置创建一个TelnetSession
+ * @param dialer 建立tcp连接的Dialer（为nil时使用配置的Dialer）, user 登录的用户名, password 密码, ipPort 交换机的ip和telnet端口, config session的配置
+ * @return 登录后的TelnetSession，执行的错误
+ * @author shenbowei
+ */
+func newTelnetSession(dialer Dialer, user, password, ipPort string, config *sessionConfig) (*TelnetSession, error) {
+	telnetSession, err := dialTelnetSession(dialer, ipPort, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := telnetSession.login(user, password, config.promptTimeout); err != nil {
+		config.logger.Error("NewTelnetSession login error:%s", err.Error())
+		telnetSession.Close()
+		return nil, err
+	}
+	telnetSession.UpdateLastUseTime()
+	return telnetSession, nil
+}
+
+/**
+ * 建立telnet连接并启动读写协程，不进行登录
+ * @param dialer 建立tcp连接的Dialer（为nil时使用配置的Dialer）, ipPort 交换机的ip和telnet端口, config session的配置
+ * @return 未登录的TelnetSession，执行的错误
+ * @author shenbowei
+ */
+func dialTelnetSession(dialer Dialer, ipPort string, config *sessionConfig) (*TelnetSession, error) {
+	config.logger.Debug("<Test> Begin telnet connect")
+	conn, err := dialConn(dialer, ipPort, config)
+	if err != nil {
+		config.logger.Error("Telnet Dial err:%s", err.Error())
+		return nil, err
+	}
+	config.logger.Debug("<Test> End telnet connect")
+	telnetSession := new(TelnetSession)
+	telnetSession.initShellSession(config, "\r\n")
+	telnetSession.conn = conn
+	telnetSession.startMux(&telnetWriter{conn: conn}, &telnetReader{conn: conn, reader: bufio.NewReader(conn)})
+	return telnetSession, nil
+}
+
+/**
+ * TelnetSession的关闭方法，会关闭tcp连接，并等待输入输出管道的读写协程退出，可重复调用
+ * @author shenbowei
+ */
+func (this *TelnetSession) Close() {
+	defer func() {
+		if err := recover(); err != nil {
+			this.logger.Error("TelnetSession Close err:%s", err)
+		}
+	}()
+	this.closeOnce.Do(func() {
+		close(this.done)
+		if err := this.conn.Close(); err != nil {
+			this.logger.Debug("Close telnet conn err:%s", err.Error())
+		}
+		this.waitMux()
+	})
+}
+
+/**
+ * telnet的输出流，去掉选项协商的内容，并对设备的协商请求进行应答（只接受回显和抑制继续进行，其余全部拒绝）
+ * @attr conn:用于发送应答的tcp连接，reader:带缓存的tcp连接输入
+ * @author shenbowei
+ */
+type telnetReader struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (this *telnetReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		//已经读到数据且缓存为空时直接返回，避免阻塞
+		if n > 0 && this.reader.Buffered() == 0 {
+			break
+		}
+		b, err := this.reader.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b != telnetIAC {
+			p[n] = b
+			n++
+			continue
+		}
+		command, err := this.reader.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		switch command {
+		case telnetIAC:
+			p[n] = telnetIAC
+			n++
+		case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+			option, err := this.reader.ReadByte()
+			if err != nil {
+				return n, err
+			}
+			if reply := telnetNegotiate(command, option); reply != nil {
+				this.conn.Write(reply)
+			}
+		case telnetSB:
+			//子协商的内容直接丢弃，直到IAC SE
+			if _, err := this.reader.ReadBytes(telnetSE); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+/**
+ * 生成选项协商的应答
+ * @param command 设备发送的协商指令（DO/DONT/WILL/WONT）, option 协商的选项
+ * @return 应答的内容，不需要应答时返回nil
+ * @author shenbowei
+ */
+func telnetNegotiate(command, option byte) []byte {
+	switch command {
+	case telnetWILL:
+		if option == telnetOptEcho || option == telnetOptSGA {
+			return []byte{telnetIAC, telnetDO, option}
+		}
+		return []byte{telnetIAC, telnetDONT, option}
+	case telnetDO:
+		if option == telnetOptSGA {
+			return []byte{telnetIAC, telnetWILL, option}
+		}
+		return []byte{telnetIAC, telnetWONT, option}
+	}
+	return nil
+}
+
+/**
+ * telnet的输入流，对数据中的IAC字符进行转义
+ * @attr conn:telnet的tcp连接
+ * @author shenbowei
+ */
+type telnetWriter struct {
+	conn net.Conn
+}
+
+func (this *telnetWriter) Write(p []byte) (int, error) {
+	escaped := bytes.Replace(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC}, -1)
+	if _, err := this.conn.Write(escaped); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,246 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/**
+ * 测试使用的模拟telnet交换机，发送选项协商后要求输入用户名和密码，登录后提供带回显的shell
+ */
+type fakeTelnetSwitch struct {
+	t         *testing.T
+	listener  net.Listener
+	password  string
+	prompt    string
+	outputs   map[string]string
+	locker    sync.Mutex
+	dials     int
+	replies   []byte
+	waitGroup sync.WaitGroup
+}
+
+func newFakeTelnetSwitch(t *testing.T, password, prompt string, outputs map[string]string) *fakeTelnetSwitch {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	fake := &fakeTelnetSwitch{t: t, listener: listener, password: password, prompt: prompt, outputs: outputs}
+	fake.waitGroup.Add(1)
+	go fake.serve()
+	t.Cleanup(fake.close)
+	return fake
+}
+
+func (this *fakeTelnetSwitch) port() int {
+	return this.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (this *fakeTelnetSwitch) close() {
+	this.listener.Close()
+	this.waitGroup.Wait()
+}
+
+func (this *fakeTelnetSwitch) getDials() int {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return this.dials
+}
+
+func (this *fakeTelnetSwitch) getReplies() []byte {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	return append([]byte(nil), this.replies...)
+}
+
+func (this *fakeTelnetSwitch) serve() {
+	defer this.waitGroup.Done()
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		this.locker.Lock()
+		this.dials++
+		this.locker.Unlock()
+		this.waitGroup.Add(1)
+		go func() {
+			defer this.waitGroup.Done()
+			defer conn.Close()
+			this.handleConn(conn)
+		}()
+	}
+}
+
+func (this *fakeTelnetSwitch) handleConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	//协商回显，并请求终端类型（客户端应当拒绝）
+	conn.Write([]byte{telnetIAC, telnetWILL, telnetOptEcho, telnetIAC, telnetDO, 24})
+	conn.Write([]byte("\r\nUser Access Verification\r\n\r\nUsername:"))
+	if _, err := this.readLine(reader); err != nil {
+		return
+	}
+	conn.Write([]byte("\r\nPassword:"))
+	password, err := this.readLine(reader)
+	if err != nil {
+		return
+	}
+	if password != this.password {
+		conn.Write([]byte("\r\nError: Authentication fail\r\nUsername:"))
+		this.readLine(reader)
+		return
+	}
+	conn.Write([]byte("\r\nInfo: The max number of VTY users is 5.\r\n" + this.prompt))
+	for {
+		cmd, err := this.readLine(reader)
+		if err != nil {
+			return
+		}
+		output := cmd + "\r\n"
+		if cmd != "" {
+			output += this.outputs[cmd] + "\r\n"
+		}
+		conn.Write([]byte(output + this.prompt))
+	}
+}
+
+// 读取一行输入，记录客户端的协商应答
+func (this *fakeTelnetSwitch) readLine(reader *bufio.Reader) (string, error) {
+	line := make([]byte, 0)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case telnetIAC:
+			reply := []byte{b, 0, 0}
+			if _, err := io.ReadFull(reader, reply[1:]); err != nil {
+				return "", err
+			}
+			this.locker.Lock()
+			this.replies = append(this.replies, reply...)
+			this.locker.Unlock()
+		case '\r':
+		case '\n':
+			return strings.TrimSpace(string(line)), nil
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+func TestSessionManagerTelnet(t *testing.T) {
+	fake := newFakeTelnetSwitch(t, "admin", "Switch#", map[string]string{"show clock": "10:00:00.000 UTC Sun Oct 18 2026"})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := Device{Host: "127.0.0.1", User: "admin", Credential: &Credential{Password: "admin"}, Brand: CISCO,
+		Transport: TELNET_TRANSPORT, TelnetPort: fake.port()}
+
+	for i := 0; i < 2; i++ {
+		result, err := manager.RunDeviceCommands(device, "show clock")
+		if err != nil {
+			t.Fatalf("RunDeviceCommands err:%s", err)
+		}
+		if !strings.Contains(result, "10:00:00.000 UTC Sun Oct 18 2026") {
+			t.Errorf("unexpected result:%q", result)
+		}
+	}
+	if fake.getDials() != 1 {
+		t.Errorf("dials=%d, expected the telnet session to be reused", fake.getDials())
+	}
+	session, err := manager.CheckoutSession(context.Background(), device)
+	if err != nil {
+		t.Fatalf("CheckoutSession err:%s", err)
+	}
+	if session.GetBanner() != "User Access Verification" || session.GetMOTD() != "Info: The max number of VTY users is 5." {
+		t.Errorf("unexpected banner:%q, MOTD:%q", session.GetBanner(), session.GetMOTD())
+	}
+	manager.ReleaseSession(session)
+	replies := fake.getReplies()
+	if !bytes.Contains(replies, []byte{telnetIAC, telnetDO, telnetOptEcho}) || !bytes.Contains(replies, []byte{telnetIAC, telnetWONT, 24}) {
+		t.Errorf("unexpected negotiation replies:%v", replies)
+	}
+}
+
+func TestSessionManagerTelnetLoginFailed(t *testing.T) {
+	fake := newFakeTelnetSwitch(t, "admin", "Switch#", nil)
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := Device{Host: "127.0.0.1", User: "admin", Credential: &Credential{Password: "wrong"}, Brand: CISCO,
+		Transport: TELNET_TRANSPORT, TelnetPort: fake.port()}
+
+	if _, err := manager.RunDeviceCommands(device, "show clock"); err != ErrLoginFailed {
+		t.Errorf("RunDeviceCommands err=%v, expected ErrLoginFailed", err)
+	}
+}
+
+func TestSessionManagerAutoTransport(t *testing.T) {
+	fake := newFakeTelnetSwitch(t, "admin", "<HUAWEI>", map[string]string{"dis clock": "2026-10-18 10:00:00"})
+	//获取一个没有监听的端口作为ssh端口
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	manager := newTestSessionManager(WithDialTimeout(time.Second))
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", "127.0.0.1:"+strconv.Itoa(closedPort), "")
+	device.Transport = AUTO_TRANSPORT
+	device.TelnetPort = fake.port()
+
+	result, err := manager.RunDeviceCommands(device, "dis clock")
+	if err != nil {
+		t.Fatalf("RunDeviceCommands err:%s", err)
+	}
+	if !strings.Contains(result, "2026-10-18 10:00:00") {
+		t.Errorf("unexpected result:%q", result)
+	}
+	brand, err := manager.GetDeviceBrand(device)
+	if err != nil || brand != HUAWEI {
+		t.Errorf("GetDeviceBrand=%s, err=%v, expected huawei", brand, err)
+	}
+}
+
+func TestSessionManagerTelnetLoginTimeout(t *testing.T) {
+	//接受连接后不发送任何输出的设备
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen err:%s", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	//登录时等待提示符使用promptTimeout，而不是dialTimeout
+	manager := newTestSessionManager(WithDialTimeout(10 * time.Second))
+	defer manager.Shutdown(context.Background())
+	device := Device{Host: "127.0.0.1", User: "admin", Credential: &Credential{Password: "admin"}, Brand: CISCO,
+		Transport: TELNET_TRANSPORT, TelnetPort: listener.Addr().(*net.TCPAddr).Port}
+
+	start := time.Now()
+	if _, err := manager.RunDeviceCommands(device, "show clock"); err == nil {
+		t.Fatalf("RunDeviceCommands to a silent device succeeded")
+	}
+	if elapsed := time.Since(start); elapsed >= 10*time.Second {
+		t.Errorf("login took %s, expected to be bounded by the prompt timeout", elapsed)
+	}
+}
@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -75,3 +76,56 @@ func TestGetSSHBrand(t *testing.T) {
 		time.Sleep(time.Second)
 	}
 }
+
+func TestParsePrivilegeMode(t *testing.T) {
+	cases := map[string]string{
+		"<HUAWEI>":           USER_MODE,
+		"[HUAWEI]":           CONFIG_MODE,
+		"[HUAWEI-Vlanif10]":  CONFIG_MODE,
+		"Switch>":            USER_MODE,
+		"Switch#":            PRIVILEGED_MODE,
+		"Switch(config)#":    CONFIG_MODE,
+		"Switch(config-if)#": CONFIG_MODE,
+		"Password:":          UNKNOWN_MODE,
+		"":                   UNKNOWN_MODE,
+	}
+	for prompt, expected := range cases {
+		if mode := parsePrivilegeMode(prompt); mode != expected {
+			t.Errorf("parsePrivilegeMode(%q) = %q, expected %q", prompt, mode, expected)
+		}
+	}
+	if prompt := lastPromptLine("dis clock\r\n10:00:00\r\n<HUAWEI>  "); prompt != "<HUAWEI>" {
+		t.Errorf("lastPromptLine = %q", prompt)
+	}
+}
+
+func TestDeviceKey(t *testing.T) {
+	device := NewDevice("admin_1", "secret", "10.0.0.1:2222", H3C)
+	if device.Host != "10.0.0.1" || device.GetPort() != 2222 {
+		t.Fatalf("NewDevice parse err:%+v", device)
+	}
+	key := device.Key()
+	if strings.Contains(key, "secret") {
+		t.Errorf("device key contains plaintext password:%s", key)
+	}
+	other := NewDevice("admin", "1_secret", "10.0.0.1:2222", H3C)
+	if other.Key() == key {
+		t.Errorf("devices with different users share the same key:%s", key)
+	}
+	if NewDevice("admin_1", "other", "10.0.0.1:2222", H3C).Key() == key {
+		t.Errorf("devices with different passwords share the same key:%s", key)
+	}
+	if NewDevice("admin_1", "secret", "10.0.0.1:2222", H3C).Key() != key {
+		t.Errorf("the same device got a different key:%s", key)
+	}
+	telnet := device
+	telnet.Transport = TELNET_TRANSPORT
+	exec := device
+	exec.ExecMode = EXEC_MODE
+	if telnet.Key() == key || exec.Key() == key || telnet.Key() == exec.Key() {
+		t.Errorf("devices with different transports or exec modes share the same key:%s", key)
+	}
+	if address := NewDevice("admin", "", "10.0.0.2", "").Address(); address != "10.0.0.2:22" {
+		t.Errorf("default port address=%s", address)
+	}
+}
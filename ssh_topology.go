@@ -0,0 +1,610 @@
+package ssh
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultCrawlConcurrency = 8
+
+/**
+ * 拓扑发现时为新发现的设备提供登录信息
+ * @author shenbowei
+ */
+type CredentialProvider interface {
+	/**
+	 * 获取登录邻居设备使用的Device
+	 * @param host 邻居的管理地址, neighbor 发现该设备的邻居信息
+	 * @return 登录使用的Device（Host为空时使用host），false:不登录该设备
+	 */
+	DeviceFor(host string, neighbor Neighbor) (Device, bool)
+}
+
+/**
+ * 函数形式的CredentialProvider
+ * @author shenbowei
+ */
+type CredentialProviderFunc func(host string, neighbor Neighbor) (Device, bool)
+
+func (this CredentialProviderFunc) DeviceFor(host string, neighbor Neighbor) (Device, bool) {
+	return this(host, neighbor)
+}
+
+/**
+ * 创建所有设备使用相同用户名和凭证的CredentialProvider，设备品牌使用邻居信息中识别的品牌
+ * @param user 登录的用户名, credential 登录凭证
+ * @return CredentialProvider
+ * @author shenbowei
+ */
+func StaticCredentials(user string, credential *Credential) CredentialProvider {
+	return CredentialProviderFunc(func(host string, neighbor Neighbor) (Device, bool) {
+		return Device{Host: host, User: user, Credential: credential, Brand: neighbor.RemoteBrand}, true
+	})
+}
+
+/**
+ * 拓扑中的设备
+ * @attr ID:设备名称（无法获取时为机框ID或管理地址），ManagementAddress:管理地址，ChassisID:机框ID，Vendor:品牌，
+ *       Platform:平台，Model:型号，SoftwareVersion:软件版本，Capabilities:LLDP/CDP能力，
+ *       Crawled:是否登录并获取了邻居，Error:登录或获取邻居的错误
+ * @author shenbowei
+ */
+type TopologyNode struct {
+	ID                string   `json:"id"`
+	ManagementAddress string   `json:"management_address,omitempty"`
+	ChassisID         string   `json:"chassis_id,omitempty"`
+	Vendor            string   `json:"vendor,omitempty"`
+	Platform          string   `json:"platform,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	SoftwareVersion   string   `json:"software_version,omitempty"`
+	Capabilities      []string `json:"capabilities,omitempty"`
+	Crawled           bool     `json:"crawled"`
+	Error             string   `json:"error,omitempty"`
+}
+
+/**
+ * 拓扑中两台设备之间的链路，两端各自上报的同一条链路只保留一条
+ * @attr Source/Target:两端设备的ID，SourceInterface/TargetInterface:两端的接口，Protocol:发现链路的协议
+ * @author shenbowei
+ */
+type TopologyLink struct {
+	Source          string `json:"source"`
+	SourceInterface string `json:"source_interface"`
+	Target          string `json:"target"`
+	TargetInterface string `json:"target_interface"`
+	Protocol        string `json:"protocol"`
+}
+
+/**
+ * 拓扑发现的结果，设备按ID排序，链路按源设备和源接口排序
+ * @author shenbowei
+ */
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Links []TopologyLink `json:"links"`
+}
+
+/**
+ * 从种子设备开始，按LLDP/CDP邻居逐层登录设备，发现网络拓扑
+ * @attr Manager:执行指令的SessionManager（为nil时使用DefaultSessionManager），
+ *       Credentials:新发现设备的登录信息（为nil时只登录种子设备），MaxDepth:距离种子设备的最大跳数（为0时不限制），
+ *       Concurrency:同时登录的设备数量（为0时为8），Follow:是否登录邻居（为nil时登录所有有管理地址的邻居），
+ *       DomainSuffixes:匹配设备名称时忽略的域名后缀（如"example.com"，使"core-1.example.com"与"Core-1"对应同一设备）
+ * @author shenbowei
+ */
+type Crawler struct {
+	Manager        *SessionManager
+	Credentials    CredentialProvider
+	MaxDepth       int
+	Concurrency    int
+	Follow         func(neighbor Neighbor) bool
+	DomainSuffixes []string
+}
+
+/**
+ * 待登录的设备
+ * @attr device:登录使用的Device，key:发现该设备的邻居对应的节点键值（种子设备为""）
+ * @author shenbowei
+ */
+type crawlTarget struct {
+	device Device
+	key    string
+}
+
+/**
+ * 登录一台设备的结果
+ * @author shenbowei
+ */
+type crawlResult struct {
+	facts     Facts
+	neighbors []Neighbor
+	err       error
+}
+
+/**
+ * 拓扑发现过程中的状态，节点和链路都以节点键值（见nodeKey）引用设备，便于登录后用设备名称替换ID
+ * @attr domains:计算节点键值时忽略的域名后缀
+ * @author shenbowei
+ */
+type topologyBuilder struct {
+	nodes   map[string]*TopologyNode
+	links   map[string]TopologyLink
+	domains []string
+}
+
+/**
+ * 发现网络拓扑：逐层并发登录设备获取设备信息和邻居，再根据邻居的管理地址和Credentials登录下一层设备，
+ * 同一台设备（名称相同）只登录一次。登录失败的设备保留在拓扑中并记录Error
+ * @param seeds 种子设备
+ * @return 网络拓扑，执行的错误（所有种子设备都登录失败时返回第一个错误）
+ * @author shenbowei
+ */
+func (this *Crawler) Crawl(seeds []Device) (*Topology, error) {
+	manager := this.Manager
+	if manager == nil {
+		manager = DefaultSessionManager
+	}
+	builder := &topologyBuilder{nodes: make(map[string]*TopologyNode), links: make(map[string]TopologyLink), domains: this.DomainSuffixes}
+	queued := make(map[string]bool)
+	level := make([]crawlTarget, 0, len(seeds))
+	for _, seed := range seeds {
+		level = append(level, crawlTarget{device: seed})
+	}
+	var seedErr error
+	seedCrawled := false
+	for depth := 0; len(level) > 0; depth++ {
+		results := this.crawlLevel(manager, level)
+		//先登记本层所有设备，避免本层设备互为邻居时被重复登录
+		keys := make([]string, len(level))
+		for i, target := range level {
+			keys[i] = builder.crawled(target, results[i])
+			if depth > 0 {
+				continue
+			}
+			if results[i].err == nil {
+				seedCrawled = true
+			} else if seedErr == nil {
+				seedErr = results[i].err
+			}
+		}
+		next := make([]crawlTarget, 0)
+		for i, result := range results {
+			if keys[i] == "" || result.err != nil {
+				continue
+			}
+			for _, neighbor := range result.neighbors {
+				remoteKey := builder.addNeighbor(keys[i], neighbor)
+				if remoteKey == "" || queued[remoteKey] || builder.nodes[remoteKey].Crawled || !this.follow(neighbor, depth) {
+					continue
+				}
+				device, ok := this.Credentials.DeviceFor(neighbor.ManagementAddress, neighbor)
+				if !ok {
+					continue
+				}
+				if device.Host == "" {
+					device.Host = neighbor.ManagementAddress
+				}
+				queued[remoteKey] = true
+				next = append(next, crawlTarget{device: device, key: remoteKey})
+			}
+		}
+		level = next
+	}
+	if !seedCrawled && seedErr != nil {
+		return nil, seedErr
+	}
+	return builder.topology(), nil
+}
+
+/**
+ * 判断是否登录邻居
+ * @param neighbor 邻居, depth 发现该邻居的设备距离种子设备的跳数
+ * @return true:登录该邻居
+ * @author shenbowei
+ */
+func (this *Crawler) follow(neighbor Neighbor, depth int) bool {
+	if this.Credentials == nil || neighbor.ManagementAddress == "" {
+		return false
+	}
+	if this.MaxDepth > 0 && depth+1 > this.MaxDepth {
+		return false
+	}
+	return this.Follow == nil || this.Follow(neighbor)
+}
+
+/**
+ * 并发登录一层设备，获取设备信息和邻居
+ * @param manager 执行指令的SessionManager, level 待登录的设备
+ * @return 与level顺序一致的结果
+ * @author shenbowei
+ */
+func (this *Crawler) crawlLevel(manager *SessionManager, level []crawlTarget) []crawlResult {
+	concurrency := this.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultCrawlConcurrency
+	}
+	results := make([]crawlResult, len(level))
+	semaphore := make(chan struct{}, concurrency)
+	var waitGroup sync.WaitGroup
+	for i := range level {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			device := level[i].device
+			if results[i].facts, results[i].err = manager.GetFacts(device); results[i].err == nil {
+				results[i].neighbors, results[i].err = manager.GetNeighbors(device)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+	return results
+}
+
+/**
+ * 获取设备名称对应的节点键值：忽略大小写和配置的域名后缀，后缀为"example.com"时"Core-1"与"core-1.example.com"对应同一节点，
+ * 其他域名的同名设备（如"core-1.site-b.example.net"）仍为不同的节点
+ * @param id 设备名称、机框ID或管理地址, domains 忽略的域名后缀
+ * @return 节点键值
+ * @author shenbowei
+ */
+func nodeKey(id string, domains []string) string {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if net.ParseIP(id) != nil {
+		return id
+	}
+	for _, domain := range domains {
+		suffix := "." + strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if suffix != "." && len(id) > len(suffix) && strings.HasSuffix(id, suffix) {
+			return strings.TrimSuffix(id, suffix)
+		}
+	}
+	return id
+}
+
+/**
+ * 获取或创建节点
+ * @param id 设备名称、机框ID或管理地址
+ * @return 节点键值，节点
+ * @author shenbowei
+ */
+func (this *topologyBuilder) node(id string) (string, *TopologyNode) {
+	key := nodeKey(id, this.domains)
+	node, ok := this.nodes[key]
+	if !ok {
+		node = &TopologyNode{ID: id}
+		this.nodes[key] = node
+	}
+	return key, node
+}
+
+/**
+ * 登记一台登录过的设备：成功时以设备名称作为ID并记录设备信息，失败时记录错误
+ * @param target 登录的设备, result 登录的结果
+ * @return 节点键值，设备已经通过其他地址登录过时返回""
+ * @author shenbowei
+ */
+func (this *topologyBuilder) crawled(target crawlTarget, result crawlResult) string {
+	id := target.device.Host
+	if result.err == nil && result.facts.Hostname != "" {
+		id = result.facts.Hostname
+		if key := nodeKey(id, this.domains); target.key != "" && target.key != key {
+			//邻居上报的名称与设备自身的名称不同，合并到设备名称对应的节点
+			this.renameNode(target.key, key, id)
+		}
+	} else if target.key != "" {
+		id = this.nodes[target.key].ID
+	}
+	key, node := this.node(id)
+	if node.Crawled {
+		return ""
+	}
+	if node.ManagementAddress == "" {
+		node.ManagementAddress = target.device.Host
+	}
+	if result.err != nil {
+		node.Error = result.err.Error()
+		return ""
+	}
+	node.Crawled = true
+	node.Vendor = result.facts.Vendor
+	node.Platform = result.facts.Platform
+	node.Model = result.facts.Model
+	node.SoftwareVersion = result.facts.SoftwareVersion
+	return key
+}
+
+/**
+ * 将节点合并到新的键值下，并更新引用它的链路。新的键值已经存在节点时，以已有节点为准补全其空缺的字段
+ * @param from 原节点键值, to 新节点键值, id 新节点的ID
+ * @author shenbowei
+ */
+func (this *topologyBuilder) renameNode(from, to, id string) {
+	node, ok := this.nodes[from]
+	if !ok {
+		return
+	}
+	delete(this.nodes, from)
+	if existing, exists := this.nodes[to]; exists {
+		mergeNode(existing, node)
+		node = existing
+	}
+	node.ID = id
+	this.nodes[to] = node
+	for linkKey, link := range this.links {
+		if link.Source == from || link.Target == from {
+			delete(this.links, linkKey)
+			if link.Source == from {
+				link.Source = to
+			}
+			if link.Target == from {
+				link.Target = to
+			}
+			this.addLink(link)
+		}
+	}
+}
+
+/**
+ * 将节点的信息合并到另一个节点，只补全空缺的字段
+ * @param into 合并到的节点, from 被合并的节点
+ * @author shenbowei
+ */
+func mergeNode(into, from *TopologyNode) {
+	for _, field := range []struct{ into, from *string }{
+		{&into.ManagementAddress, &from.ManagementAddress},
+		{&into.ChassisID, &from.ChassisID},
+		{&into.Vendor, &from.Vendor},
+		{&into.Platform, &from.Platform},
+		{&into.Model, &from.Model},
+		{&into.SoftwareVersion, &from.SoftwareVersion},
+		{&into.Error, &from.Error},
+	} {
+		if *field.into == "" {
+			*field.into = *field.from
+		}
+	}
+	if len(into.Capabilities) == 0 {
+		into.Capabilities = from.Capabilities
+	}
+	into.Crawled = into.Crawled || from.Crawled
+}
+
+/**
+ * 登记设备的一个邻居：创建或补全邻居的节点，并添加链路
+ * @param key 设备的节点键值, neighbor 邻居
+ * @return 邻居的节点键值，邻居没有名称、机框ID和管理地址时返回""
+ * @author shenbowei
+ */
+func (this *topologyBuilder) addNeighbor(key string, neighbor Neighbor) string {
+	id := neighbor.RemoteName
+	if id == "" {
+		id = neighbor.RemoteChassisID
+	}
+	if id == "" {
+		id = neighbor.ManagementAddress
+	}
+	if id == "" {
+		return ""
+	}
+	remoteKey, remote := this.node(id)
+	if remote.ManagementAddress == "" {
+		remote.ManagementAddress = neighbor.ManagementAddress
+	}
+	if remote.ChassisID == "" {
+		remote.ChassisID = neighbor.RemoteChassisID
+	}
+	if !remote.Crawled {
+		if remote.Vendor == "" {
+			remote.Vendor = neighbor.RemoteBrand
+		}
+		if remote.Platform == "" {
+			remote.Platform = neighbor.Platform
+		}
+	}
+	if len(remote.Capabilities) == 0 {
+		remote.Capabilities = neighbor.Capabilities
+	}
+	this.addLink(TopologyLink{Source: key, SourceInterface: neighbor.LocalInterface,
+		Target: remoteKey, TargetInterface: neighbor.RemoteInterface, Protocol: neighbor.Protocol})
+	return remoteKey
+}
+
+/**
+ * 添加链路，两端各自上报的同一条链路只保留先添加的
+ * @param link 以节点键值表示两端的链路
+ * @author shenbowei
+ */
+func (this *topologyBuilder) addLink(link TopologyLink) {
+	ends := []string{link.Source + "|" + strings.ToLower(link.SourceInterface), link.Target + "|" + strings.ToLower(link.TargetInterface)}
+	sort.Strings(ends)
+	linkKey := ends[0] + "--" + ends[1]
+	if _, ok := this.links[linkKey]; !ok {
+		this.links[linkKey] = link
+	}
+}
+
+/**
+ * 生成排序后的拓扑，链路两端的节点键值替换为节点ID
+ * @return 网络拓扑
+ * @author shenbowei
+ */
+func (this *topologyBuilder) topology() *Topology {
+	topology := &Topology{Nodes: make([]TopologyNode, 0, len(this.nodes)), Links: make([]TopologyLink, 0, len(this.links))}
+	for _, node := range this.nodes {
+		topology.Nodes = append(topology.Nodes, *node)
+	}
+	for _, link := range this.links {
+		link.Source = this.nodes[link.Source].ID
+		link.Target = this.nodes[link.Target].ID
+		topology.Links = append(topology.Links, link)
+	}
+	sort.Slice(topology.Nodes, func(i, j int) bool { return topology.Nodes[i].ID < topology.Nodes[j].ID })
+	sort.Slice(topology.Links, func(i, j int) bool {
+		a, b := topology.Links[i], topology.Links[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.SourceInterface != b.SourceInterface {
+			return a.SourceInterface < b.SourceInterface
+		}
+		return a.Target < b.Target
+	})
+	return topology
+}
+
+/**
+ * 以JSON格式输出拓扑
+ * @param writer 输出
+ * @return 输出的错误
+ * @author shenbowei
+ */
+func (this *Topology) WriteJSON(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(this)
+}
+
+/**
+ * 转换为DOT中带引号的字符串
+ * @param text 文本
+ * @return 带引号的字符串
+ * @author shenbowei
+ */
+func dotQuote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(text) + `"`
+}
+
+/**
+ * 以Graphviz DOT格式输出拓扑（无向图），节点标签为名称、管理地址和型号，未登录的设备为虚线框，链路两端标注接口
+ * @param writer 输出
+ * @return 输出的错误
+ * @author shenbowei
+ */
+func (this *Topology) WriteDOT(writer io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString("graph topology {\n")
+	for _, node := range this.Nodes {
+		label := node.ID
+		for _, extra := range []string{node.ManagementAddress, node.Model} {
+			if extra != "" {
+				label += "\n" + extra
+			}
+		}
+		style := ""
+		if !node.Crawled {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&builder, "  %s [label=%s%s];\n", dotQuote(node.ID), dotQuote(label), style)
+	}
+	for _, link := range this.Links {
+		fmt.Fprintf(&builder, "  %s -- %s [taillabel=%s, headlabel=%s];\n", dotQuote(link.Source), dotQuote(link.Target),
+			dotQuote(link.SourceInterface), dotQuote(link.TargetInterface))
+	}
+	builder.WriteString("}\n")
+	_, err := io.WriteString(writer, builder.String())
+	return err
+}
+
+// GraphML的结构
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+/**
+ * 生成GraphML的data列表，忽略空值
+ * @param pairs 依次为键和值
+ * @return data列表
+ * @author shenbowei
+ */
+func graphMLDataList(pairs ...string) []graphMLData {
+	data := make([]graphMLData, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			data = append(data, graphMLData{Key: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return data
+}
+
+/**
+ * 以GraphML格式输出拓扑（无向图），设备信息和链路两端的接口作为data输出
+ * @param writer 输出
+ * @return 输出的错误
+ * @author shenbowei
+ */
+func (this *Topology) WriteGraphML(writer io.Writer) error {
+	document := graphML{Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: "topology", EdgeDefault: "undirected"}}
+	for _, name := range []string{"management_address", "chassis_id", "vendor", "platform", "model", "software_version", "crawled", "error"} {
+		keyType := "string"
+		if name == "crawled" {
+			keyType = "boolean"
+		}
+		document.Keys = append(document.Keys, graphMLKey{ID: name, For: "node", Name: name, Type: keyType})
+	}
+	for _, name := range []string{"source_interface", "target_interface", "protocol"} {
+		document.Keys = append(document.Keys, graphMLKey{ID: name, For: "edge", Name: name, Type: "string"})
+	}
+	for _, node := range this.Nodes {
+		document.Graph.Nodes = append(document.Graph.Nodes, graphMLNode{ID: node.ID, Data: graphMLDataList(
+			"management_address", node.ManagementAddress, "chassis_id", node.ChassisID, "vendor", node.Vendor,
+			"platform", node.Platform, "model", node.Model, "software_version", node.SoftwareVersion,
+			"crawled", strconv.FormatBool(node.Crawled), "error", node.Error)})
+	}
+	for _, link := range this.Links {
+		document.Graph.Edges = append(document.Graph.Edges, graphMLEdge{Source: link.Source, Target: link.Target, Data: graphMLDataList(
+			"source_interface", link.SourceInterface, "target_interface", link.TargetInterface, "protocol", link.Protocol)})
+	}
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
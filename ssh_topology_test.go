@@ -0,0 +1,151 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestCrawlerCrawl(t *testing.T) {
+	access := newFakeSwitch(t, "Access-SW1#", map[string]string{
+		CiscoVersionCmd: ciscoVersionSample,
+		CiscoLLDPCmd:    ciscoLLDPSample,
+		CiscoCDPCmd:     ciscoCDPSample,
+	})
+	core := newFakeSwitch(t, "<Core-HW>", map[string]string{
+		HuaweiVersionCmd: huaweiVersionSample,
+		HuaweiSysnameCmd: "sysname Core-HW",
+		HuaweiLLDPCmd:    huaweiLLDPSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	followed := make([]string, 0)
+	crawler := &Crawler{Manager: manager, DomainSuffixes: []string{"example.com"}, Credentials: CredentialProviderFunc(func(host string, neighbor Neighbor) (Device, bool) {
+		followed = append(followed, host)
+		if host != "10.0.0.2" {
+			return Device{}, false
+		}
+		return NewDevice("admin", "admin", core.addr(), neighbor.RemoteBrand), true
+	})}
+	topology, err := crawler.Crawl([]Device{NewDevice("admin", "admin", access.addr(), CISCO)})
+	if err != nil {
+		t.Fatalf("Crawl err:%s", err)
+	}
+
+	ids := make([]string, 0)
+	for _, node := range topology.Nodes {
+		ids = append(ids, node.ID)
+	}
+	if strings.Join(ids, ",") != "Access-SW1,Core-HW,Dist-2,N9K-1,SEP001122334455" {
+		t.Fatalf("unexpected nodes:%+v", topology.Nodes)
+	}
+	if !topology.Nodes[0].Crawled || !topology.Nodes[1].Crawled || topology.Nodes[2].Crawled ||
+		topology.Nodes[1].Vendor != HUAWEI || topology.Nodes[2].ManagementAddress != "10.0.0.3" {
+		t.Errorf("unexpected nodes:%+v", topology.Nodes)
+	}
+	//两端各自上报的Access-SW1与Core-HW之间的链路只保留一条
+	if len(topology.Links) != 4 {
+		t.Fatalf("unexpected links:%+v", topology.Links)
+	}
+	if topology.Links[0] != (TopologyLink{Source: "Access-SW1", SourceInterface: "GigabitEthernet1/0/48", Target: "N9K-1",
+		TargetInterface: "Ethernet1/1", Protocol: NEIGHBOR_CDP}) {
+		t.Errorf("unexpected link 0:%+v", topology.Links[0])
+	}
+	if topology.Links[1] != (TopologyLink{Source: "Access-SW1", SourceInterface: "GigabitEthernet1/0/49", Target: "Core-HW",
+		TargetInterface: "GigabitEthernet0/0/1", Protocol: NEIGHBOR_LLDP}) {
+		t.Errorf("unexpected link 1:%+v", topology.Links[1])
+	}
+	//Core-HW上报的Access-SW1已经登录过，不再询问登录信息
+	if strings.Join(followed, ",") != "10.0.0.2,10.0.0.50,192.168.1.9,10.0.0.3" {
+		t.Errorf("unexpected followed hosts:%v", followed)
+	}
+
+	var buffer bytes.Buffer
+	if err := topology.WriteJSON(&buffer); err != nil {
+		t.Fatalf("WriteJSON err:%s", err)
+	}
+	decoded := Topology{}
+	if err := json.Unmarshal(buffer.Bytes(), &decoded); err != nil || len(decoded.Nodes) != 5 || decoded.Links[1].TargetInterface != "GigabitEthernet0/0/1" {
+		t.Errorf("unexpected json:%s err:%v", buffer.String(), err)
+	}
+
+	buffer.Reset()
+	if err := topology.WriteDOT(&buffer); err != nil {
+		t.Fatalf("WriteDOT err:%s", err)
+	}
+	dot := buffer.String()
+	if !strings.HasPrefix(dot, "graph topology {\n") ||
+		!strings.Contains(dot, `"Access-SW1" -- "Core-HW" [taillabel="GigabitEthernet1/0/49", headlabel="GigabitEthernet0/0/1"];`) ||
+		!strings.Contains(dot, `"Dist-2" [label="Dist-2\n10.0.0.3", style=dashed];`) {
+		t.Errorf("unexpected dot:%s", dot)
+	}
+
+	buffer.Reset()
+	if err := topology.WriteGraphML(&buffer); err != nil {
+		t.Fatalf("WriteGraphML err:%s", err)
+	}
+	document := graphML{}
+	if err := xml.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatalf("unexpected graphml:%s err:%s", buffer.String(), err)
+	}
+	if len(document.Graph.Nodes) != 5 || len(document.Graph.Edges) != 4 || document.Graph.Edges[1].Target != "Core-HW" {
+		t.Errorf("unexpected graphml:%s", buffer.String())
+	}
+}
+
+func TestNodeKey(t *testing.T) {
+	domains := []string{"example.com", ".corp.local."}
+	cases := map[string]string{
+		"Core-1":                   "core-1",
+		"core-1.example.com":       "core-1",
+		"Core-1.CORP.local":        "core-1",
+		"sw1.site-a.example.net":   "sw1.site-a.example.net",
+		"sw1.site-b.example.net":   "sw1.site-b.example.net",
+		"sw1.site-a.example.com":   "sw1.site-a",
+		"10.0.0.1":                 "10.0.0.1",
+		"00:11:22:33:44:55":        "00:11:22:33:44:55",
+		"example.com":              "example.com",
+		"core-1.notexample.com.cn": "core-1.notexample.com.cn",
+	}
+	for id, expected := range cases {
+		if key := nodeKey(id, domains); key != expected {
+			t.Errorf("nodeKey(%q) = %q, expected %q", id, key, expected)
+		}
+	}
+	//没有配置域名后缀时使用完整的名称
+	if key := nodeKey("Core-1.example.com", nil); key != "core-1.example.com" {
+		t.Errorf("nodeKey without domains = %q", key)
+	}
+}
+
+func TestTopologyBuilderRenameNode(t *testing.T) {
+	builder := &topologyBuilder{nodes: make(map[string]*TopologyNode), links: make(map[string]TopologyLink)}
+	_, reported := builder.node("core")
+	reported.ManagementAddress = "10.0.0.1"
+	reported.Platform = "S6730"
+	reported.Capabilities = []string{"bridge", "router"}
+	_, existing := builder.node("Core-1")
+	existing.ChassisID = "0011-2233-4455"
+	existing.Platform = "S6730-H"
+	builder.addLink(TopologyLink{Source: "access", SourceInterface: "GE0/0/1", Target: "core", TargetInterface: "GE0/0/2"})
+
+	//目标键值已经存在节点时合并字段，而不是丢弃被重命名的节点
+	builder.renameNode("core", "core-1", "Core-1")
+	if len(builder.nodes) != 1 {
+		t.Fatalf("unexpected nodes:%v", builder.nodes)
+	}
+	node := builder.nodes["core-1"]
+	if node.ID != "Core-1" || node.ManagementAddress != "10.0.0.1" || node.ChassisID != "0011-2233-4455" ||
+		node.Platform != "S6730-H" || len(node.Capabilities) != 2 {
+		t.Errorf("unexpected merged node:%+v", node)
+	}
+	for _, link := range builder.links {
+		if link.Target != "core-1" {
+			t.Errorf("link is not updated:%+v", link)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package ssh
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 光模块的诊断（DOM）参数
+const (
+	DOM_TEMPERATURE = "temperature"
+	DOM_VOLTAGE     = "voltage"
+	DOM_BIAS        = "bias"
+	DOM_TX_POWER    = "tx-power"
+	DOM_RX_POWER    = "rx-power"
+)
+
+// 光模块告警的类型
+const (
+	TRANSCEIVER_OUT_OF_RANGE = "out-of-range" //读数超出设备的告警阈值
+	TRANSCEIVER_DRIFT        = "drift"        //光功率与上一次采样相比变化过大
+)
+
+// 默认的光功率漂移告警阈值（dB）
+const DEFAULT_TRANSCEIVER_DRIFT = 2.0
+
+/**
+ * 光模块的一项诊断读数
+ * @attr Value:当前值（多通道模块为最差通道的值），Low/High:设备的下限/上限告警阈值（设备没有输出时均为0），
+ *       Valid:是否有读数（没有收光或设备输出"N/A"、"--"时为false）
+ * @author shenbowei
+ */
+type DOMReading struct {
+	Value float64
+	Low   float64
+	High  float64
+	Valid bool
+}
+
+/**
+ * 判断读数是否超出告警阈值
+ * @return 有读数和阈值且读数低于下限或高于上限时为true
+ * @author shenbowei
+ */
+func (this DOMReading) OutOfRange() bool {
+	return this.Valid && this.Low < this.High && (this.Value < this.Low || this.Value > this.High)
+}
+
+/**
+ * 光模块信息
+ * @attr Interface:规范化后的接口名，Type:模块类型，Vendor:厂商，SerialNumber:序列号（设备没有输出时为""），
+ *       Temperature:温度（摄氏度），Voltage:电压（V），Bias:偏置电流（mA），TxPower/RxPower:发送/接收光功率（dBm）
+ * @author shenbowei
+ */
+type Transceiver struct {
+	Interface    string
+	Type         string
+	Vendor       string
+	SerialNumber string
+	Temperature  DOMReading
+	Voltage      DOMReading
+	Bias         DOMReading
+	TxPower      DOMReading
+	RxPower      DOMReading
+}
+
+/**
+ * 获取指定的诊断读数
+ * @param name 诊断参数（DOM_TEMPERATURE等）
+ * @return 读数的指针，未知的参数为nil
+ * @author shenbowei
+ */
+func (this *Transceiver) reading(name string) *DOMReading {
+	switch name {
+	case DOM_TEMPERATURE:
+		return &this.Temperature
+	case DOM_VOLTAGE:
+		return &this.Voltage
+	case DOM_BIAS:
+		return &this.Bias
+	case DOM_TX_POWER:
+		return &this.TxPower
+	case DOM_RX_POWER:
+		return &this.RxPower
+	}
+	return nil
+}
+
+/**
+ * 光模块告警
+ * @attr Interface:接口名，Reading:诊断参数（DOM_RX_POWER等），Kind:告警类型（TRANSCEIVER_OUT_OF_RANGE、TRANSCEIVER_DRIFT），
+ *       Value:当前值，Previous:上一次采样的值（仅漂移告警），Low/High:设备的告警阈值（仅超出阈值告警）
+ * @author shenbowei
+ */
+type TransceiverAlert struct {
+	Interface string
+	Reading   string
+	Kind      string
+	Value     float64
+	Previous  float64
+	Low       float64
+	High      float64
+}
+
+/**
+ * 检查光模块的各项读数是否超出设备的告警阈值
+ * @return 超出阈值的告警
+ * @author shenbowei
+ */
+func (this Transceiver) Alerts() []TransceiverAlert {
+	alerts := make([]TransceiverAlert, 0)
+	for _, name := range []string{DOM_TEMPERATURE, DOM_VOLTAGE, DOM_BIAS, DOM_TX_POWER, DOM_RX_POWER} {
+		if reading := this.reading(name); reading.OutOfRange() {
+			alerts = append(alerts, TransceiverAlert{Interface: this.Interface, Reading: name, Kind: TRANSCEIVER_OUT_OF_RANGE,
+				Value: reading.Value, Low: reading.Low, High: reading.High})
+		}
+	}
+	return alerts
+}
+
+// 获取光模块信息时各品牌执行的指令
+var (
+	HuaweiTransceiverCmd = "display transceiver verbose"
+	H3cTransceiverCmd    = "display transceiver diagnosis interface"
+	CiscoTransceiverCmd  = "show interfaces transceiver detail"
+)
+
+/**
+ * 获取设备上光模块的诊断信息和告警阈值
+ * @param device 设备的身份信息
+ * @return 光模块信息（不支持诊断的模块没有读数），执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetTransceivers(device Device) ([]Transceiver, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiTransceiverCmd},
+		H3C:    {H3cTransceiverCmd},
+		CISCO:  {CiscoTransceiverCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch brand {
+	case H3C:
+		return parseH3cTransceivers(outputs[H3cTransceiverCmd]), nil
+	case CISCO:
+		return parseCiscoTransceivers(outputs[CiscoTransceiverCmd]), nil
+	}
+	return parseHuaweiTransceivers(outputs[HuaweiTransceiverCmd]), nil
+}
+
+/**
+ * 解析诊断读数，多通道模块的读数以"|"分隔
+ * @param text 读数的文本, lowest 为true时取最小的通道值（光功率），否则取最大的通道值
+ * @return 读数，是否解析成功
+ * @author shenbowei
+ */
+func parseDOMValue(text string, lowest bool) (float64, bool) {
+	result, valid := 0.0, false
+	for _, lane := range strings.Split(text, "|") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(lane), 64)
+		if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+			continue
+		}
+		if !valid || (lowest && value < result) || (!lowest && value > result) {
+			result, valid = value, true
+		}
+	}
+	return result, valid
+}
+
+/**
+ * 根据华为输出中的键识别诊断参数，如"RX Power High Threshold(dBM)"为DOM_RX_POWER的上限阈值，
+ * "Current RX Power(dBm)"为DOM_RX_POWER的当前值，告警（warning）阈值被忽略
+ * @param key 去除空格后的小写的键
+ * @return 诊断参数（无法识别时为""），类型（"value"、"high"、"low"）
+ * @author shenbowei
+ */
+func huaweiDOMKey(key string) (string, string) {
+	name := ""
+	switch {
+	case strings.Contains(key, "rxpower"):
+		name = DOM_RX_POWER
+	case strings.Contains(key, "txpower"):
+		name = DOM_TX_POWER
+	case strings.Contains(key, "bias"):
+		name = DOM_BIAS
+	case strings.HasPrefix(key, "volt") || strings.Contains(key, "voltage"):
+		name = DOM_VOLTAGE
+	case strings.HasPrefix(key, "temp") || strings.Contains(key, "temperature"):
+		name = DOM_TEMPERATURE
+	}
+	switch {
+	case name == "" || strings.Contains(key, "warn"):
+		return "", ""
+	case strings.Contains(key, "high"):
+		return name, "high"
+	case strings.Contains(key, "low"):
+		return name, "low"
+	}
+	return name, "value"
+}
+
+var huaweiTransceiverRegexp = regexp.MustCompile(`^\s*(\S+) transceiver information:`)
+
+/**
+ * 解析华为的光模块信息（display transceiver verbose），每个接口以"GigabitEthernet0/0/25 transceiver information:"开始，
+ * 之后为"Transceiver Type :1000_BASE_SX_SFP"、"RX Power(dBM) :-5.32"、"RX Power High Threshold(dBM) :0.00"等行
+ * @param output 指令的输出
+ * @return 光模块信息
+ * @author shenbowei
+ */
+func parseHuaweiTransceivers(output string) []Transceiver {
+	transceivers := make([]Transceiver, 0)
+	var current *Transceiver
+	for _, line := range outputLines(output) {
+		if match := huaweiTransceiverRegexp.FindStringSubmatch(line); match != nil {
+			transceivers = append(transceivers, Transceiver{Interface: NormalizeInterfaceName(HUAWEI, match[1])})
+			current = &transceivers[len(transceivers)-1]
+			continue
+		}
+		index := strings.Index(line, ":")
+		if current == nil || index < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.NewReplacer(" ", "", ".", "").Replace(line[:index]))
+		value := lineValue(line)
+		switch {
+		case key == "transceivertype":
+			current.Type = value
+		case key == "vendorname":
+			current.Vendor = value
+		case strings.Contains(key, "serialnumber"):
+			current.SerialNumber = value
+		default:
+			name, kind := huaweiDOMKey(key)
+			if name == "" {
+				continue
+			}
+			reading := current.reading(name)
+			number, ok := parseDOMValue(value, name == DOM_RX_POWER || name == DOM_TX_POWER)
+			switch kind {
+			case "high":
+				reading.High = number
+			case "low":
+				reading.Low = number
+			default:
+				reading.Value, reading.Valid = number, ok
+			}
+		}
+	}
+	return transceivers
+}
+
+var (
+	h3cTransceiverRegexp = regexp.MustCompile(`^\s*(\S+) transceiver diagnostic information`)
+	h3cDOMColumns        = []string{DOM_TEMPERATURE, DOM_VOLTAGE, DOM_BIAS, DOM_RX_POWER, DOM_TX_POWER}
+)
+
+/**
+ * 解析h3c的光模块诊断信息（display transceiver diagnosis interface），每个接口以"... transceiver diagnostic information:"开始，
+ * 各列依次为温度、电压、偏置电流、接收光功率、发送光功率，"High"、"Low"开始的行为告警阈值
+ * @param output 指令的输出
+ * @return 光模块信息
+ * @author shenbowei
+ */
+func parseH3cTransceivers(output string) []Transceiver {
+	transceivers := make([]Transceiver, 0)
+	var current *Transceiver
+	for _, line := range outputLines(output) {
+		if match := h3cTransceiverRegexp.FindStringSubmatch(line); match != nil {
+			transceivers = append(transceivers, Transceiver{Interface: NormalizeInterfaceName(H3C, match[1])})
+			current = &transceivers[len(transceivers)-1]
+			continue
+		}
+		fields := strings.Fields(line)
+		if current == nil {
+			continue
+		}
+		kind := "value"
+		if strings.EqualFold(fields[0], "High") || strings.EqualFold(fields[0], "Low") {
+			kind, fields = strings.ToLower(fields[0]), fields[1:]
+		}
+		if len(fields) != len(h3cDOMColumns) {
+			continue
+		}
+		if _, err := strconv.ParseFloat(fields[0], 64); err != nil && kind == "value" {
+			continue
+		}
+		for i, name := range h3cDOMColumns {
+			reading := current.reading(name)
+			number, ok := parseDOMValue(fields[i], name == DOM_RX_POWER || name == DOM_TX_POWER)
+			switch kind {
+			case "high":
+				reading.High = number
+			case "low":
+				reading.Low = number
+			default:
+				reading.Value, reading.Valid = number, ok
+			}
+		}
+	}
+	return transceivers
+}
+
+var ciscoDOMFlagRegexp = regexp.MustCompile(`^[+-]{1,2}$`)
+
+/**
+ * 解析cisco的光模块诊断信息（show interfaces transceiver detail），温度、电压、电流、发送光功率、接收光功率分别为一个表格，
+ * 每行为"接口 当前值 High Alarm High Warn Low Warn Low Alarm"，当前值之后可能带有"++"、"-"等告警标记；阈值取Alarm列
+ * @param output 指令的输出
+ * @return 光模块信息（按接口第一次出现的顺序）
+ * @author shenbowei
+ */
+func parseCiscoTransceivers(output string) []Transceiver {
+	transceivers := make([]Transceiver, 0)
+	indexes := make(map[string]int)
+	name := ""
+	for _, line := range outputLines(output) {
+		fields := make([]string, 0)
+		for _, field := range strings.Fields(line) {
+			if !ciscoDOMFlagRegexp.MatchString(field) {
+				fields = append(fields, field)
+			}
+		}
+		high, err := 0.0, error(nil)
+		if len(fields) >= 6 {
+			high, err = strconv.ParseFloat(fields[2], 64)
+		}
+		if len(fields) < 6 || err != nil {
+			switch {
+			case strings.Contains(line, "Transmit Power"):
+				name = DOM_TX_POWER
+			case strings.Contains(line, "Receive Power"):
+				name = DOM_RX_POWER
+			case strings.Contains(line, "Temperature"):
+				name = DOM_TEMPERATURE
+			case strings.Contains(line, "Voltage"):
+				name = DOM_VOLTAGE
+			case len(fields) > 0 && fields[0] == "Current":
+				name = DOM_BIAS
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		low, _ := strconv.ParseFloat(fields[5], 64)
+		port := NormalizeInterfaceName(CISCO, fields[0])
+		index, ok := indexes[port]
+		if !ok {
+			index = len(transceivers)
+			indexes[port] = index
+			transceivers = append(transceivers, Transceiver{Interface: port})
+		}
+		reading := transceivers[index].reading(name)
+		reading.Value, reading.Valid = parseDOMValue(fields[1], name == DOM_RX_POWER || name == DOM_TX_POWER)
+		reading.High, reading.Low = high, low
+	}
+	return transceivers
+}
+
+/**
+ * 保存每台设备上一次的光模块读数，检查超出阈值的读数和两次采样之间光功率的漂移，可以在多个协程中使用
+ * @attr MaxDrift:光功率的漂移告警阈值（dB），samples:设备索引键值（Device.Key）对应的上一次读数
+ * @author shenbowei
+ */
+type TransceiverMonitor struct {
+	MaxDrift float64
+	samples  map[string]map[string]Transceiver
+	locker   sync.Mutex
+}
+
+/**
+ * 创建TransceiverMonitor，漂移告警阈值为DEFAULT_TRANSCEIVER_DRIFT
+ * @return TransceiverMonitor
+ * @author shenbowei
+ */
+func NewTransceiverMonitor() *TransceiverMonitor {
+	return &TransceiverMonitor{MaxDrift: DEFAULT_TRANSCEIVER_DRIFT, samples: make(map[string]map[string]Transceiver)}
+}
+
+/**
+ * 从设备获取光模块信息，并检查超出阈值的读数和光功率的漂移
+ * @param manager 获取光模块信息的SessionManager（为nil时使用DefaultSessionManager）, device 设备的身份信息
+ * @return 告警，执行的错误
+ * @author shenbowei
+ */
+func (this *TransceiverMonitor) Poll(manager *SessionManager, device Device) ([]TransceiverAlert, error) {
+	if manager == nil {
+		manager = DefaultSessionManager
+	}
+	transceivers, err := manager.GetTransceivers(device)
+	if err != nil {
+		return nil, err
+	}
+	return this.Update(device.Key(), transceivers), nil
+}
+
+/**
+ * 保存一次采样，返回超出阈值的读数，以及与该设备上一次采样相比发送/接收光功率变化达到MaxDrift的告警
+ * @param key 设备的索引键值, transceivers 光模块信息
+ * @return 告警（第一次采样或新出现的接口没有漂移告警）
+ * @author shenbowei
+ */
+func (this *TransceiverMonitor) Update(key string, transceivers []Transceiver) []TransceiverAlert {
+	sample := make(map[string]Transceiver, len(transceivers))
+	for _, transceiver := range transceivers {
+		sample[transceiver.Interface] = transceiver
+	}
+	this.locker.Lock()
+	//通过结构体字面量创建时samples为nil
+	if this.samples == nil {
+		this.samples = make(map[string]map[string]Transceiver)
+	}
+	previous := this.samples[key]
+	this.samples[key] = sample
+	this.locker.Unlock()
+
+	alerts := make([]TransceiverAlert, 0)
+	for _, transceiver := range transceivers {
+		alerts = append(alerts, transceiver.Alerts()...)
+		last, ok := previous[transceiver.Interface]
+		if !ok || this.MaxDrift <= 0 {
+			continue
+		}
+		for _, name := range []string{DOM_TX_POWER, DOM_RX_POWER} {
+			current, before := transceiver.reading(name), last.reading(name)
+			if current.Valid && before.Valid && math.Abs(current.Value-before.Value) >= this.MaxDrift {
+				alerts = append(alerts, TransceiverAlert{Interface: transceiver.Interface, Reading: name, Kind: TRANSCEIVER_DRIFT,
+					Value: current.Value, Previous: before.Value})
+			}
+		}
+	}
+	return alerts
+}
+
+/**
+ * 删除设备保存的读数
+ * @param key 设备的索引键值
+ * @author shenbowei
+ */
+func (this *TransceiverMonitor) Forget(key string) {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+	delete(this.samples, key)
+}
@@ -0,0 +1,216 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const huaweiTransceiverSample = `GigabitEthernet0/0/25 transceiver information:
+-------------------------------------------------------------------
+Common information:
+  Transceiver Type                      :1000_BASE_SX_SFP
+  Connector Type                        :LC
+  Wavelength(nm)                        :850
+  Transfer Distance(m)                  :300(50um),150(62.5um)
+  Digital Diagnostic Monitoring         :YES
+  Vendor Name                           :HUAWEI
+-------------------------------------------------------------------
+Manufacture information:
+  Manu. Serial Number                   :ABC1234567
+  Manufacturing Date                    :2015-01-01
+-------------------------------------------------------------------
+Diagnostic information:
+  Temperature(Celsius)                  :35.00
+  Temp High Threshold(Celsius)          :95.00
+  Temp Low  Threshold(Celsius)          :-42.00
+  Voltage(V)                            :3.30
+  Volt High Threshold(V)                :3.80
+  Volt Low  Threshold(V)                :2.81
+  Bias Current(mA)                      :6.50
+  Bias High Threshold(mA)               :15.00
+  Bias Low  Threshold(mA)               :1.00
+  RX Power(dBM)                         :-18.32
+  RX Power High Threshold(dBM)          :0.00
+  RX Power Low  Threshold(dBM)          :-17.00
+  TX Power(dBM)                         :-5.40
+  TX Power High Threshold(dBM)          :-1.00
+  TX Power Low  Threshold(dBM)          :-10.50
+-------------------------------------------------------------------
+40GE0/0/1 transceiver information:
+-------------------------------------------------------------------
+Common information:
+  Transceiver Type                      :40GBASE_SR4_QSFP+
+-------------------------------------------------------------------
+Diagnostic information:
+  Temperature(Celsius)                  :30.00
+  RX Power(dBM)                         :-2.10|-2.30|-6.00|-2.20
+  TX Power(dBM)                         :--
+`
+
+const h3cTransceiverSample = `GigabitEthernet1/0/25 transceiver diagnostic information:
+  Current diagnostic parameters:
+    Temp.(°C)  Voltage(V)  Bias(mA)  RX power(dBm)  TX power(dBm)
+    36         3.31        6.13      -3.10          -5.08
+  Alarm thresholds:
+          Temp.(°C)  Voltage(V)  Bias(mA)  RX power(dBm)  TX power(dBm)
+    High  50         3.55        10.00     0.00           0.00
+    Low   0          3.05        1.00      -20.00         -9.00
+`
+
+const ciscoTransceiverSample = `ITU Channel not available (Wavelength not available),
+Transceiver is internally calibrated.
+mA: milliamperes, dBm: decibels (milliwatts), NA or N/A: not applicable.
+++ : high alarm, +  : high warning, -  : low warning, -- : low alarm.
+A2D readouts (if they differ), are reported in parentheses.
+The threshold values are calibrated.
+
+                                High Alarm  High Warn  Low Warn   Low Alarm
+           Temperature          Threshold   Threshold  Threshold  Threshold
+Port       (Celsius)            (Celsius)   (Celsius)  (Celsius)  (Celsius)
+---------  -----------------    ----------  ---------  ---------  ---------
+Gi1/0/49   32.5                 75.0        70.0       0.0        -5.0
+Gi1/0/50   80.1       ++        75.0        70.0       0.0        -5.0
+
+                                High Alarm  High Warn  Low Warn   Low Alarm
+           Voltage              Threshold   Threshold  Threshold  Threshold
+Port       (Volts)              (Volts)     (Volts)    (Volts)    (Volts)
+---------  ---------------      ----------  ---------  ---------  ---------
+Gi1/0/49   3.29                 3.63        3.46       3.13       2.97
+Gi1/0/50   3.30                 3.63        3.46       3.13       2.97
+
+                                High Alarm  High Warn  Low Warn   Low Alarm
+           Current              Threshold   Threshold  Threshold  Threshold
+Port       (milliamperes)       (mA)        (mA)       (mA)       (mA)
+---------  -----------------    ----------  ---------  ---------  ---------
+Gi1/0/49   6.1                  12.0        11.0       2.0        1.0
+Gi1/0/50   6.3                  12.0        11.0       2.0        1.0
+
+           Optical              High Alarm  High Warn  Low Warn   Low Alarm
+           Transmit Power       Threshold   Threshold  Threshold  Threshold
+Port       (dBm)                (dBm)       (dBm)      (dBm)      (dBm)
+---------  -----------------    ----------  ---------  ---------  ---------
+Gi1/0/49   -2.4                 1.0         -1.0       -7.3       -9.3
+Gi1/0/50   -2.5                 1.0         -1.0       -7.3       -9.3
+
+           Optical              High Alarm  High Warn  Low Warn   Low Alarm
+           Receive Power        Threshold   Threshold  Threshold  Threshold
+Port       (dBm)                (dBm)       (dBm)      (dBm)      (dBm)
+---------  -----------------    ----------  ---------  ---------  ---------
+Gi1/0/49   -3.1                 1.0         -1.0       -9.9       -13.9
+Gi1/0/50   N/A                  1.0         -1.0       -9.9       -13.9
+`
+
+func TestParseHuaweiTransceivers(t *testing.T) {
+	transceivers := parseHuaweiTransceivers(huaweiTransceiverSample)
+	if len(transceivers) != 2 {
+		t.Fatalf("huawei transceivers:%+v", transceivers)
+	}
+	if !reflect.DeepEqual(transceivers[0], Transceiver{Interface: "GigabitEthernet0/0/25", Type: "1000_BASE_SX_SFP", Vendor: "HUAWEI",
+		SerialNumber: "ABC1234567",
+		Temperature:  DOMReading{Value: 35, Low: -42, High: 95, Valid: true},
+		Voltage:      DOMReading{Value: 3.3, Low: 2.81, High: 3.8, Valid: true},
+		Bias:         DOMReading{Value: 6.5, Low: 1, High: 15, Valid: true},
+		TxPower:      DOMReading{Value: -5.4, Low: -10.5, High: -1, Valid: true},
+		RxPower:      DOMReading{Value: -18.32, Low: -17, High: 0, Valid: true}}) {
+		t.Errorf("huawei transceiver 0:%+v", transceivers[0])
+	}
+	//多通道模块取最差的通道
+	if transceivers[1].Interface != "40GE0/0/1" || transceivers[1].RxPower.Value != -6 || transceivers[1].TxPower.Valid {
+		t.Errorf("huawei transceiver 1:%+v", transceivers[1])
+	}
+	alerts := transceivers[0].Alerts()
+	if !reflect.DeepEqual(alerts, []TransceiverAlert{{Interface: "GigabitEthernet0/0/25", Reading: DOM_RX_POWER, Kind: TRANSCEIVER_OUT_OF_RANGE,
+		Value: -18.32, Low: -17}}) {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+	if alerts := transceivers[1].Alerts(); len(alerts) != 0 {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+}
+
+func TestParseH3cTransceivers(t *testing.T) {
+	transceivers := parseH3cTransceivers(h3cTransceiverSample)
+	if !reflect.DeepEqual(transceivers, []Transceiver{{Interface: "GigabitEthernet1/0/25",
+		Temperature: DOMReading{Value: 36, Low: 0, High: 50, Valid: true},
+		Voltage:     DOMReading{Value: 3.31, Low: 3.05, High: 3.55, Valid: true},
+		Bias:        DOMReading{Value: 6.13, Low: 1, High: 10, Valid: true},
+		TxPower:     DOMReading{Value: -5.08, Low: -9, High: 0, Valid: true},
+		RxPower:     DOMReading{Value: -3.1, Low: -20, High: 0, Valid: true}}}) {
+		t.Errorf("h3c transceivers:%+v", transceivers)
+	}
+}
+
+func TestParseCiscoTransceivers(t *testing.T) {
+	transceivers := parseCiscoTransceivers(ciscoTransceiverSample)
+	if len(transceivers) != 2 {
+		t.Fatalf("cisco transceivers:%+v", transceivers)
+	}
+	if !reflect.DeepEqual(transceivers[0], Transceiver{Interface: "GigabitEthernet1/0/49",
+		Temperature: DOMReading{Value: 32.5, Low: -5, High: 75, Valid: true},
+		Voltage:     DOMReading{Value: 3.29, Low: 2.97, High: 3.63, Valid: true},
+		Bias:        DOMReading{Value: 6.1, Low: 1, High: 12, Valid: true},
+		TxPower:     DOMReading{Value: -2.4, Low: -9.3, High: 1, Valid: true},
+		RxPower:     DOMReading{Value: -3.1, Low: -13.9, High: 1, Valid: true}}) {
+		t.Errorf("cisco transceiver 0:%+v", transceivers[0])
+	}
+	if transceivers[1].Temperature.Value != 80.1 || transceivers[1].RxPower.Valid {
+		t.Errorf("cisco transceiver 1:%+v", transceivers[1])
+	}
+	if alerts := transceivers[1].Alerts(); len(alerts) != 1 || alerts[0].Reading != DOM_TEMPERATURE {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+}
+
+func TestTransceiverMonitor(t *testing.T) {
+	monitor := NewTransceiverMonitor()
+	transceivers := parseCiscoTransceivers(ciscoTransceiverSample)
+	//第一次采样只有超出阈值的告警
+	if alerts := monitor.Update("sw1", transceivers); len(alerts) != 1 || alerts[0].Kind != TRANSCEIVER_OUT_OF_RANGE {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+
+	drifted := parseCiscoTransceivers(ciscoTransceiverSample)
+	drifted[0].RxPower.Value = -6.2
+	drifted[0].TxPower.Value = -3.0
+	alerts := monitor.Update("sw1", drifted)
+	if len(alerts) != 2 || alerts[0] != (TransceiverAlert{Interface: "GigabitEthernet1/0/49", Reading: DOM_RX_POWER, Kind: TRANSCEIVER_DRIFT,
+		Value: -6.2, Previous: -3.1}) || alerts[1].Interface != "GigabitEthernet1/0/50" {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+
+	monitor.Forget("sw1")
+	if alerts := monitor.Update("sw1", transceivers); len(alerts) != 1 {
+		t.Errorf("unexpected alerts after Forget:%+v", alerts)
+	}
+}
+
+func TestTransceiverMonitorLiteral(t *testing.T) {
+	monitor := &TransceiverMonitor{MaxDrift: 3}
+	transceivers := parseCiscoTransceivers(ciscoTransceiverSample)
+	if alerts := monitor.Update("sw1", transceivers); len(alerts) != 1 {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+	drifted := parseCiscoTransceivers(ciscoTransceiverSample)
+	drifted[0].RxPower.Value = -6.2
+	if alerts := monitor.Update("sw1", drifted); len(alerts) != 2 || alerts[0].Kind != TRANSCEIVER_DRIFT {
+		t.Errorf("unexpected alerts:%+v", alerts)
+	}
+	(&TransceiverMonitor{}).Forget("sw1")
+}
+
+func TestSessionManagerGetTransceivers(t *testing.T) {
+	fake := newFakeSwitch(t, "<HW1>", map[string]string{HuaweiTransceiverCmd: huaweiTransceiverSample})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+	device := NewDevice("admin", "admin", fake.addr(), HUAWEI)
+
+	transceivers, err := manager.GetTransceivers(device)
+	if err != nil || len(transceivers) != 2 || transceivers[0].RxPower.Value != -18.32 {
+		t.Errorf("GetTransceivers transceivers:%+v err:%v", transceivers, err)
+	}
+	alerts, err := NewTransceiverMonitor().Poll(manager, device)
+	if err != nil || len(alerts) != 1 || alerts[0].Reading != DOM_RX_POWER {
+		t.Errorf("Poll alerts:%+v err:%v", alerts, err)
+	}
+}
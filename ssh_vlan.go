@@ -0,0 +1,312 @@
+package ssh
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * VLAN
+ * @attr ID:VLAN号，Name:VLAN名称（华为为Description列），TaggedPorts:以tagged方式加入的规范化接口，
+ *       UntaggedPorts:以untagged方式加入的规范化接口（cisco trunk口的native VLAN也算作untagged）
+ * @author shenbowei
+ */
+type VLAN struct {
+	ID            int
+	Name          string
+	TaggedPorts   []string
+	UntaggedPorts []string
+}
+
+// 获取VLAN时各品牌执行的指令
+var (
+	HuaweiVlanCmd = "display vlan"
+	H3cVlanCmd    = "display vlan all"
+	CiscoVlanCmd  = "show vlan brief"
+	CiscoTrunkCmd = "show interfaces trunk"
+)
+
+/**
+ * 获取设备的VLAN及其成员接口
+ * @param device 设备的身份信息
+ * @return VLAN（按设备输出的顺序），执行的错误
+ * @author shenbowei
+ */
+func (this *SessionManager) GetVLANs(device Device) ([]VLAN, error) {
+	brand, outputs, err := this.runBrandCommands(device, map[string][]string{
+		HUAWEI: {HuaweiVlanCmd},
+		H3C:    {H3cVlanCmd},
+		CISCO:  {CiscoVlanCmd, CiscoTrunkCmd},
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch brand {
+	case H3C:
+		return parseH3cVLANs(outputs[H3cVlanCmd]), nil
+	case CISCO:
+		return parseCiscoVLANs(outputs[CiscoVlanCmd], outputs[CiscoTrunkCmd]), nil
+	}
+	return parseHuaweiVLANs(outputs[HuaweiVlanCmd]), nil
+}
+
+/**
+ * 解析VLAN列表，如"1,10,20-22"，"none"等无法解析的部分被忽略
+ * @param text VLAN列表的文本
+ * @return VLAN号
+ * @author shenbowei
+ */
+func parseVLANList(text string) []int {
+	vlans := make([]int, 0)
+	for _, part := range strings.Split(text, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil || end < start {
+				continue
+			}
+		}
+		for vlan := start; vlan <= end; vlan++ {
+			vlans = append(vlans, vlan)
+		}
+	}
+	return vlans
+}
+
+var (
+	huaweiVLANRegexp     = regexp.MustCompile(`^(\d+)\s+\S+\s*(.*)$`)
+	huaweiVLANPortRegexp = regexp.MustCompile(`\([A-Z]\)$`)
+)
+
+/**
+ * 解析华为的VLAN（display vlan）。第一个表格"VID Type Ports"中成员接口以"UT:"、"TG:"开始，
+ * 接口名后带有"(U)"、"(D)"等状态，换行的接口在缩进的行中；第二个表格"VID Status Property ... Description"中获取名称
+ * @param output 指令的输出
+ * @return VLAN
+ * @author shenbowei
+ */
+func parseHuaweiVLANs(output string) []VLAN {
+	vlans := make([]VLAN, 0)
+	indexes := make(map[int]int)
+	table, mode := "", ""
+	var current *VLAN
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "VID" {
+			table = fields[1]
+			current = nil
+			continue
+		}
+		switch table {
+		case "Type":
+			if match := huaweiVLANRegexp.FindStringSubmatch(line); match != nil {
+				id, _ := strconv.Atoi(match[1])
+				indexes[id] = len(vlans)
+				vlans = append(vlans, VLAN{ID: id, TaggedPorts: make([]string, 0), UntaggedPorts: make([]string, 0)})
+				current, mode = &vlans[len(vlans)-1], ""
+				fields = strings.Fields(match[2])
+			} else if current == nil || !strings.HasPrefix(line, " ") {
+				continue
+			}
+			for _, field := range fields {
+				if index := strings.Index(field, ":"); index >= 0 {
+					//MP:、ST:等其他类型的成员不记录
+					mode, field = field[:index], field[index+1:]
+				}
+				field = huaweiVLANPortRegexp.ReplaceAllString(field, "")
+				if field == "" {
+					continue
+				}
+				switch mode {
+				case "UT":
+					current.UntaggedPorts = append(current.UntaggedPorts, NormalizeInterfaceName(HUAWEI, field))
+				case "TG":
+					current.TaggedPorts = append(current.TaggedPorts, NormalizeInterfaceName(HUAWEI, field))
+				}
+			}
+		case "Status":
+			if len(fields) < 6 || !isDigits(fields[0]) {
+				continue
+			}
+			id, _ := strconv.Atoi(fields[0])
+			if index, ok := indexes[id]; ok {
+				vlans[index].Name = strings.Join(fields[5:], " ")
+			}
+		}
+	}
+	return vlans
+}
+
+var (
+	h3cVLANIDRegexp   = regexp.MustCompile(`^\s*VLAN ID:\s*(\d+)`)
+	h3cVLANPortRegexp = regexp.MustCompile(`(?i)^\s*(tagged|untagged)\s+ports:\s*(.*)$`)
+)
+
+/**
+ * 解析h3c的VLAN（display vlan all）。每个VLAN以"VLAN ID: 1"开始，"Tagged ports:"、"Untagged ports:"之后
+ * 为成员接口（可能换行到缩进的行中，没有成员时为"None"）
+ * @param output 指令的输出
+ * @return VLAN
+ * @author shenbowei
+ */
+func parseH3cVLANs(output string) []VLAN {
+	vlans := make([]VLAN, 0)
+	mode := ""
+	var current *VLAN
+	for _, line := range outputLines(output) {
+		if match := h3cVLANIDRegexp.FindStringSubmatch(line); match != nil {
+			id, _ := strconv.Atoi(match[1])
+			vlans = append(vlans, VLAN{ID: id, TaggedPorts: make([]string, 0), UntaggedPorts: make([]string, 0)})
+			current, mode = &vlans[len(vlans)-1], ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		ports := ""
+		if match := h3cVLANPortRegexp.FindStringSubmatch(line); match != nil {
+			mode, ports = strings.ToLower(match[1]), match[2]
+		} else if strings.Contains(line, ":") {
+			mode = ""
+			if strings.HasPrefix(strings.TrimSpace(line), "Name:") {
+				current.Name = lineValue(line)
+			}
+			continue
+		} else {
+			ports = line
+		}
+		for _, field := range strings.Fields(ports) {
+			if strings.EqualFold(field, "none") {
+				continue
+			}
+			switch mode {
+			case "untagged":
+				current.UntaggedPorts = append(current.UntaggedPorts, NormalizeInterfaceName(H3C, field))
+			case "tagged":
+				current.TaggedPorts = append(current.TaggedPorts, NormalizeInterfaceName(H3C, field))
+			}
+		}
+	}
+	return vlans
+}
+
+var ciscoVLANRegexp = regexp.MustCompile(`^(\d+)\s+(\S+)\s+(active|suspended|act/\S+|sus/\S+)\s*(.*)$`)
+
+/**
+ * 解析cisco的VLAN（show vlan brief），其中只包含access口；trunk口从show interfaces trunk中获取：
+ * "allowed and active in management domain"（NX-OS没有该段落时为"Vlans Allowed on Trunk"）中的VLAN为tagged，
+ * native VLAN为untagged。不支持的默认VLAN（1002-1005，状态为act/unsup）被忽略
+ * @param output show vlan brief的输出, trunk show interfaces trunk的输出
+ * @return VLAN
+ * @author shenbowei
+ */
+func parseCiscoVLANs(output, trunk string) []VLAN {
+	vlans := make([]VLAN, 0)
+	indexes := make(map[int]int)
+	var current *VLAN
+	for _, line := range outputLines(output) {
+		ports := ""
+		if match := ciscoVLANRegexp.FindStringSubmatch(line); match != nil {
+			current = nil
+			if strings.HasSuffix(match[3], "unsup") {
+				continue
+			}
+			id, _ := strconv.Atoi(match[1])
+			indexes[id] = len(vlans)
+			vlans = append(vlans, VLAN{ID: id, Name: match[2], TaggedPorts: make([]string, 0), UntaggedPorts: make([]string, 0)})
+			current, ports = &vlans[len(vlans)-1], match[4]
+		} else if current != nil && strings.HasPrefix(line, " ") {
+			ports = line
+		}
+		for _, port := range strings.Split(ports, ",") {
+			if port = strings.TrimSpace(port); port != "" {
+				current.UntaggedPorts = append(current.UntaggedPorts, NormalizeInterfaceName(CISCO, port))
+			}
+		}
+	}
+
+	natives, allowed, active := parseCiscoTrunks(trunk)
+	if len(active) > 0 {
+		allowed = active
+	}
+	ports := make([]string, 0, len(allowed))
+	for port := range allowed {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		native, hasNative := natives[port]
+		for _, id := range parseVLANList(allowed[port]) {
+			index, ok := indexes[id]
+			if !ok {
+				continue
+			}
+			if hasNative && id == native {
+				vlans[index].UntaggedPorts = append(vlans[index].UntaggedPorts, port)
+			} else {
+				vlans[index].TaggedPorts = append(vlans[index].TaggedPorts, port)
+			}
+		}
+	}
+	return vlans
+}
+
+/**
+ * 解析show interfaces trunk的各个段落，段落以"Port"开始的表头区分，换行的VLAN列表在缩进的行中
+ * @param output 指令的输出
+ * @return 各trunk口的native VLAN，允许通过的VLAN列表，允许通过且活动的VLAN列表（接口名均已规范化）
+ * @author shenbowei
+ */
+func parseCiscoTrunks(output string) (map[string]int, map[string]string, map[string]string) {
+	natives := make(map[string]int)
+	allowed := make(map[string]string)
+	active := make(map[string]string)
+	section, nativeColumn, port := "", -1, ""
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		lower := strings.ToLower(line)
+		if fields[0] == "Port" {
+			section, port = "", ""
+			switch {
+			case strings.Contains(lower, "native"):
+				section = "native"
+				for i, field := range fields {
+					if strings.EqualFold(field, "native") {
+						nativeColumn = i
+					}
+				}
+			case strings.Contains(lower, "allowed and active"):
+				section = "active"
+			case strings.Contains(lower, "allowed on trunk"):
+				section = "allowed"
+			}
+			continue
+		}
+		if strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		lists := map[string]map[string]string{"allowed": allowed, "active": active}[section]
+		if strings.HasPrefix(line, " ") {
+			if lists != nil && port != "" {
+				lists[port] += strings.TrimSpace(line)
+			}
+			continue
+		}
+		port = NormalizeInterfaceName(CISCO, fields[0])
+		switch {
+		case section == "native" && nativeColumn > 0 && nativeColumn < len(fields):
+			if native, err := strconv.Atoi(fields[nativeColumn]); err == nil {
+				natives[port] = native
+			}
+		case lists != nil && len(fields) >= 2:
+			lists[port] = fields[1]
+		}
+	}
+	return natives, allowed, active
+}
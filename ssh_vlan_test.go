@@ -0,0 +1,144 @@
+package ssh
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const huaweiVlanSample = `The total number of vlans is : 3
+--------------------------------------------------------------------------------
+U: Up;         D: Down;         TG: Tagged;         UT: Untagged;
+MP: Vlan-mapping;               ST: Vlan-stacking;
+#: ProtocolTransparent-vlan;    *: Management-vlan;
+--------------------------------------------------------------------------------
+
+VID  Type    Ports
+--------------------------------------------------------------------------------
+1    common  UT:GE0/0/1(U)      GE0/0/2(D)      GE0/0/3(D)      GE0/0/4(D)
+                GE0/0/5(D)
+             TG:GE0/0/24(U)
+10   common  UT:GE0/0/6(U)
+             TG:GE0/0/24(U)     Eth-Trunk1(U)
+20   common  TG:GE0/0/24(U)
+
+VID  Status  Property      MAC-LRN Statistics Description
+--------------------------------------------------------------------------------
+1    enable  default       enable  disable    VLAN 0001
+10   enable  default       enable  disable    Servers
+20   enable  default       enable  disable    VLAN 0020
+`
+
+const h3cVlanSample = ` VLAN ID: 1
+ VLAN type: Static
+ Route interface: Not configured
+ Description: VLAN 0001
+ Name: VLAN 0001
+ Tagged ports:   None
+ Untagged ports:
+    GigabitEthernet1/0/1     GigabitEthernet1/0/2
+    GigabitEthernet1/0/3
+
+ VLAN ID: 10
+ VLAN type: Static
+ Route interface: Configured
+ IPv4 address: 10.2.10.1
+ IPv4 subnet mask: 255.255.255.0
+ Description: Servers
+ Name: Servers
+ Tagged ports:
+    XGE1/0/49
+ Untagged ports:
+    GigabitEthernet1/0/4
+`
+
+const ciscoVlanSample = `
+VLAN Name                             Status    Ports
+---- -------------------------------- --------- -------------------------------
+1    default                          active    Gi1/0/2, Gi1/0/3, Gi1/0/4
+                                                Gi1/0/5
+10   Servers                          active    Gi1/0/6
+20   VLAN0020                         active
+1002 fddi-default                     act/unsup
+`
+
+const ciscoTrunkSample = `
+Port        Mode             Encapsulation  Status        Native vlan
+Gi1/0/49    on               802.1q         trunking      1
+Po1         on               802.1q         trunking      99
+
+Port        Vlans allowed on trunk
+Gi1/0/49    1-4094
+Po1         1-4094
+
+Port        Vlans allowed and active in management domain
+Gi1/0/49    1,10,
+            20
+Po1         10
+
+Port        Vlans in spanning tree forwarding state and not pruned
+Gi1/0/49    1,10,20
+Po1         10
+`
+
+func TestParseVLANList(t *testing.T) {
+	if vlans := parseVLANList("1,10,20-22,none"); !reflect.DeepEqual(vlans, []int{1, 10, 20, 21, 22}) {
+		t.Errorf("unexpected vlans:%v", vlans)
+	}
+}
+
+func TestParseVLANs(t *testing.T) {
+	vlans := parseHuaweiVLANs(huaweiVlanSample)
+	if len(vlans) != 3 {
+		t.Fatalf("huawei vlans:%+v", vlans)
+	}
+	if !reflect.DeepEqual(vlans[0], VLAN{ID: 1, Name: "VLAN 0001", TaggedPorts: []string{"GigabitEthernet0/0/24"},
+		UntaggedPorts: []string{"GigabitEthernet0/0/1", "GigabitEthernet0/0/2", "GigabitEthernet0/0/3", "GigabitEthernet0/0/4", "GigabitEthernet0/0/5"}}) {
+		t.Errorf("huawei vlan 0:%+v", vlans[0])
+	}
+	if !reflect.DeepEqual(vlans[1].TaggedPorts, []string{"GigabitEthernet0/0/24", "Eth-Trunk1"}) || vlans[1].Name != "Servers" ||
+		len(vlans[2].UntaggedPorts) != 0 {
+		t.Errorf("huawei vlans:%+v", vlans)
+	}
+
+	vlans = parseH3cVLANs(h3cVlanSample)
+	if len(vlans) != 2 {
+		t.Fatalf("h3c vlans:%+v", vlans)
+	}
+	if !reflect.DeepEqual(vlans[0], VLAN{ID: 1, Name: "VLAN 0001", TaggedPorts: []string{},
+		UntaggedPorts: []string{"GigabitEthernet1/0/1", "GigabitEthernet1/0/2", "GigabitEthernet1/0/3"}}) {
+		t.Errorf("h3c vlan 0:%+v", vlans[0])
+	}
+	if !reflect.DeepEqual(vlans[1], VLAN{ID: 10, Name: "Servers", TaggedPorts: []string{"Ten-GigabitEthernet1/0/49"},
+		UntaggedPorts: []string{"GigabitEthernet1/0/4"}}) {
+		t.Errorf("h3c vlan 1:%+v", vlans[1])
+	}
+
+	vlans = parseCiscoVLANs(ciscoVlanSample, ciscoTrunkSample)
+	if len(vlans) != 3 {
+		t.Fatalf("cisco vlans:%+v", vlans)
+	}
+	//trunk口的native VLAN为untagged
+	if !reflect.DeepEqual(vlans[0], VLAN{ID: 1, Name: "default", TaggedPorts: []string{},
+		UntaggedPorts: []string{"GigabitEthernet1/0/2", "GigabitEthernet1/0/3", "GigabitEthernet1/0/4", "GigabitEthernet1/0/5", "GigabitEthernet1/0/49"}}) {
+		t.Errorf("cisco vlan 0:%+v", vlans[0])
+	}
+	if !reflect.DeepEqual(vlans[1].TaggedPorts, []string{"GigabitEthernet1/0/49", "Port-channel1"}) ||
+		!reflect.DeepEqual(vlans[2], VLAN{ID: 20, Name: "VLAN0020", TaggedPorts: []string{"GigabitEthernet1/0/49"}, UntaggedPorts: []string{}}) {
+		t.Errorf("cisco vlans:%+v", vlans)
+	}
+}
+
+func TestSessionManagerGetVLANs(t *testing.T) {
+	fake := newFakeSwitch(t, "SW1#", map[string]string{
+		CiscoVlanCmd:  ciscoVlanSample,
+		CiscoTrunkCmd: ciscoTrunkSample,
+	})
+	manager := newTestSessionManager()
+	defer manager.Shutdown(context.Background())
+
+	vlans, err := manager.GetVLANs(NewDevice("admin", "admin", fake.addr(), CISCO))
+	if err != nil || len(vlans) != 3 || vlans[1].Name != "Servers" || len(vlans[1].TaggedPorts) != 2 {
+		t.Errorf("GetVLANs vlans:%+v err:%v", vlans, err)
+	}
+}